@@ -0,0 +1,88 @@
+package kine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+type Alerter interface {
+	Alert(a *Alert) error
+}
+
+type AlerterFunc func(a *Alert) error
+
+func (f AlerterFunc) Alert(a *Alert) error {
+	return f(a)
+}
+
+// LogAlerter writes alerts to a logger, or the standard logger when nil.
+type LogAlerter struct {
+	Logger *log.Logger
+}
+
+func (l *LogAlerter) Alert(a *Alert) error {
+	if l.Logger == nil {
+		log.Print(a)
+		return nil
+	}
+	l.Logger.Print(a)
+	return nil
+}
+
+// WebhookAlerter posts alerts as JSON to URL.
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookAlerter) Alert(a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// SNSAlerter publishes alerts to an SNS topic.
+type SNSAlerter struct {
+	svc      *sns.SNS
+	topicARN string
+}
+
+// NewSNSAlerter returns an alerter publishing to topicARN with the
+// credentials and region of k.
+func (k *Kine) NewSNSAlerter(topicARN string) *SNSAlerter {
+	return &SNSAlerter{
+		svc:      sns.New(k.session, k.serviceConfig(sns.EndpointsID)),
+		topicARN: topicARN,
+	}
+}
+
+func (s *SNSAlerter) Alert(a *Alert) error {
+	_, err := s.svc.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Subject:  aws.String(fmt.Sprintf("kine: %s %s", a.StreamName, a.Condition)),
+		Message:  aws.String(a.String()),
+	})
+	return err
+}
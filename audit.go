@@ -0,0 +1,257 @@
+package kine
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// auditedOperations are the Kinesis API calls changing a stream.
+var auditedOperations = map[string]bool{
+	"CreateStream":                  true,
+	"DeleteStream":                  true,
+	"SplitShard":                    true,
+	"MergeShards":                   true,
+	"UpdateShardCount":              true,
+	"IncreaseStreamRetentionPeriod": true,
+	"DecreaseStreamRetentionPeriod": true,
+	"StartStreamEncryption":         true,
+	"StopStreamEncryption":          true,
+	"AddTagsToStream":               true,
+	"RemoveTagsFromStream":          true,
+	"EnableEnhancedMonitoring":      true,
+	"DisableEnhancedMonitoring":     true,
+}
+
+// auditTimeFormat has a fixed width, so that the times sort as strings.
+const auditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// AuditEvent is a change kine made to a stream.
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Principal is the ARN of the caller identity, empty when unknown.
+	Principal  string                 `json:"principal,omitempty"`
+	StreamName string                 `json:"stream"`
+	Operation  string                 `json:"operation"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Duration   time.Duration          `json:"duration"`
+	// Error is empty when the operation succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink records the changes kine makes, for compliance reviews.
+type AuditSink interface {
+	Record(e *AuditEvent) error
+}
+
+// WithAuditSink records every Kinesis call changing a stream made through
+// the Kine, by kine itself or through AWSKinesis, to the sink. A failing
+// sink is logged and does not fail the call.
+func WithAuditSink(s AuditSink) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.auditSink = s
+		return nil
+	})
+}
+
+// principal returns the ARN of the caller identity, looked up once.
+func (k *Kine) principal() string {
+	k.principalOnce.Do(func() {
+		out, err := sts.New(k.session, k.serviceConfig(sts.EndpointsID)).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+		if err != nil {
+			log.Printf("kine: audit: caller identity: %v", err)
+			return
+		}
+		k.principalARN = aws.StringValue(out.Arn)
+	})
+	return k.principalARN
+}
+
+// auditRequest is a Complete handler of the Kinesis client.
+func (k *Kine) auditRequest(r *request.Request) {
+	if !auditedOperations[r.Operation.Name] {
+		return
+	}
+
+	var params map[string]interface{}
+	if b, err := json.Marshal(r.Params); err == nil {
+		json.Unmarshal(b, &params)
+	}
+	streamName, _ := params["StreamName"].(string)
+
+	e := &AuditEvent{
+		Time:       r.Time,
+		Principal:  k.principal(),
+		StreamName: streamName,
+		Operation:  r.Operation.Name,
+		Parameters: params,
+		Duration:   time.Since(r.Time),
+	}
+	if r.Error != nil {
+		e.Error = r.Error.Error()
+	}
+	if err := k.auditSink.Record(e); err != nil {
+		log.Printf("kine: audit: %s %s: %v", e.Operation, e.StreamName, err)
+	}
+}
+
+// JSONFileAuditSink appends the events to a file as JSON lines.
+type JSONFileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func NewJSONFileAuditSink(path string) (*JSONFileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONFileAuditSink{path: path, f: f}, nil
+}
+
+func (s *JSONFileAuditSink) Record(e *AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+func (s *JSONFileAuditSink) Close() error {
+	return s.f.Close()
+}
+
+// DynamoDBAuditSink puts the events into a DynamoDB table with the string
+// partition key "StreamName" and the string sort key "Time".
+type DynamoDBAuditSink struct {
+	k         *Kine
+	tableName string
+}
+
+func (k *Kine) NewDynamoDBAuditSink(tableName string) *DynamoDBAuditSink {
+	return &DynamoDBAuditSink{k: k, tableName: tableName}
+}
+
+func (s *DynamoDBAuditSink) Record(e *AuditEvent) error {
+	params, err := json.Marshal(e.Parameters)
+	if err != nil {
+		return err
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		"StreamName": {S: aws.String(e.StreamName)},
+		"Time":       {S: aws.String(e.Time.UTC().Format(auditTimeFormat))},
+		"Operation":  {S: aws.String(e.Operation)},
+		"Parameters": {S: aws.String(string(params))},
+		"DurationMs": {N: aws.String(strconv.FormatInt(int64(e.Duration/time.Millisecond), 10))},
+	}
+	if e.Principal != "" {
+		item["Principal"] = &dynamodb.AttributeValue{S: aws.String(e.Principal)}
+	}
+	if e.Error != "" {
+		item["Error"] = &dynamodb.AttributeValue{S: aws.String(e.Error)}
+	}
+	_, err = s.k.ddb.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// CloudWatchLogsAuditSink puts the events as JSON into a log stream, which
+// is created when it does not exist. The log group must exist.
+type CloudWatchLogsAuditSink struct {
+	svc       *cloudwatchlogs.CloudWatchLogs
+	group     string
+	stream    string
+	mu        sync.Mutex
+	created   bool
+	nextToken *string
+}
+
+func (k *Kine) NewCloudWatchLogsAuditSink(logGroup, logStream string) *CloudWatchLogsAuditSink {
+	return &CloudWatchLogsAuditSink{
+		svc:    cloudwatchlogs.New(k.session, k.serviceConfig(cloudwatchlogs.EndpointsID)),
+		group:  logGroup,
+		stream: logStream,
+	}
+}
+
+func (s *CloudWatchLogsAuditSink) Record(e *AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.created {
+		_, err := s.svc.CreateLogStream(&cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String(s.group),
+			LogStreamName: aws.String(s.stream),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
+			err = s.refreshToken()
+		}
+		if err != nil {
+			return err
+		}
+		s.created = true
+	}
+
+	in := &cloudwatchlogs.PutLogEventsInput{
+		LogEvents: []*cloudwatchlogs.InputLogEvent{{
+			Message:   aws.String(string(b)),
+			Timestamp: aws.Int64(e.Time.UnixNano() / int64(time.Millisecond)),
+		}},
+		LogGroupName:  aws.String(s.group),
+		LogStreamName: aws.String(s.stream),
+	}
+	for attempt := 0; ; attempt++ {
+		in.SequenceToken = s.nextToken
+		out, err := s.svc.PutLogEvents(in)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeInvalidSequenceTokenException && attempt == 0 {
+			// another writer put into the log stream
+			if err := s.refreshToken(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.nextToken = out.NextSequenceToken
+		return nil
+	}
+}
+
+func (s *CloudWatchLogsAuditSink) refreshToken() error {
+	out, err := s.svc.DescribeLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName:        aws.String(s.group),
+		LogStreamNamePrefix: aws.String(s.stream),
+	})
+	if err != nil {
+		return err
+	}
+	s.nextToken = nil
+	for _, ls := range out.LogStreams {
+		if aws.StringValue(ls.LogStreamName) == s.stream {
+			s.nextToken = ls.UploadSequenceToken
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// AutoscalerConfig is the policy of an Autoscaler, read from YAML by
+// LoadAutoscalerConfigFile:
+//
+//	minShards: 2
+//	maxShards: 64
+//	headroom: 0.3
+//	lookback: 1h
+//	cooldown: 15m
+//	interval: 5m
+type AutoscalerConfig struct {
+	MinShards int `json:"minShards" yaml:"minShards"`
+	MaxShards int `json:"maxShards" yaml:"maxShards"`
+	// Headroom is passed to Recommend; it defaults to 20%.
+	Headroom float64 `json:"headroom,omitempty" yaml:"headroom,omitempty"`
+	// Lookback is the window of Recommend; it defaults to one hour.
+	Lookback time.Duration `json:"lookback,omitempty" yaml:"lookback,omitempty"`
+	// Cooldown is the least time between two scale operations.
+	Cooldown time.Duration `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+	// Interval is how often Run decides; it defaults to five minutes.
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+const (
+	defaultAutoscaleLookback = time.Hour
+	defaultAutoscaleInterval = 5 * time.Minute
+)
+
+func (c *AutoscalerConfig) validate() error {
+	if c.MinShards < 1 || c.MaxShards < c.MinShards {
+		return fmt.Errorf("invalid shard bounds %d..%d", c.MinShards, c.MaxShards)
+	}
+	if c.Headroom < 0 {
+		return fmt.Errorf("invalid headroom %v", c.Headroom)
+	}
+	if c.Lookback == 0 {
+		c.Lookback = defaultAutoscaleLookback
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultAutoscaleInterval
+	}
+	if c.Headroom == 0 {
+		c.Headroom = defaultHeadroom
+	}
+	return nil
+}
+
+func LoadAutoscalerConfigFile(path string) (AutoscalerConfig, error) {
+	var c AutoscalerConfig
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Autoscaler scales a stream to the shard count recommended by its recent
+// traffic, within bounds.
+type Autoscaler struct {
+	k          *Kine
+	streamName string
+
+	mu        sync.Mutex
+	config    AutoscalerConfig
+	lastScale time.Time
+	reloaded  chan struct{}
+}
+
+func (k *Kine) NewAutoscaler(streamName string, config AutoscalerConfig) (*Autoscaler, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Autoscaler{
+		k:          k,
+		streamName: streamName,
+		config:     config,
+		reloaded:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Config returns the current configuration.
+func (a *Autoscaler) Config() AutoscalerConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.config
+}
+
+// Reload replaces the configuration while the autoscaler runs. A scale
+// operation in flight completes with its target; the next decision uses
+// the new configuration, and is made right away.
+func (a *Autoscaler) Reload(config AutoscalerConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.config = config
+	a.mu.Unlock()
+
+	select {
+	case a.reloaded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Step makes one scaling decision and returns the shard count it scaled
+// to, or zero when it left the stream alone.
+func (a *Autoscaler) Step() (int, error) {
+	a.mu.Lock()
+	c := a.config
+	cooling := a.k.clock.Now().Sub(a.lastScale) < c.Cooldown
+	a.mu.Unlock()
+
+	if cooling {
+		return 0, nil
+	}
+
+	r, err := a.k.Recommend(a.streamName, c.Lookback, WithHeadroom(c.Headroom))
+	if err != nil {
+		return 0, err
+	}
+	target := r.ShardCount
+	if target < c.MinShards {
+		target = c.MinShards
+	}
+	if target > c.MaxShards {
+		target = c.MaxShards
+	}
+	if target == r.CurrentShardCount {
+		return 0, nil
+	}
+	a.k.emit(EventAutoscaleDecision, a.streamName, map[string]interface{}{
+		"currentShardCount":     r.CurrentShardCount,
+		"recommendedShardCount": r.ShardCount,
+		"shardCount":            target,
+	})
+
+	log.Printf("kine: autoscale %s from %d to %d shards", a.streamName, r.CurrentShardCount, target)
+	err = a.k.ScaleTo(a.streamName, target)
+
+	a.mu.Lock()
+	a.lastScale = a.k.clock.Now()
+	a.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return target, nil
+}
+
+// ScaleUp doubles the open shard count, up to MaxShards, for a reason other
+// than traffic, e.g. an SLO at risk. It honors the cooldown and returns the
+// shard count it scaled to, or zero when it left the stream alone.
+func (a *Autoscaler) ScaleUp(reason string) (int, error) {
+	a.mu.Lock()
+	c := a.config
+	cooling := a.k.clock.Now().Sub(a.lastScale) < c.Cooldown
+	a.mu.Unlock()
+
+	if cooling {
+		return 0, nil
+	}
+
+	shards, err := a.k.openShards(a.streamName, false)
+	if err != nil {
+		return 0, err
+	}
+	current := len(shards)
+	target := current * 2
+	if target > c.MaxShards {
+		target = c.MaxShards
+	}
+	if target <= current {
+		return 0, nil
+	}
+	a.k.emit(EventAutoscaleDecision, a.streamName, map[string]interface{}{
+		"currentShardCount": current,
+		"shardCount":        target,
+		"reason":            reason,
+	})
+
+	log.Printf("kine: autoscale %s from %d to %d shards: %s", a.streamName, current, target, reason)
+	err = a.k.ScaleTo(a.streamName, target)
+
+	a.mu.Lock()
+	a.lastScale = a.k.clock.Now()
+	a.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return target, nil
+}
+
+// Run decides every interval until ctx is done. A failing step is logged
+// and does not stop the loop.
+func (a *Autoscaler) Run(ctx context.Context) error {
+	for {
+		if _, err := a.Step(); err != nil {
+			log.Printf("kine: autoscale %s: %v", a.streamName, err)
+		}
+
+		timer := a.k.clock.NewTimer(a.Config().Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-a.reloaded:
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
+
+// WatchConfigFile reloads the configuration from the file whenever its
+// modification time changes, checking every interval, and whenever the
+// process receives SIGHUP, until ctx is done. An invalid file is logged
+// and the previous configuration kept.
+func (a *Autoscaler) WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var modTime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	reload := func() {
+		c, err := LoadAutoscalerConfigFile(path)
+		if err == nil {
+			err = a.Reload(c)
+		}
+		if err != nil {
+			log.Printf("kine: autoscale %s: reload: %v", a.streamName, err)
+		}
+	}
+
+	for {
+		timer := a.k.clock.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-hup:
+			timer.Stop()
+			reload()
+		case <-timer.C():
+			fi, err := os.Stat(path)
+			if err != nil {
+				log.Printf("kine: autoscale %s: %v", a.streamName, err)
+				continue
+			}
+			if !fi.ModTime().Equal(modTime) {
+				modTime = fi.ModTime()
+				reload()
+			}
+		}
+	}
+}
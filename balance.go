@@ -0,0 +1,209 @@
+package kine
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	// BalanceNamespace is the CloudWatch namespace of the balance metrics.
+	BalanceNamespace = "Kine"
+
+	// A shard is hot when its writes reach this fraction of the shard limit.
+	hotShardUtilization = 0.8
+	hotShardLookback    = 5 * time.Minute
+)
+
+// Balance is the topology health of a stream, as published by a
+// BalancePublisher.
+type Balance struct {
+	StreamName     string
+	OpenShardCount int
+	// MaxRangeShare and MinRangeShare are the fractions of the hash space
+	// covered by the widest and narrowest open shard.
+	MaxRangeShare float64
+	MinRangeShare float64
+	// SkewRatio is MaxRangeShare over the even share; 1 is perfectly even.
+	SkewRatio float64
+	// HotShards is the number of shards whose writes reached 80% of the
+	// shard limit in the last five minutes, or -1 when the shard-level
+	// IncomingBytes and IncomingRecords metrics are not enabled.
+	HotShards int
+}
+
+// Balance computes the topology health of the stream.
+func (k *Kine) Balance(streamName string) (*Balance, error) {
+	shards, err := k.openShards(streamName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Balance{
+		StreamName:     streamName,
+		OpenShardCount: len(shards),
+		MinRangeShare:  math.Inf(1),
+	}
+	for _, s := range shards {
+		share := hashRangeShare(s)
+		b.MaxRangeShare = math.Max(b.MaxRangeShare, share)
+		b.MinRangeShare = math.Min(b.MinRangeShare, share)
+	}
+	if len(shards) == 0 {
+		b.MinRangeShare = 0
+	} else {
+		b.SkewRatio = b.MaxRangeShare * float64(len(shards))
+	}
+
+	b.HotShards, err = k.hotShards(streamName, shards)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (k *Kine) hotShards(streamName string, shards []*kinesis.Shard) (int, error) {
+	utilization, err := k.writeUtilization(streamName, shards, hotShardLookback)
+	if err != nil || utilization == nil {
+		return -1, err
+	}
+	var hot int
+	for _, u := range utilization {
+		if u >= hotShardUtilization {
+			hot++
+		}
+	}
+	return hot, nil
+}
+
+// writeUtilization returns the peak fraction of the shard write limit, in
+// bytes or records, used by each shard over the lookback. It returns
+// nil when the shard-level IncomingBytes and IncomingRecords metrics are
+// not enabled.
+func (k *Kine) writeUtilization(streamName string, shards []*kinesis.Shard, lookback time.Duration) (map[string]float64, error) {
+	bytesEnabled, err := k.shardMetricEnabled(streamName, kinesis.MetricsNameIncomingBytes)
+	if err != nil {
+		return nil, err
+	}
+	recordsEnabled, err := k.shardMetricEnabled(streamName, kinesis.MetricsNameIncomingRecords)
+	if err != nil {
+		return nil, err
+	}
+	if !bytesEnabled || !recordsEnabled {
+		return nil, nil
+	}
+
+	limits := map[string]float64{
+		kinesis.MetricsNameIncomingBytes:   shardWriteBytesPerSecond,
+		kinesis.MetricsNameIncomingRecords: shardWriteRecordsPerSecond,
+	}
+	utilization := make(map[string]float64, len(shards))
+	for _, s := range shards {
+		for metricName, limit := range limits {
+			datapoints, err := k.getMetric(metricName, map[string]string{
+				"StreamName": streamName,
+				"ShardId":    *s.ShardId,
+			}, cloudwatch.StatisticSum, time.Minute, lookback)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range datapoints {
+				utilization[*s.ShardId] = math.Max(utilization[*s.ShardId], *d.Sum/60/limit)
+			}
+		}
+	}
+	return utilization, nil
+}
+
+// BalancePublisher periodically publishes the Balance of streams as custom
+// CloudWatch metrics in the Kine namespace with the StreamName dimension,
+// so that alarms can watch the topology health.
+type BalancePublisher struct {
+	k        *Kine
+	streams  []string
+	interval time.Duration
+}
+
+func (k *Kine) NewBalancePublisher(interval time.Duration, streamNames ...string) *BalancePublisher {
+	return &BalancePublisher{
+		k:        k,
+		streams:  streamNames,
+		interval: interval,
+	}
+}
+
+// Publish computes and publishes the balance of every stream once. A
+// failing stream does not stop the others: the errors are returned together
+// as a *BatchError.
+func (p *BalancePublisher) Publish() error {
+	errs := make(map[string]error)
+	for _, streamName := range p.streams {
+		b, err := p.k.Balance(streamName)
+		if err == nil {
+			err = p.put(b)
+		}
+		if err != nil {
+			errs[streamName] = err
+		}
+	}
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+func (p *BalancePublisher) put(b *Balance) error {
+	dims := []*cloudwatch.Dimension{{
+		Name:  aws.String("StreamName"),
+		Value: aws.String(b.StreamName),
+	}}
+	now := p.k.clock.Now()
+	datum := func(name string, value float64, unit string) *cloudwatch.MetricDatum {
+		return &cloudwatch.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dims,
+			Timestamp:  aws.Time(now),
+			Value:      aws.Float64(value),
+			Unit:       aws.String(unit),
+		}
+	}
+
+	data := []*cloudwatch.MetricDatum{
+		datum("OpenShardCount", float64(b.OpenShardCount), cloudwatch.StandardUnitCount),
+		datum("MaxRangeShare", b.MaxRangeShare*100, cloudwatch.StandardUnitPercent),
+		datum("MinRangeShare", b.MinRangeShare*100, cloudwatch.StandardUnitPercent),
+		datum("SkewRatio", b.SkewRatio, cloudwatch.StandardUnitNone),
+	}
+	if b.HotShards >= 0 {
+		data = append(data, datum("HotShards", float64(b.HotShards), cloudwatch.StandardUnitCount))
+	}
+
+	_, err := p.k.cw.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(BalanceNamespace),
+		MetricData: data,
+	})
+	return err
+}
+
+// Run publishes every interval until ctx is done. A failing publish is
+// logged and does not stop the loop.
+func (p *BalancePublisher) Run(ctx context.Context) error {
+	for {
+		if err := p.Publish(); err != nil {
+			log.Printf("kine: publish balance: %v", err)
+		}
+
+		timer := p.k.clock.NewTimer(p.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
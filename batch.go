@@ -0,0 +1,160 @@
+package kine
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// BatchHandler processes records of a shard in batches. Errors are handled
+// by the retry policy of the consumer, for the whole batch.
+type BatchHandler func(ctx context.Context, records []*Record) error
+
+// Batching bounds the batches of a batch consumer. A batch is delivered as
+// soon as one bound is reached, when the shard ends or when the consumer is
+// shut down.
+type Batching struct {
+	// MaxRecords defaults to 500.
+	MaxRecords int
+	// MaxBytes is the most data of a batch, 5 MiB by default. A batch holds
+	// at least one record, whatever its size.
+	MaxBytes int
+	// MaxWait is the longest time a record waits in a batch, one second by
+	// default. Shards are polled every 200ms, which bounds its precision.
+	MaxWait time.Duration
+}
+
+const (
+	defaultBatchRecords = 500
+	defaultBatchBytes   = 5 << 20
+	defaultBatchWait    = time.Second
+)
+
+type batcher struct {
+	Batching
+	handler BatchHandler
+}
+
+// NewBatchConsumer returns a consumer passing the records of each shard to
+// handler in batches, in order, and checkpointing once per batch handled.
+// Records skipped by the consumer, e.g. heartbeats, are not delivered.
+func (k *Kine) NewBatchConsumer(streamName string, handler BatchHandler, b Batching, opts ...ConsumerOption) *Consumer {
+	if b.MaxRecords == 0 {
+		b.MaxRecords = defaultBatchRecords
+	}
+	if b.MaxBytes == 0 {
+		b.MaxBytes = defaultBatchBytes
+	}
+	if b.MaxWait == 0 {
+		b.MaxWait = defaultBatchWait
+	}
+	c := k.NewConsumer(streamName, nil, opts...)
+	c.batch = &batcher{Batching: b, handler: handler}
+	return c
+}
+
+// consumeBatches reads the shard from the iterator in batches, reporting
+// whether it was read to its end.
+func (c *Consumer) consumeBatches(ctx context.Context, shardID string, iterator *string) (bool, error) {
+	var batch []*Record
+	var size int
+	var started time.Time
+	// last is the last record read, handled or skipped, and checkpointed
+	// the last one checkpointed
+	var last, checkpointed string
+
+	// flush handles the batch and checkpoints the records read so far,
+	// or the end of the shard
+	flush := func(end bool) error {
+		if len(batch) > 0 {
+			err := c.retryHandler(ctx, batch, func() error {
+				return c.batch.handler(ctx, batch)
+			})
+			if err != nil {
+				return err
+			}
+			for _, r := range batch {
+				c.dedup.add(r)
+			}
+		}
+		batch, size = nil, 0
+
+		if end {
+			last = ShardEnd
+		}
+		// an idle shard is not checkpointed again
+		if c.checkpoint == nil || last == "" || last == checkpointed {
+			return nil
+		}
+		if err := c.setCheckpoint(ctx, shardID, last); err != nil {
+			return err
+		}
+		checkpointed = last
+		return nil
+	}
+
+	for iterator != nil && !c.stopping() {
+		out, err := c.k.svc.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
+			ShardIterator: iterator,
+		})
+		if err != nil {
+			return false, err
+		}
+		c.metrics.Set(MetricConsumerLag, float64(aws.Int64Value(out.MillisBehindLatest)), "stream", c.streamName, "shard", shardID)
+		c.metrics.Add(MetricRecordsReceived, float64(len(out.Records)), "stream", c.streamName, "shard", shardID)
+
+		for _, r := range out.Records {
+			record, err := c.prepare(ctx, shardID, r)
+			if err != nil {
+				return false, err
+			}
+			if record != nil {
+				if len(batch) > 0 && size+len(record.Data) > c.batch.MaxBytes {
+					if err := flush(false); err != nil {
+						return false, stopShard(err)
+					}
+				}
+				if len(batch) == 0 {
+					started = c.k.clock.Now()
+				}
+				batch = append(batch, record)
+				size += len(record.Data)
+			}
+			last = *r.SequenceNumber
+			if len(batch) >= c.batch.MaxRecords {
+				if err := flush(false); err != nil {
+					return false, stopShard(err)
+				}
+			}
+		}
+
+		iterator = out.NextShardIterator
+		if iterator == nil || len(batch) == 0 || c.k.clock.Now().Sub(started) >= c.batch.MaxWait {
+			if err := flush(iterator == nil); err != nil {
+				return false, stopShard(err)
+			}
+		}
+		if err := c.wait(ctx, getRecordsInterval); err != nil {
+			return false, err
+		}
+	}
+
+	if iterator != nil {
+		// shut down: deliver the records in flight
+		if err := flush(false); err != nil {
+			return false, stopShard(err)
+		}
+	}
+	return iterator == nil, nil
+}
+
+// stopShard maps errStopShard to stopping the shard without error: the
+// records before the batch are checkpointed already.
+func stopShard(err error) error {
+	if err == errStopShard {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package kine_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/kinetest"
+)
+
+// countingStore counts the checkpoints written.
+type countingStore struct {
+	*kine.MemoryCheckpointStore
+
+	mu     sync.Mutex
+	writes int
+}
+
+func (s *countingStore) SetCheckpoint(ctx context.Context, streamName, shardID, sequenceNumber string) error {
+	s.mu.Lock()
+	s.writes++
+	s.mu.Unlock()
+	return s.MemoryCheckpointStore.SetCheckpoint(ctx, streamName, shardID, sequenceNumber)
+}
+
+func (s *countingStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+func TestBatchConsumerIdleShardsAreNotCheckpointed(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 2)
+	for i := 0; i < 3; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	runClock(t, clock, 50*time.Millisecond)
+
+	h := newHandled()
+	checkpoints := &countingStore{MemoryCheckpointStore: kine.NewMemoryCheckpointStore()}
+	c := k.NewBatchConsumer("orders", func(ctx context.Context, records []*kine.Record) error {
+		for _, r := range records {
+			h.handle(ctx, r)
+		}
+		return nil
+	}, kine.Batching{},
+		kine.WithStartPosition(kine.StartTrimHorizon),
+		kine.WithCheckpointStore(checkpoints),
+	)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(context.Background())
+	}()
+
+	eventually(t, "the records", func() bool { return h.len() == 6 })
+	polls := f.callCount("GetRecords")
+	eventually(t, "idle polls", func() bool { return f.callCount("GetRecords") > polls+20 })
+
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	// one batch per shard
+	if n := checkpoints.count(); n != 2 {
+		t.Errorf("%d checkpoints written, want 2", n)
+	}
+}
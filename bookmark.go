@@ -0,0 +1,111 @@
+package kine
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Bookmark is the position of every open shard of a stream at a moment, to
+// process later everything which arrived since, without a checkpoint store.
+type Bookmark struct {
+	StreamName string    `json:"stream"`
+	Time       time.Time `json:"time"`
+	// SequenceNumbers are the last records of the shards open at Time,
+	// empty for shards without a record in the day before.
+	SequenceNumbers map[string]string `json:"sequenceNumbers"`
+}
+
+// TakeBookmark records the last sequence number of every open shard.
+func (k *Kine) TakeBookmark(streamName string) (*Bookmark, error) {
+	b := &Bookmark{
+		StreamName:      streamName,
+		Time:            k.clock.Now(),
+		SequenceNumbers: make(map[string]string),
+	}
+	peeked, err := k.Peek(streamName, 1)
+	if err != nil {
+		return nil, err
+	}
+	for shardID, records := range peeked {
+		b.SequenceNumbers[shardID] = ""
+		if len(records) > 0 {
+			b.SequenceNumbers[shardID] = records[len(records)-1].SequenceNumber
+		}
+	}
+	return b, nil
+}
+
+// WriteBookmark writes the bookmark as JSON.
+func WriteBookmark(w io.Writer, b *Bookmark) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// ReadBookmark reads a bookmark written by WriteBookmark.
+func ReadBookmark(r io.Reader) (*Bookmark, error) {
+	b := &Bookmark{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ResumeIterators returns a shard iterator by shard ID for every shard with
+// records after the bookmark: after the bookmarked sequence number, at the
+// bookmark time for bookmarked shards which had no records, and from the
+// start for shards created since by resharding. Read a parent shard to its
+// end before its children to keep the order of the records.
+func (k *Kine) ResumeIterators(b *Bookmark) (map[string]string, error) {
+	var shards []*kinesis.Shard
+	err := k.listShards(b.StreamName, func(page []*kinesis.Shard) error {
+		shards = append(shards, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// shards which existed before the bookmark and were not open then have
+	// no records after it
+	descendant := make(map[string]bool)
+	for _, s := range shards {
+		_, bookmarked := b.SequenceNumbers[*s.ShardId]
+		if bookmarked || descendant[aws.StringValue(s.ParentShardId)] || descendant[aws.StringValue(s.AdjacentParentShardId)] {
+			descendant[*s.ShardId] = true
+		}
+	}
+
+	iterators := make(map[string]string)
+	for _, s := range shards {
+		shardID := *s.ShardId
+		if !descendant[shardID] {
+			continue
+		}
+
+		var pos StartPosition
+		seq, bookmarked := b.SequenceNumbers[shardID]
+		switch {
+		case bookmarked && seq != "":
+			pos = StartPosition{
+				Type:            kinesis.ShardIteratorTypeAfterSequenceNumber,
+				SequenceNumbers: map[string]string{shardID: seq},
+			}
+		case bookmarked:
+			pos = StartAtTimestamp(b.Time)
+		default:
+			pos = StartPosition{Type: kinesis.ShardIteratorTypeTrimHorizon}
+		}
+
+		out, err := k.svc.GetShardIterator(pos.iteratorInput(b.StreamName, shardID))
+		if err != nil {
+			return nil, err
+		}
+		iterators[shardID] = *out.ShardIterator
+	}
+	return iterators, nil
+}
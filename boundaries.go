@@ -0,0 +1,52 @@
+package kine
+
+import (
+	"fmt"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// RangesFromBoundaries turns split points, the starting hash keys of all
+// shards but the first, into ranges tiling the hash key space. A leading
+// "0" is accepted and ignored. The points must be strictly increasing.
+func RangesFromBoundaries(boundaries []string) ([]hashrange.Range, error) {
+	ranges := make([]hashrange.Range, 0, len(boundaries)+1)
+	start := hashrange.MinKey
+	for i, b := range boundaries {
+		key, err := hashrange.ParseKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("boundary %d: %w", i+1, err)
+		}
+		if i == 0 && key == hashrange.MinKey {
+			continue
+		}
+		if key.Cmp(start) <= 0 {
+			return nil, fmt.Errorf("boundary %d: %s is not above the previous boundary %s", i+1, key, start)
+		}
+		ranges = append(ranges, hashrange.Range{Start: start, End: key.Prev()})
+		start = key
+	}
+	return append(ranges, hashrange.Range{Start: start, End: hashrange.MaxKey}), nil
+}
+
+// PlanBoundaries returns the plan making the open shards of the stream match
+// exactly the ranges between the boundaries, e.g. ones kept in a config file
+// or computed by DesignBoundaries.
+func (k *Kine) PlanBoundaries(streamName string, boundaries []string) (*Plan, error) {
+
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return topology.PlanBoundaries(boundaries)
+}
+
+// ApplyBoundaries plans and applies the boundaries, returning the plan.
+func (k *Kine) ApplyBoundaries(streamName string, boundaries []string) (*Plan, error) {
+	plan, err := k.PlanBoundaries(streamName, boundaries)
+	if err != nil {
+		return nil, err
+	}
+	return plan, k.Apply(plan)
+}
@@ -0,0 +1,41 @@
+package kine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+func FuzzRangesFromBoundaries(f *testing.F) {
+	f.Add("")
+	f.Add("0")
+	f.Add("0,170141183460469231731687303715884105728")
+	f.Add("10,10")
+	f.Add("20,10")
+	f.Add("1,340282366920938463463374607431768211455")
+	f.Add("340282366920938463463374607431768211456")
+	f.Fuzz(func(t *testing.T, s string) {
+		var boundaries []string
+		if s != "" {
+			boundaries = strings.Split(s, ",")
+		}
+		ranges, err := RangesFromBoundaries(boundaries)
+		if err != nil {
+			return
+		}
+		shards := make([]ShardRange, 0, len(ranges))
+		for _, r := range ranges {
+			if r.Start.Cmp(r.End) > 0 {
+				t.Fatalf("boundaries %q: empty range %s", s, r)
+			}
+			shards = append(shards, ShardRange{Range: r})
+		}
+		if anomalies := CheckTiling(shards); len(anomalies) > 0 {
+			t.Fatalf("boundaries %q: ranges do not tile the hash key space: %v", s, anomalies)
+		}
+		if ranges[0].Start != hashrange.MinKey || ranges[len(ranges)-1].End != hashrange.MaxKey {
+			t.Fatalf("boundaries %q: ranges %v do not cover the hash key space", s, ranges)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package kine
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ShardEnd is the checkpoint of a shard which was read to its end.
+const ShardEnd = "SHARD_END"
+
+// CheckpointStore persists the last processed sequence number of each shard,
+// so a consumer resumes where it stopped. Redis, Postgres or other stores can
+// be plugged by implementing it.
+type CheckpointStore interface {
+	// GetCheckpoint returns an empty string when the shard has no checkpoint.
+	GetCheckpoint(ctx context.Context, streamName, shardID string) (string, error)
+	SetCheckpoint(ctx context.Context, streamName, shardID, sequenceNumber string) error
+}
+
+// AttemptStore is implemented by the checkpoint stores which can count the
+// attempts to handle a record, across restarts of the consumer, for
+// WithQuarantine.
+type AttemptStore interface {
+	// AddAttempt counts an attempt to handle the record and returns the
+	// attempts so far. Only the last record of each shard is counted, so an
+	// attempt of another record starts again from 1.
+	AddAttempt(ctx context.Context, streamName, shardID, sequenceNumber string) (int, error)
+}
+
+// attempts counts the attempts of the last record of a shard.
+type attempts struct {
+	SequenceNumber string `json:"sequenceNumber"`
+	Attempts       int    `json:"attempts"`
+}
+
+func (a *attempts) add(sequenceNumber string) int {
+	if a.SequenceNumber != sequenceNumber {
+		a.SequenceNumber = sequenceNumber
+		a.Attempts = 0
+	}
+	a.Attempts++
+	return a.Attempts
+}
+
+// MemoryCheckpointStore keeps the checkpoints in memory, for tests and
+// consumers which do not need to survive restarts.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]string
+	attempts    map[string]*attempts
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: make(map[string]string),
+		attempts:    make(map[string]*attempts),
+	}
+}
+
+func (s *MemoryCheckpointStore) GetCheckpoint(ctx context.Context, streamName, shardID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[streamName+"/"+shardID], nil
+}
+
+func (s *MemoryCheckpointStore) SetCheckpoint(ctx context.Context, streamName, shardID, sequenceNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[streamName+"/"+shardID] = sequenceNumber
+	return nil
+}
+
+func (s *MemoryCheckpointStore) AddAttempt(ctx context.Context, streamName, shardID, sequenceNumber string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.attempts[streamName+"/"+shardID]
+	if a == nil {
+		a = &attempts{}
+		s.attempts[streamName+"/"+shardID] = a
+	}
+	return a.add(sequenceNumber), nil
+}
+
+// FileCheckpointStore keeps the checkpoints in a JSON file, rewritten
+// atomically on every checkpoint. It suits small single-process consumers.
+// The attempts counted for WithQuarantine are kept in the file with the
+// suffix ".attempts".
+type FileCheckpointStore struct {
+	path string
+
+	mu          sync.Mutex
+	checkpoints map[string]map[string]string
+	attempts    map[string]map[string]*attempts
+}
+
+// NewFileCheckpointStore loads the checkpoints of the file, which is created
+// on the first checkpoint when it does not exist.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		path:        path,
+		checkpoints: make(map[string]map[string]string),
+		attempts:    make(map[string]map[string]*attempts),
+	}
+
+	if err := readJSONFile(path, &s.checkpoints); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(path+".attempts", &s.attempts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readJSONFile leaves v untouched when the file does not exist.
+func readJSONFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// writeJSONFile replaces the file atomically.
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileCheckpointStore) GetCheckpoint(ctx context.Context, streamName, shardID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[streamName][shardID], nil
+}
+
+func (s *FileCheckpointStore) SetCheckpoint(ctx context.Context, streamName, shardID, sequenceNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checkpoints[streamName] == nil {
+		s.checkpoints[streamName] = make(map[string]string)
+	}
+	s.checkpoints[streamName][shardID] = sequenceNumber
+
+	return writeJSONFile(s.path, s.checkpoints)
+}
+
+func (s *FileCheckpointStore) AddAttempt(ctx context.Context, streamName, shardID, sequenceNumber string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attempts[streamName] == nil {
+		s.attempts[streamName] = make(map[string]*attempts)
+	}
+	a := s.attempts[streamName][shardID]
+	if a == nil {
+		a = &attempts{}
+		s.attempts[streamName][shardID] = a
+	}
+	n := a.add(sequenceNumber)
+
+	return n, writeJSONFile(s.path+".attempts", s.attempts)
+}
+
+// DynamoDBCheckpointStore keeps the checkpoints in a DynamoDB table with the
+// string partition key "StreamName" and the string sort key "ShardID".
+// It is the store for production consumers.
+type DynamoDBCheckpointStore struct {
+	k         *Kine
+	tableName string
+}
+
+func (k *Kine) NewDynamoDBCheckpointStore(tableName string) *DynamoDBCheckpointStore {
+	return &DynamoDBCheckpointStore{k: k, tableName: tableName}
+}
+
+func (s *DynamoDBCheckpointStore) GetCheckpoint(ctx context.Context, streamName, shardID string) (string, error) {
+	out, err := s.k.ddb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            checkpointKey(streamName, shardID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if v, ok := out.Item["SequenceNumber"]; ok {
+		return aws.StringValue(v.S), nil
+	}
+	return "", nil
+}
+
+func (s *DynamoDBCheckpointStore) SetCheckpoint(ctx context.Context, streamName, shardID, sequenceNumber string) error {
+	_, err := s.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.tableName),
+		Key:              checkpointKey(streamName, shardID),
+		UpdateExpression: aws.String("SET SequenceNumber = :seq"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":seq": {S: aws.String(sequenceNumber)},
+		},
+	})
+	return err
+}
+
+// AddAttempt keeps the attempts in the attributes "AttemptSequenceNumber"
+// and "Attempts" of the checkpoint item.
+func (s *DynamoDBCheckpointStore) AddAttempt(ctx context.Context, streamName, shardID, sequenceNumber string) (int, error) {
+	out, err := s.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 checkpointKey(streamName, shardID),
+		ConditionExpression: aws.String("AttemptSequenceNumber = :seq"),
+		UpdateExpression:    aws.String("ADD Attempts :one"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":seq": {S: aws.String(sequenceNumber)},
+			":one": {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// first attempt of the record
+		_, err = s.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String(s.tableName),
+			Key:              checkpointKey(streamName, shardID),
+			UpdateExpression: aws.String("SET AttemptSequenceNumber = :seq, Attempts = :one"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":seq": {S: aws.String(sequenceNumber)},
+				":one": {N: aws.String("1")},
+			},
+		})
+		return 1, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(aws.StringValue(out.Attributes["Attempts"].N))
+}
+
+func checkpointKey(streamName, shardID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"StreamName": {S: aws.String(streamName)},
+		"ShardID":    {S: aws.String(shardID)},
+	}
+}
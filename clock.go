@@ -0,0 +1,62 @@
+package kine
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the time source of the waiters and backoffs of kine. Tests can
+// replace it with kinetest.FakeClock to run them without waiting.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a time.Timer created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// WithClock replaces the clock of the waiters, backoffs, producers and
+// consumers; it defaults to the system clock.
+func WithClock(c Clock) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.clock = c
+		return nil
+	})
+}
+
+// sleep waits for d on the clock of k or until ctx is done.
+func (k *Kine) sleep(ctx context.Context, d time.Duration) error {
+	t := k.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package kine
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Clone creates dstStream with the shard count, retention, encryption and
+// tags of srcStream, e.g. for a staging copy of a production stream.
+// With withData, it also starts copying the records of srcStream from
+// TRIM_HORIZON and returns the running replicator, to be stopped with
+// Shutdown; otherwise the returned replicator is nil.
+func (k *Kine) Clone(srcStream, dstStream string, withData bool) (*Replicator, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(srcStream),
+	})
+	if err != nil {
+		return nil, err
+	}
+	src := out.StreamDescriptionSummary
+
+	_, err = k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(dstStream),
+	})
+	if err == nil {
+		return nil, fmt.Errorf("stream %s already exists", dstStream)
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	spec := StreamSpec{
+		Name:           dstStream,
+		ShardCount:     int(*src.OpenShardCount),
+		RetentionHours: int(*src.RetentionPeriodHours),
+	}
+	if aws.StringValue(src.EncryptionType) == kinesis.EncryptionTypeKms {
+		spec.KMSKeyID = aws.StringValue(src.KeyId)
+	}
+	spec.Tags, err = k.listTags(srcStream)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := k.EnsureStream(spec); err != nil {
+		return nil, err
+	}
+
+	if !withData {
+		return nil, nil
+	}
+	r := k.NewReplicator(srcStream, dstStream)
+	return r, r.start()
+}
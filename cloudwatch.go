@@ -0,0 +1,45 @@
+package kine
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+const kinesisNamespace = "AWS/Kinesis"
+
+// getMetric returns the datapoints of a Kinesis metric over the lookback
+// window, oldest first.
+func (k *Kine) getMetric(metricName string, dimensions map[string]string, statistic string, period, lookback time.Duration) ([]*cloudwatch.Datapoint, error) {
+
+	dims := make([]*cloudwatch.Dimension, 0, len(dimensions))
+	for name, value := range dimensions {
+		dims = append(dims, &cloudwatch.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+
+	end := k.clock.Now()
+	out, err := k.cw.GetMetricStatistics(&cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(kinesisNamespace),
+		MetricName: aws.String(metricName),
+		Dimensions: dims,
+		StartTime:  aws.Time(end.Add(-lookback)),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int64(int64(period / time.Second)),
+		Statistics: []*string{aws.String(statistic)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	datapoints := out.Datapoints
+	sort.Slice(datapoints, func(i, j int) bool {
+		return datapoints[i].Timestamp.Before(*datapoints[j].Timestamp)
+	})
+
+	return datapoints, nil
+}
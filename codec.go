@@ -0,0 +1,51 @@
+package kine
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Codec converts the values given to a Producer into record data, and record
+// data back into values for a Consumer. Avro, Protobuf or msgpack can be
+// supported by implementing it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// RawCodec passes bytes through untouched. It marshals []byte and string,
+// and unmarshals into *[]byte and *string. It is the default codec.
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("raw codec cannot marshal %T", v)
+}
+
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = append((*v)[:0], data...)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	}
+	return fmt.Errorf("raw codec cannot unmarshal into %T", v)
+}
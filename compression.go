@@ -0,0 +1,87 @@
+package kine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+// compressionMagic starts the data of compressed records, followed by the
+// ID of the compression. Text and JSON payloads never start with a NUL byte.
+const compressionMagic = "\x00kz"
+
+// compressionMinSize is the size under which compression is not worth it.
+const compressionMinSize = 1024
+
+// Compression is a record compression algorithm. Other algorithms (e.g. zstd)
+// can be plugged by implementing it with an unused ID.
+type Compression interface {
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Gzip compresses records with compress/gzip.
+var Gzip Compression = gzipCompression{}
+
+type gzipCompression struct{}
+
+func (gzipCompression) ID() byte {
+	return 1
+}
+
+func (gzipCompression) Compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompression) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// compress prefixes the compressed data with the header, or returns the data
+// as is when it is small or does not shrink.
+func compress(c Compression, data []byte) ([]byte, error) {
+	if len(data) < compressionMinSize {
+		return data, nil
+	}
+	compressed, err := c.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressionMagic)+1+len(compressed) >= len(data) {
+		return data, nil
+	}
+
+	out := make([]byte, 0, len(compressionMagic)+1+len(compressed))
+	out = append(out, compressionMagic...)
+	out = append(out, c.ID())
+	return append(out, compressed...), nil
+}
+
+// decompress detects the header and decompresses the data with the matching
+// compression, returning uncompressed data as is.
+func decompress(compressions map[byte]Compression, data []byte) ([]byte, error) {
+	n := len(compressionMagic)
+	if len(data) <= n || string(data[:n]) != compressionMagic {
+		return data, nil
+	}
+	c, ok := compressions[data[n]]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %d", data[n])
+	}
+	return c.Decompress(data[n+1:])
+}
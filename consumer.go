@@ -0,0 +1,437 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Record is a record read by a Consumer.
+type Record struct {
+	StreamName     string
+	ShardID        string
+	SequenceNumber string
+	// SubSequenceNumber is the position of the record in its KPL aggregated
+	// record, when it was deaggregated.
+	SubSequenceNumber int
+	PartitionKey      string
+	ArrivalTime       time.Time
+	Data              []byte
+	// Origin is the stream the record was first put into when it comes
+	// from an Aggregator, empty otherwise.
+	Origin string
+
+	codec      Codec
+	aggregated bool
+}
+
+// Decode decodes the record data into v with the codec of the consumer.
+func (r *Record) Decode(v interface{}) error {
+	return r.codec.Unmarshal(r.Data, v)
+}
+
+// Handler processes a record. Errors are handled by the retry policy of the consumer.
+type Handler func(ctx context.Context, r *Record) error
+
+// Consumer reads the shards of a stream, by default starting from the latest
+// records, and passes the records to its handler in order per shard.
+// A shard is read only after its parents are read to their end, and then
+// from its first record, so the records of a partition key stay in order
+// across resharding.
+type Consumer struct {
+	k          *Kine
+	streamName string
+	handler    Handler
+	codec      Codec
+	start      StartPosition
+	checkpoint CheckpointStore
+	retry      RetryPolicy
+	onError    ErrorHandler
+	deadLetter DeadLetterQueue
+	quarantine *quarantine
+	heartbeat  *heartbeatCheck
+	metrics    Metrics
+	leases     *leaseTable
+	standby    *standby
+	dedup      *dedup
+	batch      *batcher
+
+	compressions map[byte]Compression
+	decrypter    *decrypter
+
+	mu       sync.Mutex
+	running  bool
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type ConsumerOption func(c *Consumer)
+
+// WithConsumerCodec sets how Record.Decode decodes data, RawCodec by default.
+func WithConsumerCodec(codec Codec) ConsumerOption {
+	return func(c *Consumer) {
+		c.codec = codec
+	}
+}
+
+// WithStartPosition sets where the shards are read from, StartLatest by default.
+func WithStartPosition(p StartPosition) ConsumerOption {
+	return func(c *Consumer) {
+		c.start = p
+	}
+}
+
+// WithCheckpointStore makes the consumer resume each shard after its last
+// checkpoint, and checkpoint every batch of records once they are handled.
+// Shards without a checkpoint start from the start position.
+func WithCheckpointStore(s CheckpointStore) ConsumerOption {
+	return func(c *Consumer) {
+		c.checkpoint = s
+	}
+}
+
+// WithCheckpointTable checkpoints in the DynamoDB table,
+// see DynamoDBCheckpointStore.
+func WithCheckpointTable(tableName string) ConsumerOption {
+	return func(c *Consumer) {
+		c.checkpoint = c.k.NewDynamoDBCheckpointStore(tableName)
+	}
+}
+
+// WithConsumerMetrics reports the records received and the lag per shard,
+// and the handler latency, to m.
+func WithConsumerMetrics(m Metrics) ConsumerOption {
+	return func(c *Consumer) {
+		c.metrics = m
+	}
+}
+
+// WithDecompression lets the consumer decompress records compressed with
+// other algorithms than Gzip, which is always supported.
+func WithDecompression(compressions ...Compression) ConsumerOption {
+	return func(c *Consumer) {
+		for _, compression := range compressions {
+			c.compressions[compression.ID()] = compression
+		}
+	}
+}
+
+func (k *Kine) NewConsumer(streamName string, handler Handler, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		k:          k,
+		streamName: streamName,
+		handler:    handler,
+		codec:      RawCodec{},
+		start:      StartLatest,
+		retry:      DefaultRetryPolicy,
+		metrics:    nopMetrics{},
+		compressions: map[byte]Compression{
+			Gzip.ID(): Gzip,
+		},
+		decrypter: &decrypter{kms: k.kms},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Run consumes the stream until ctx is done, a shard fails or the consumer
+// is shut down. A consumer runs only once.
+func (c *Consumer) Run(ctx context.Context) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return errors.New("consumer already ran")
+	}
+	c.running = true
+	c.cancel = cancel
+	c.mu.Unlock()
+	defer close(c.done)
+
+	if err := c.checkQuarantine(); err != nil {
+		return err
+	}
+	if c.leases != nil {
+		return c.runLeased(ctx)
+	}
+
+	stream, err := c.k.DescribeStream(c.streamName)
+	if err != nil {
+		return err
+	}
+
+	l := newLineage(stream.Shards)
+	if c.start.Type == kinesis.ShardIteratorTypeLatest {
+		if err := l.skipClosed(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	if c.heartbeat != nil {
+		go c.heartbeat.run(ctx, c.streamName)
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var consume func(shardID string, from StartPosition)
+	consume = func(shardID string, from StartPosition) {
+		defer wg.Done()
+
+		ended, err := c.consumeShard(ctx, shardID, from)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(err)
+			}
+			return
+		}
+		if !ended {
+			return
+		}
+
+		// the children were created after the stream was described
+		l.finish(shardID)
+		if len(l.children(shardID)) == 0 {
+			stream, err := c.k.DescribeStream(c.streamName)
+			if err != nil {
+				fail(err)
+				return
+			}
+			l.add(stream.Shards)
+		}
+		// children start where their parents ended, not at the start position
+		for _, child := range l.ready() {
+			wg.Add(1)
+			go consume(child, StartTrimHorizon)
+		}
+	}
+
+	for _, shardID := range l.ready() {
+		wg.Add(1)
+		go consume(shardID, l.startPosition(c, shardID))
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// Shutdown stops fetching records, lets the handlers finish the records in
+// flight, checkpoints them and waits for Run to return. When ctx is done
+// first, the handlers are canceled and ctx.Err() is returned.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	c.mu.Lock()
+	running, cancel := c.running, c.cancel
+	c.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-c.done
+		return ctx.Err()
+	}
+}
+
+func (c *Consumer) stopping() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait sleeps for d, returning early when the consumer is shut down.
+func (c *Consumer) wait(ctx context.Context, d time.Duration) error {
+	t := c.k.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.stop:
+		return nil
+	case <-t.C():
+		return nil
+	}
+}
+
+// consumeShard reads the shard from the position, reporting whether it was
+// read to its end.
+func (c *Consumer) consumeShard(ctx context.Context, shardID string, from StartPosition) (bool, error) {
+	seq, iterator, err := c.openShard(ctx, shardID, from)
+	if err != nil {
+		return false, err
+	}
+	if seq == ShardEnd {
+		return true, nil
+	}
+	return c.readShard(ctx, shardID, iterator)
+}
+
+// openShard returns the checkpoint of the shard and an iterator after it,
+// or at the position when the shard has no checkpoint. A shard checkpointed
+// at its end has no iterator.
+func (c *Consumer) openShard(ctx context.Context, shardID string, from StartPosition) (string, *string, error) {
+	in := from.iteratorInput(c.streamName, shardID)
+	var seq string
+	if c.checkpoint != nil {
+		var err error
+		seq, err = c.checkpoint.GetCheckpoint(ctx, c.streamName, shardID)
+		if err != nil {
+			return "", nil, err
+		}
+		switch seq {
+		case "":
+		case ShardEnd:
+			return seq, nil, nil
+		default:
+			in.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber)
+			in.StartingSequenceNumber = aws.String(seq)
+			in.Timestamp = nil
+		}
+	}
+
+	out, err := c.k.svc.GetShardIteratorWithContext(ctx, in)
+	if err != nil {
+		return "", nil, err
+	}
+	return seq, out.ShardIterator, nil
+}
+
+// readShard reads the shard from the iterator, reporting whether it was read
+// to its end.
+func (c *Consumer) readShard(ctx context.Context, shardID string, iterator *string) (bool, error) {
+	c.heartbeat.watch(shardID)
+	defer c.heartbeat.forget(shardID)
+	if c.batch != nil {
+		return c.consumeBatches(ctx, shardID, iterator)
+	}
+
+	// a closed shard has no next iterator once it is read to the end
+	for iterator != nil && !c.stopping() {
+		out, err := c.k.svc.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
+			ShardIterator: iterator,
+		})
+		if err != nil {
+			return false, err
+		}
+		c.metrics.Set(MetricConsumerLag, float64(aws.Int64Value(out.MillisBehindLatest)), "stream", c.streamName, "shard", shardID)
+		c.metrics.Add(MetricRecordsReceived, float64(len(out.Records)), "stream", c.streamName, "shard", shardID)
+
+		var last string
+		for _, r := range out.Records {
+			if c.stopping() {
+				return false, c.checkpointRecord(ctx, shardID, last)
+			}
+			err := c.process(ctx, shardID, r)
+			if err == errStopShard {
+				return false, c.checkpointRecord(ctx, shardID, last)
+			}
+			if err != nil {
+				return false, err
+			}
+			last = *r.SequenceNumber
+		}
+
+		if err := c.checkpointBatch(ctx, shardID, out); err != nil {
+			return false, err
+		}
+
+		iterator = out.NextShardIterator
+		if err := c.wait(ctx, getRecordsInterval); err != nil {
+			return false, err
+		}
+	}
+	return iterator == nil, nil
+}
+
+// checkpointRecord checkpoints a record handled in the middle of a batch.
+func (c *Consumer) checkpointRecord(ctx context.Context, shardID, sequenceNumber string) error {
+	if c.checkpoint == nil || sequenceNumber == "" {
+		return nil
+	}
+	return c.setCheckpoint(ctx, shardID, sequenceNumber)
+}
+
+// checkpointBatch checkpoints the last record of a handled batch, or the end
+// of the shard when it was the last batch.
+func (c *Consumer) checkpointBatch(ctx context.Context, shardID string, out *kinesis.GetRecordsOutput) error {
+	if c.checkpoint == nil {
+		return nil
+	}
+
+	seq := ShardEnd
+	if out.NextShardIterator != nil {
+		if len(out.Records) == 0 {
+			return nil
+		}
+		seq = *out.Records[len(out.Records)-1].SequenceNumber
+	}
+	return c.setCheckpoint(ctx, shardID, seq)
+}
+
+// setCheckpoint checkpoints the shard, once the worker checked it still holds
+// its lease, if any: a shard whose lease was taken over is checkpointed by
+// its new owner.
+func (c *Consumer) setCheckpoint(ctx context.Context, shardID, sequenceNumber string) error {
+	if c.leases != nil {
+		if err := c.leases.hold(ctx, shardID); err != nil {
+			return err
+		}
+	}
+	return c.checkpoint.SetCheckpoint(ctx, c.streamName, shardID, sequenceNumber)
+}
+
+func (c *Consumer) newRecord(ctx context.Context, shardID string, r *kinesis.Record) (*Record, error) {
+	origin, data := untagOrigin(r.Data)
+	data, err := c.decrypter.decrypt(ctx, data)
+	if err == nil {
+		data, err = decompress(c.compressions, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", shardID, *r.SequenceNumber, err)
+	}
+
+	return &Record{
+		StreamName:     c.streamName,
+		ShardID:        shardID,
+		SequenceNumber: *r.SequenceNumber,
+		PartitionKey:   *r.PartitionKey,
+		ArrivalTime:    aws.TimeValue(r.ApproximateArrivalTimestamp),
+		Data:           data,
+		Origin:         origin,
+		codec:          c.codec,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package kine_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/kinetest"
+)
+
+// handled records the records a handler saw, from any goroutine.
+type handled struct {
+	mu      sync.Mutex
+	records map[string]int
+}
+
+func newHandled() *handled {
+	return &handled{records: make(map[string]int)}
+}
+
+func (h *handled) handle(ctx context.Context, r *kine.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[r.ShardID+"/"+string(r.Data)]++
+	return nil
+}
+
+func (h *handled) len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.records)
+}
+
+func TestConsumerReadsShardsConcurrently(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 4)
+	for i := 0; i < 5; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	runClock(t, clock, 50*time.Millisecond)
+
+	h := newHandled()
+	checkpoints := kine.NewMemoryCheckpointStore()
+	c := k.NewConsumer("orders", h.handle,
+		kine.WithStartPosition(kine.StartTrimHorizon),
+		kine.WithCheckpointStore(checkpoints),
+	)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(context.Background())
+	}()
+
+	// records put while running are read too
+	eventually(t, "the first records", func() bool { return h.len() == 20 })
+	for i := 5; i < 10; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	eventually(t, "the records put while running", func() bool { return h.len() == 40 })
+
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	for key, n := range h.records {
+		if n != 1 {
+			t.Errorf("%s handled %d times", key, n)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		shardID := fmt.Sprintf("shardId-%012d", i)
+		seq, err := checkpoints.GetCheckpoint(context.Background(), "orders", shardID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq == "" {
+			t.Errorf("%s is not checkpointed", shardID)
+		}
+	}
+}
+
+func TestConsumerShutdownWhileRunning(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 8)
+	runClock(t, clock, 50*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		c := k.NewConsumer("orders", newHandled().handle)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- c.Run(context.Background())
+		}()
+		go f.put("orders", fmt.Sprint(i))
+		if err := c.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConsumerRestartsInClosedShard(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 1)
+	for i := 0; i < 4; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	f.split("orders", "shardId-000000000000")
+	f.put("orders", "4")
+	runClock(t, clock, 50*time.Millisecond)
+
+	// the consumer stopped in the parent before the split
+	checkpoints := kine.NewMemoryCheckpointStore()
+	seq := f.sequenceNumber("orders", "shardId-000000000000", 1)
+	if err := checkpoints.SetCheckpoint(context.Background(), "orders", "shardId-000000000000", seq); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHandled()
+	c := k.NewConsumer("orders", h.handle, kine.WithCheckpointStore(checkpoints))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(context.Background())
+	}()
+
+	// the rest of the parent, then the children from their start
+	want := []string{
+		"shardId-000000000000/2",
+		"shardId-000000000000/3",
+		"shardId-000000000001/4",
+		"shardId-000000000002/4",
+	}
+	eventually(t, "the records after the checkpoint", func() bool { return h.len() == len(want) })
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range want {
+		if h.records[key] != 1 {
+			t.Errorf("%s handled %d times", key, h.records[key])
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package kine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DeadLetter is a failed record with the error of its handler, as written
+// by StreamDeadLetterQueue. Data is base64 encoded in JSON.
+type DeadLetter struct {
+	StreamName     string    `json:"streamName"`
+	ShardID        string    `json:"shardId"`
+	SequenceNumber string    `json:"sequenceNumber"`
+	PartitionKey   string    `json:"partitionKey"`
+	ArrivalTime    time.Time `json:"arrivalTime"`
+	Error          string    `json:"error"`
+	FailedAt       time.Time `json:"failedAt"`
+	Data           []byte    `json:"data"`
+}
+
+func newDeadLetter(r *Record, err error) *DeadLetter {
+	return &DeadLetter{
+		StreamName:     r.StreamName,
+		ShardID:        r.ShardID,
+		SequenceNumber: r.SequenceNumber,
+		PartitionKey:   r.PartitionKey,
+		ArrivalTime:    r.ArrivalTime,
+		Error:          err.Error(),
+		FailedAt:       time.Now(),
+		Data:           r.Data,
+	}
+}
+
+// StreamDeadLetterQueue puts failed records into a Kinesis stream as JSON
+// DeadLetters, with their original partition key.
+type StreamDeadLetterQueue struct {
+	svc        *kinesis.Kinesis
+	streamName string
+}
+
+// NewStreamDeadLetterQueue returns a dead-letter queue putting into the
+// stream, for WithDeadLetterQueue.
+func (k *Kine) NewStreamDeadLetterQueue(streamName string) *StreamDeadLetterQueue {
+	return &StreamDeadLetterQueue{
+		svc:        k.svc,
+		streamName: streamName,
+	}
+}
+
+func (q *StreamDeadLetterQueue) Send(ctx context.Context, r *Record, err error) error {
+	data, err := json.Marshal(newDeadLetter(r, err))
+	if err != nil {
+		return err
+	}
+	_, err = q.svc.PutRecordWithContext(ctx, &kinesis.PutRecordInput{
+		Data:         data,
+		PartitionKey: aws.String(r.PartitionKey),
+		StreamName:   aws.String(q.streamName),
+	})
+	return err
+}
+
+// SQSDeadLetterQueue sends failed records to an SQS queue. The body is the
+// base64 encoded data, and the metadata and error are message attributes.
+// Records larger than about 190 KB exceed the SQS message size once encoded
+// and cannot be sent.
+type SQSDeadLetterQueue struct {
+	svc      *sqs.SQS
+	queueURL string
+}
+
+// NewSQSDeadLetterQueue returns a dead-letter queue sending to queueURL with
+// the credentials and region of k, for WithDeadLetterQueue.
+func (k *Kine) NewSQSDeadLetterQueue(queueURL string) *SQSDeadLetterQueue {
+	return &SQSDeadLetterQueue{
+		svc:      sqs.New(k.session, k.serviceConfig(sqs.EndpointsID)),
+		queueURL: queueURL,
+	}
+}
+
+func (q *SQSDeadLetterQueue) Send(ctx context.Context, r *Record, err error) error {
+	d := newDeadLetter(r, err)
+	attrs := map[string]string{
+		"StreamName":     d.StreamName,
+		"ShardId":        d.ShardID,
+		"SequenceNumber": d.SequenceNumber,
+		"PartitionKey":   d.PartitionKey,
+		"ArrivalTime":    d.ArrivalTime.Format(time.RFC3339Nano),
+		"Error":          d.Error,
+		"FailedAt":       d.FailedAt.Format(time.RFC3339Nano),
+	}
+	in := &sqs.SendMessageInput{
+		MessageAttributes: make(map[string]*sqs.MessageAttributeValue, len(attrs)),
+		MessageBody:       aws.String(base64.StdEncoding.EncodeToString(d.Data)),
+		QueueUrl:          aws.String(q.queueURL),
+	}
+	for name, v := range attrs {
+		if v == "" {
+			// SQS rejects empty attribute values
+			continue
+		}
+		in.MessageAttributes[name] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	_, err = q.svc.SendMessageWithContext(ctx, in)
+	return err
+}
@@ -0,0 +1,110 @@
+package kine
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DedupWindow remembers the records recently handled by a consumer, so that
+// a record delivered again within the window, e.g. after a lease moved back
+// or a checkpoint failed, is not handled twice. The window lives in the
+// memory of the process: it is best effort, and records redelivered to
+// another worker or after a restart are still handled again.
+type DedupWindow struct {
+	// Size is the most records remembered, 10000 by default.
+	Size int
+	// TTL is how long a record is remembered, 5 minutes by default.
+	TTL time.Duration
+	// Key returns the ID of a record, e.g. an ID in its data set by the
+	// producer, which also catches records put twice. It defaults to the
+	// shard and sequence number of the record.
+	Key func(r *Record) string
+}
+
+const (
+	defaultDedupSize = 10000
+	defaultDedupTTL  = 5 * time.Minute
+)
+
+// WithDedupWindow skips the records handled within the window.
+func WithDedupWindow(w DedupWindow) ConsumerOption {
+	return func(c *Consumer) {
+		if w.Size == 0 {
+			w.Size = defaultDedupSize
+		}
+		if w.TTL == 0 {
+			w.TTL = defaultDedupTTL
+		}
+		if w.Key == nil {
+			w.Key = func(r *Record) string {
+				return r.ShardID + "/" + r.SequenceNumber
+			}
+		}
+		c.dedup = &dedup{
+			DedupWindow: w,
+			clock:       c.k.clock,
+			keys:        make(map[string]*list.Element),
+			order:       list.New(),
+		}
+	}
+}
+
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+// dedup is a DedupWindow shared by the shards, keeping its keys in the
+// order they were handled.
+type dedup struct {
+	DedupWindow
+	clock Clock
+
+	mu    sync.Mutex
+	keys  map[string]*list.Element
+	order *list.List
+}
+
+// seen reports whether the record was handled within the window.
+func (d *dedup) seen(r *Record) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire()
+	_, ok := d.keys[d.Key(r)]
+	return ok
+}
+
+// add remembers the record as handled.
+func (d *dedup) add(r *Record) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := d.Key(r)
+	if e, ok := d.keys[key]; ok {
+		d.order.Remove(e)
+	}
+	d.keys[key] = d.order.PushBack(&dedupEntry{key: key, seen: d.clock.Now()})
+	for d.order.Len() > d.Size {
+		d.remove(d.order.Front())
+	}
+}
+
+// expire forgets the records handled before the window.
+func (d *dedup) expire() {
+	limit := d.clock.Now().Add(-d.TTL)
+	for e := d.order.Front(); e != nil && e.Value.(*dedupEntry).seen.Before(limit); e = d.order.Front() {
+		d.remove(e)
+	}
+}
+
+func (d *dedup) remove(e *list.Element) {
+	delete(d.keys, e.Value.(*dedupEntry).key)
+	d.order.Remove(e)
+}
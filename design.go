@@ -0,0 +1,122 @@
+package kine
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// HeatPoint is the observed traffic of one hash key.
+type HeatPoint struct {
+	HashKey hashrange.Key
+	Weight  float64
+}
+
+// HeatMap is a sample of the traffic over the hash key space.
+type HeatMap []HeatPoint
+
+// HashKeyOf returns the hash key Kinesis assigns to a partition key:
+// the MD5 digest read as a 128-bit big-endian integer.
+func HashKeyOf(partitionKey string) hashrange.Key {
+	sum := md5.Sum([]byte(partitionKey))
+	return hashrange.Key{
+		Hi: binary.BigEndian.Uint64(sum[:8]),
+		Lo: binary.BigEndian.Uint64(sum[8:]),
+	}
+}
+
+// HeatMapFromPartitionKeys builds a heat map from sampled partition keys and
+// their record (or byte) counts.
+func HeatMapFromPartitionKeys(counts map[string]float64) HeatMap {
+	heat := make(HeatMap, 0, len(counts))
+	for key, weight := range counts {
+		heat = append(heat, HeatPoint{HashKey: HashKeyOf(key), Weight: weight})
+	}
+	return heat
+}
+
+// DesignBoundaries returns shardCount ranges tiling the hash key space so
+// that each of them receives roughly the same share of the sampled traffic.
+// Boundaries are put halfway between neighboring samples. When the samples
+// cannot be separated any further (e.g. a single very hot key), the widest
+// ranges are split evenly until shardCount is reached.
+func DesignBoundaries(heat HeatMap, shardCount int) ([]hashrange.Range, error) {
+	if shardCount < 1 {
+		return nil, fmt.Errorf("invalid shard count %d", shardCount)
+	}
+
+	points := make(HeatMap, 0, len(heat))
+	var total float64
+	for _, p := range heat {
+		if p.Weight > 0 {
+			points = append(points, p)
+			total += p.Weight
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].HashKey.Cmp(points[j].HashKey) < 0
+	})
+
+	starts := []hashrange.Key{hashrange.MinKey}
+	var cum float64
+	next := 1
+	for i := 0; i < len(points)-1 && next < shardCount; i++ {
+		cum += points[i].Weight
+		if cum < total*float64(next)/float64(shardCount) {
+			continue
+		}
+
+		lo, hi := points[i].HashKey, points[i+1].HashKey
+		if lo == hi {
+			continue
+		}
+		// halfway between the two samples, rounded up so it is above lo
+		mid := lo.Add(hi.Sub(lo).Next().MulFrac(0.5))
+		if mid.Cmp(starts[len(starts)-1]) > 0 {
+			starts = append(starts, mid)
+		}
+		for next < shardCount && cum >= total*float64(next)/float64(shardCount) {
+			next++
+		}
+	}
+
+	ranges := make([]hashrange.Range, 0, shardCount)
+	for i, s := range starts {
+		end := hashrange.MaxKey
+		if i+1 < len(starts) {
+			end = starts[i+1].Prev()
+		}
+		ranges = append(ranges, hashrange.Range{Start: s, End: end})
+	}
+
+	for len(ranges) < shardCount {
+		widest := 0
+		for i, r := range ranges {
+			if r.Width().Cmp(ranges[widest].Width()) > 0 {
+				widest = i
+			}
+		}
+		lower, upper, err := ranges[widest].SplitAt(0.5)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges[:widest], append([]hashrange.Range{lower, upper}, ranges[widest+1:]...)...)
+	}
+
+	return ranges, nil
+}
+
+// Design computes boundaries from the heat map and returns the plan turning
+// the current topology of the stream into them.
+func (k *Kine) Design(streamName string, heat HeatMap, shardCount int) (*Plan, error) {
+
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return topology.PlanDesign(heat, shardCount)
+}
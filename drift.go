@@ -0,0 +1,71 @@
+package kine
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Drift is the difference between the desired configuration of a stream and
+// the actual one, for periodic checks in CI or cron.
+type Drift struct {
+	StreamName string `json:"stream"`
+	// Missing is set when the stream does not exist.
+	Missing bool     `json:"missing,omitempty"`
+	Changes []Change `json:"changes"`
+}
+
+// Drifted reports whether the stream differs from its spec.
+func (d *Drift) Drifted() bool {
+	return len(d.Changes) > 0
+}
+
+// DetectDrift compares the shard count, retention, encryption and tags of the
+// stream with the spec, without changing anything.
+func (k *Kine) DetectDrift(spec StreamSpec) (*Drift, error) {
+	changes, err := k.DiffStream(spec)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []Change{}
+	}
+	d := &Drift{
+		StreamName: spec.Name,
+		Missing:    len(changes) == 1 && changes[0].Field == "stream",
+		Changes:    changes,
+	}
+	if d.Drifted() {
+		k.emit(EventDriftDetected, spec.Name, map[string]interface{}{
+			"missing": d.Missing,
+			"changes": d.Changes,
+		})
+	}
+	return d, nil
+}
+
+// DetectDrifts runs DetectDrift for every spec and returns the streams which
+// drifted.
+func (k *Kine) DetectDrifts(specs []StreamSpec) ([]*Drift, error) {
+	var drifts []*Drift
+	for _, spec := range specs {
+		d, err := k.DetectDrift(spec)
+		if err != nil {
+			return drifts, err
+		}
+		if d.Drifted() {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
+
+// WriteDrifts writes the drifts as indented JSON, the structured diff of a
+// drift check.
+func WriteDrifts(w io.Writer, drifts []*Drift) error {
+	if drifts == nil {
+		drifts = []*Drift{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(drifts)
+}
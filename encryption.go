@@ -0,0 +1,159 @@
+package kine
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// encryptionMagic starts the data of encrypted records, followed by the
+// format version, the length of the encrypted data key, the encrypted data
+// key, the nonce and the AES-GCM sealed payload.
+const (
+	encryptionMagic   = "\x00ke"
+	encryptionVersion = 1
+)
+
+// dataKeyLifetime is how long a producer encrypts with the same data key.
+const dataKeyLifetime = time.Hour
+
+var ErrMalformedEncryptedRecord = errors.New("malformed encrypted record")
+
+type dataKey struct {
+	plaintext []byte
+	encrypted []byte
+	created   time.Time
+}
+
+// encrypter seals record data with KMS data keys.
+type encrypter struct {
+	kms   *kms.KMS
+	keyID string
+	clock Clock
+
+	mu  sync.Mutex
+	key *dataKey
+}
+
+func (e *encrypter) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.key != nil && e.clock.Now().Sub(e.key.created) < dataKeyLifetime {
+		return e.key, nil
+	}
+
+	out, err := e.kms.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(e.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.key = &dataKey{
+		plaintext: out.Plaintext,
+		encrypted: out.CiphertextBlob,
+		created:   e.clock.Now(),
+	}
+	return e.key, nil
+}
+
+func (e *encrypter) encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key.plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptionMagic)+3+len(key.encrypted)+len(nonce)+len(data)+gcm.Overhead())
+	out = append(out, encryptionMagic...)
+	out = append(out, encryptionVersion)
+	out = append(out, byte(len(key.encrypted)>>8), byte(len(key.encrypted)))
+	out = append(out, key.encrypted...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+// decrypter opens encrypted record data, caching the decrypted data keys.
+type decrypter struct {
+	kms *kms.KMS
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func (d *decrypter) dataKey(ctx context.Context, encrypted []byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if key, ok := d.keys[string(encrypted)]; ok {
+		return key, nil
+	}
+
+	out, err := d.kms.DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob: encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.keys == nil {
+		d.keys = make(map[string][]byte)
+	}
+	d.keys[string(encrypted)] = out.Plaintext
+	return out.Plaintext, nil
+}
+
+// decrypt returns the data as is when it is not encrypted.
+func (d *decrypter) decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	n := len(encryptionMagic)
+	if len(data) <= n || string(data[:n]) != encryptionMagic {
+		return data, nil
+	}
+	data = data[n:]
+	if len(data) < 3 || data[0] != encryptionVersion {
+		return nil, ErrMalformedEncryptedRecord
+	}
+	keyLen := int(binary.BigEndian.Uint16(data[1:3]))
+	data = data[3:]
+	if len(data) < keyLen {
+		return nil, ErrMalformedEncryptedRecord
+	}
+
+	key, err := d.dataKey(ctx, data[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	data = data[keyLen:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformedEncryptedRecord
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,130 @@
+package kine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+)
+
+// Types of the events emitted by kine.
+const (
+	EventReshardStarted    = "ReshardStarted"
+	EventReshardCompleted  = "ReshardCompleted"
+	EventReshardStuck      = "ReshardStuck"
+	EventAutoscaleDecision = "AutoscaleDecision"
+	EventDriftDetected     = "DriftDetected"
+	EventStandbyTakeover   = "StandbyTakeover"
+)
+
+// Event is an action kine took or a finding it made, for other automation
+// to react to.
+type Event struct {
+	Type       string                 `json:"type"`
+	StreamName string                 `json:"stream"`
+	Time       time.Time              `json:"time"`
+	Detail     map[string]interface{} `json:"detail,omitempty"`
+}
+
+type EventEmitter interface {
+	Emit(e *Event) error
+}
+
+// WithEventEmitter emits the start and end of every resharding operation,
+// the decisions of autoscalers, detected drift and standby takeovers to e. A failing emitter
+// is logged and does not fail the operation.
+func WithEventEmitter(e EventEmitter) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.events = e
+		return nil
+	})
+}
+
+func (k *Kine) emit(eventType, streamName string, detail map[string]interface{}) {
+	if k.events == nil {
+		return
+	}
+	e := &Event{
+		Type:       eventType,
+		StreamName: streamName,
+		Time:       k.clock.Now(),
+		Detail:     detail,
+	}
+	if err := k.events.Emit(e); err != nil {
+		log.Printf("kine: emit %s %s: %v", eventType, streamName, err)
+	}
+}
+
+// beginReshard takes the reshard lock of the stream and emits
+// ReshardStarted. The returned function emits ReshardCompleted with the
+// outcome, records the operation in the OperationStore and releases the
+// lock.
+func (k *Kine) beginReshard(streamName, operation string, detail map[string]interface{}) (func(err error), error) {
+	unlock, err := k.lockReshard(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	if detail == nil {
+		detail = make(map[string]interface{})
+	}
+	detail["operation"] = operation
+	k.emit(EventReshardStarted, streamName, detail)
+	start := k.clock.Now()
+	record := k.recordOperation(streamName, operation, detail)
+
+	return func(err error) {
+		completed := map[string]interface{}{
+			"operation": operation,
+			"duration":  k.clock.Now().Sub(start).String(),
+		}
+		if err != nil {
+			completed["error"] = err.Error()
+		}
+		k.emit(EventReshardCompleted, streamName, completed)
+		record(err)
+		unlock()
+	}, nil
+}
+
+// EventBridgeEmitter puts the events on the default event bus of the
+// account, with the event type as detail type.
+type EventBridgeEmitter struct {
+	svc    *cloudwatchevents.CloudWatchEvents
+	source string
+}
+
+// NewEventBridgeEmitter returns an emitter putting events with the given
+// source, e.g. "kine" or "com.example.kine", for rules to match on.
+func (k *Kine) NewEventBridgeEmitter(source string) *EventBridgeEmitter {
+	return &EventBridgeEmitter{
+		svc:    cloudwatchevents.New(k.session, k.serviceConfig(cloudwatchevents.EndpointsID)),
+		source: source,
+	}
+}
+
+func (b *EventBridgeEmitter) Emit(e *Event) error {
+	detail, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	out, err := b.svc.PutEvents(&cloudwatchevents.PutEventsInput{
+		Entries: []*cloudwatchevents.PutEventsRequestEntry{{
+			Source:     aws.String(b.source),
+			DetailType: aws.String(e.Type),
+			Detail:     aws.String(string(detail)),
+			Time:       aws.Time(e.Time),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 {
+		entry := out.Entries[0]
+		return fmt.Errorf("%s: %s", aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+	}
+	return nil
+}
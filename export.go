@@ -0,0 +1,164 @@
+package kine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// metricDataQueries is the most queries GetMetricData accepts per call.
+const metricDataQueries = 100
+
+// shardMetricStatistics are the statistics exported for the shard-level
+// metrics: the maximum for the iterator age, the sum for the others.
+var shardMetricStatistics = map[string]string{
+	kinesis.MetricsNameIncomingBytes:                      cloudwatch.StatisticSum,
+	kinesis.MetricsNameIncomingRecords:                    cloudwatch.StatisticSum,
+	kinesis.MetricsNameOutgoingBytes:                      cloudwatch.StatisticSum,
+	kinesis.MetricsNameOutgoingRecords:                    cloudwatch.StatisticSum,
+	kinesis.MetricsNameWriteProvisionedThroughputExceeded: cloudwatch.StatisticSum,
+	kinesis.MetricsNameReadProvisionedThroughputExceeded:  cloudwatch.StatisticSum,
+	kinesis.MetricsNameIteratorAgeMilliseconds:            cloudwatch.StatisticMaximum,
+}
+
+type exportConfig struct {
+	metrics []string
+	period  time.Duration
+}
+
+type ExportOption func(c *exportConfig)
+
+// WithExportMetrics sets the shard-level metrics exported, all of them by
+// default.
+func WithExportMetrics(metricNames ...string) ExportOption {
+	return func(c *exportConfig) {
+		c.metrics = metricNames
+	}
+}
+
+// WithExportPeriod sets the period of the datapoints, one minute by default.
+func WithExportPeriod(d time.Duration) ExportOption {
+	return func(c *exportConfig) {
+		c.period = d
+	}
+}
+
+// ExportShardMetrics writes the shard-level CloudWatch metrics of every
+// shard of the stream between from and to as CSV with the columns shard,
+// timestamp (RFC 3339), metric and value, one row per datapoint sorted by
+// shard, metric and time. The shard-level metrics must be enabled; closed
+// shards are included while they are within the retention period.
+func (k *Kine) ExportShardMetrics(streamName string, w io.Writer, from, to time.Time, opts ...ExportOption) error {
+	c := &exportConfig{
+		metrics: []string{
+			kinesis.MetricsNameIncomingBytes,
+			kinesis.MetricsNameIncomingRecords,
+			kinesis.MetricsNameOutgoingBytes,
+			kinesis.MetricsNameOutgoingRecords,
+			kinesis.MetricsNameWriteProvisionedThroughputExceeded,
+			kinesis.MetricsNameReadProvisionedThroughputExceeded,
+			kinesis.MetricsNameIteratorAgeMilliseconds,
+		},
+		period: time.Minute,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	var shardIDs []string
+	err := k.listShards(streamName, func(page []*kinesis.Shard) error {
+		for _, s := range page {
+			shardIDs = append(shardIDs, *s.ShardId)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(shardIDs)
+
+	type series struct {
+		shardID, metric string
+	}
+	var queries []*cloudwatch.MetricDataQuery
+	index := make(map[string]series)
+	for _, shardID := range shardIDs {
+		for _, metric := range c.metrics {
+			stat, ok := shardMetricStatistics[metric]
+			if !ok {
+				return fmt.Errorf("unknown shard-level metric %q", metric)
+			}
+			id := fmt.Sprintf("m%d", len(queries))
+			index[id] = series{shardID: shardID, metric: metric}
+			queries = append(queries, &cloudwatch.MetricDataQuery{
+				Id: aws.String(id),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String(kinesisNamespace),
+						MetricName: aws.String(metric),
+						Dimensions: []*cloudwatch.Dimension{
+							{Name: aws.String("StreamName"), Value: aws.String(streamName)},
+							{Name: aws.String("ShardId"), Value: aws.String(shardID)},
+						},
+					},
+					Period: aws.Int64(int64(c.period / time.Second)),
+					Stat:   aws.String(stat),
+				},
+			})
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"shard", "timestamp", "metric", "value"})
+	for len(queries) > 0 {
+		batch := queries
+		if len(batch) > metricDataQueries {
+			batch = batch[:metricDataQueries]
+		}
+		queries = queries[len(batch):]
+
+		in := &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: batch,
+			StartTime:         aws.Time(from),
+			EndTime:           aws.Time(to),
+			ScanBy:            aws.String(cloudwatch.ScanByTimestampAscending),
+		}
+		// the results of a batch come in pages, whose order does not follow
+		// the queries, so they are collected before writing
+		points := make(map[string][][2]string)
+		for {
+			out, err := k.cw.GetMetricData(in)
+			if err != nil {
+				return fmt.Errorf("%s: GetMetricData: %w", streamName, err)
+			}
+			for _, r := range out.MetricDataResults {
+				for i, t := range r.Timestamps {
+					points[*r.Id] = append(points[*r.Id], [2]string{
+						t.UTC().Format(time.RFC3339),
+						strconv.FormatFloat(*r.Values[i], 'f', -1, 64),
+					})
+				}
+			}
+			if out.NextToken == nil {
+				break
+			}
+			in.NextToken = out.NextToken
+		}
+
+		for _, q := range batch {
+			s := index[*q.Id]
+			for _, p := range points[*q.Id] {
+				cw.Write([]string{s.shardID, p[0], s.metric, p[1]})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,448 @@
+package kine_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/hashrange"
+	"github.com/ingtk/kine/kinetest"
+)
+
+// fakeAWS is an in-memory Kinesis, serving the streams of the tests, and a
+// DynamoDB table holding the leases of the consumers.
+type fakeAWS struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	streams map[string][]*fakeShard
+	seq     int
+	// items are the lease table items by stream and shard
+	items map[string]map[string]attributes
+	// calls counts the calls of each action
+	calls map[string]int
+}
+
+type fakeShard struct {
+	id      string
+	hash    hashrange.Range
+	parent  string
+	closed  bool
+	records []fakeRecord
+}
+
+type fakeRecord struct {
+	seq     string
+	key     string
+	data    []byte
+	arrival time.Time
+}
+
+type attribute struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+}
+
+type attributes map[string]attribute
+
+// newFakeAWS starts the fake, and returns it with a client reaching it on
+// the fake clock.
+func newFakeAWS(t testing.TB, clock *kinetest.FakeClock, opts ...kine.KineOption) (*fakeAWS, *kine.Kine) {
+	f := &fakeAWS{
+		t:       t,
+		streams: make(map[string][]*fakeShard),
+		items:   make(map[string]map[string]attributes),
+		calls:   make(map[string]int),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	k, err := kine.New(append([]kine.KineOption{
+		kine.WithRegion("us-east-1"),
+		kine.WithEndpoint(f.srv.URL),
+		kine.WithServiceEndpoints(map[string]string{"dynamodb": f.srv.URL}),
+		kine.WithClock(clock),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, k
+}
+
+// createStream adds a stream of n shards sharing the hash space evenly.
+func (f *fakeAWS) createStream(name string, n int) {
+	ranges, err := hashrange.Full.Divide(n)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	shards := make([]*fakeShard, n)
+	for i, r := range ranges {
+		shards[i] = &fakeShard{id: fmt.Sprintf("shardId-%012d", i), hash: r}
+	}
+	f.streams[name] = shards
+}
+
+// callCount returns the number of calls of the action.
+func (f *fakeAWS) callCount(action string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[action]
+}
+
+// split closes the shard and adds its two halves.
+func (f *fakeAWS) split(streamName, shardID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.shard(streamName, shardID)
+	lower, upper, err := s.hash.SplitAt(0.5)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	s.closed = true
+	for _, r := range []hashrange.Range{lower, upper} {
+		f.streams[streamName] = append(f.streams[streamName], &fakeShard{
+			id:     fmt.Sprintf("shardId-%012d", len(f.streams[streamName])),
+			hash:   r,
+			parent: shardID,
+		})
+	}
+}
+
+// sequenceNumber returns the sequence number of the i-th record of a shard.
+func (f *fakeAWS) sequenceNumber(streamName, shardID string, i int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.shard(streamName, shardID).records[i].seq
+}
+
+// put appends a record to each open shard of the stream.
+func (f *fakeAWS) put(streamName string, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.streams[streamName] {
+		if s.closed {
+			continue
+		}
+		f.seq++
+		s.records = append(s.records, fakeRecord{
+			seq:     fmt.Sprintf("%056d", f.seq),
+			key:     s.id,
+			data:    []byte(data),
+			arrival: time.Unix(0, 0),
+		})
+	}
+}
+
+// owners returns the owner of the lease of each shard of the stream.
+func (f *fakeAWS) owners(streamName string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	owners := make(map[string]string)
+	for id, item := range f.items[streamName] {
+		owners[id] = item["Owner"].S
+	}
+	return owners
+}
+
+func (f *fakeAWS) serve(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	action := target[strings.Index(target, ".")+1:]
+	var in map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		f.t.Errorf("%s: %v", target, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[action]++
+
+	var out interface{}
+	var errType string
+	switch action {
+	case "DescribeStreamSummary":
+		out, errType = f.describeStreamSummary(in)
+	case "ListShards":
+		out, errType = f.listShards(in)
+	case "GetShardIterator":
+		out, errType = f.getShardIterator(in)
+	case "GetRecords":
+		out, errType = f.getRecords(in)
+	case "Query":
+		out = f.query(in)
+	case "UpdateItem":
+		out, errType = f.updateItem(in)
+	default:
+		f.t.Errorf("unexpected call to %s", target)
+		errType = "UnknownOperationException"
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if errType != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "com.amazonaws#" + errType,
+			"message": action + " failed",
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func decode(in map[string]json.RawMessage, name string) string {
+	var s string
+	json.Unmarshal(in[name], &s)
+	return s
+}
+
+func (f *fakeAWS) describeStreamSummary(in map[string]json.RawMessage) (interface{}, string) {
+	name := decode(in, "StreamName")
+	shards, ok := f.streams[name]
+	if !ok {
+		return nil, "ResourceNotFoundException"
+	}
+	return map[string]interface{}{
+		"StreamDescriptionSummary": map[string]interface{}{
+			"StreamName":              name,
+			"StreamARN":               "arn:aws:kinesis:us-east-1:123456789012:stream/" + name,
+			"StreamStatus":            "ACTIVE",
+			"OpenShardCount":          len(shards),
+			"RetentionPeriodHours":    24,
+			"StreamCreationTimestamp": 0,
+			"EnhancedMonitoring":      []interface{}{},
+		},
+	}, ""
+}
+
+// listShards returns pages of MaxResults shards, the token of the next page
+// being "stream/index".
+func (f *fakeAWS) listShards(in map[string]json.RawMessage) (interface{}, string) {
+	name, next := decode(in, "StreamName"), 0
+	if token := decode(in, "NextToken"); token != "" {
+		if name != "" {
+			return nil, "InvalidArgumentException"
+		}
+		i := strings.LastIndex(token, "/")
+		name = token[:i]
+		next, _ = strconv.Atoi(token[i+1:])
+	}
+	shards, ok := f.streams[name]
+	if !ok {
+		return nil, "ResourceNotFoundException"
+	}
+	var max int
+	json.Unmarshal(in["MaxResults"], &max)
+	page := shards[next:]
+	if max > 0 && max < len(page) {
+		page = page[:max]
+	}
+
+	list := make([]interface{}, 0, len(page))
+	for _, s := range page {
+		shard := map[string]interface{}{
+			"ShardId": s.id,
+			"HashKeyRange": map[string]string{
+				"StartingHashKey": s.hash.Start.String(),
+				"EndingHashKey":   s.hash.End.String(),
+			},
+		}
+		sequenceNumbers := map[string]string{"StartingSequenceNumber": "0"}
+		if s.closed {
+			sequenceNumbers["EndingSequenceNumber"] = fmt.Sprintf("%056d", f.seq)
+		}
+		shard["SequenceNumberRange"] = sequenceNumbers
+		if s.parent != "" {
+			shard["ParentShardId"] = s.parent
+		}
+		list = append(list, shard)
+	}
+	out := map[string]interface{}{"Shards": list}
+	if next+len(page) < len(shards) {
+		out["NextToken"] = fmt.Sprintf("%s/%d", name, next+len(page))
+	}
+	return out, ""
+}
+
+func (f *fakeAWS) shard(streamName, shardID string) *fakeShard {
+	for _, s := range f.streams[streamName] {
+		if s.id == shardID {
+			return s
+		}
+	}
+	return nil
+}
+
+// getShardIterator returns an iterator "stream/shard/index" of the next
+// record to read.
+func (f *fakeAWS) getShardIterator(in map[string]json.RawMessage) (interface{}, string) {
+	streamName := decode(in, "StreamName")
+	s := f.shard(streamName, decode(in, "ShardId"))
+	if s == nil {
+		return nil, "ResourceNotFoundException"
+	}
+
+	var next int
+	switch decode(in, "ShardIteratorType") {
+	case "TRIM_HORIZON":
+	case "LATEST":
+		next = len(s.records)
+	case "AFTER_SEQUENCE_NUMBER", "AT_SEQUENCE_NUMBER":
+		seq := decode(in, "StartingSequenceNumber")
+		for next < len(s.records) && s.records[next].seq < seq {
+			next++
+		}
+		if next < len(s.records) && s.records[next].seq == seq && decode(in, "ShardIteratorType") == "AFTER_SEQUENCE_NUMBER" {
+			next++
+		}
+	default:
+		return nil, "InvalidArgumentException"
+	}
+	return map[string]string{
+		"ShardIterator": fmt.Sprintf("%s/%s/%d", streamName, s.id, next),
+	}, ""
+}
+
+func (f *fakeAWS) getRecords(in map[string]json.RawMessage) (interface{}, string) {
+	parts := strings.Split(decode(in, "ShardIterator"), "/")
+	if len(parts) != 3 {
+		return nil, "InvalidArgumentException"
+	}
+	s := f.shard(parts[0], parts[1])
+	next, err := strconv.Atoi(parts[2])
+	if s == nil || err != nil {
+		return nil, "InvalidArgumentException"
+	}
+
+	records := make([]interface{}, 0, len(s.records)-next)
+	for _, r := range s.records[next:] {
+		records = append(records, map[string]interface{}{
+			"SequenceNumber":              r.seq,
+			"PartitionKey":                r.key,
+			"Data":                        base64.StdEncoding.EncodeToString(r.data),
+			"ApproximateArrivalTimestamp": r.arrival.Unix(),
+		})
+	}
+	out := map[string]interface{}{
+		"Records":            records,
+		"MillisBehindLatest": 0,
+	}
+	// a closed shard read to its end has no next iterator
+	if !s.closed {
+		out["NextShardIterator"] = fmt.Sprintf("%s/%s/%d", parts[0], parts[1], len(s.records))
+	}
+	return out, ""
+}
+
+func (f *fakeAWS) query(in map[string]json.RawMessage) interface{} {
+	var values attributes
+	json.Unmarshal(in["ExpressionAttributeValues"], &values)
+	items := make([]attributes, 0)
+	for _, item := range f.items[values[":stream"].S] {
+		items = append(items, item)
+	}
+	return map[string]interface{}{"Items": items, "Count": len(items)}
+}
+
+// updateItem runs the updates of the lease table, which the fake knows by
+// their expressions.
+func (f *fakeAWS) updateItem(in map[string]json.RawMessage) (interface{}, string) {
+	var key, values attributes
+	json.Unmarshal(in["Key"], &key)
+	json.Unmarshal(in["ExpressionAttributeValues"], &values)
+
+	stream, shardID := key["StreamName"].S, key["ShardID"].S
+	if f.items[stream] == nil {
+		f.items[stream] = make(map[string]attributes)
+	}
+	item, exists := f.items[stream][shardID]
+	if !exists {
+		item = attributes{"StreamName": key["StreamName"], "ShardID": key["ShardID"]}
+	}
+	owner, owned := item["Owner"]
+
+	var ok bool
+	switch condition := decode(in, "ConditionExpression"); condition {
+	case "":
+		ok = true
+	case "attribute_not_exists(#owner) OR #owner = :me OR Expires < :now":
+		expires, _ := strconv.ParseInt(item["Expires"].N, 10, 64)
+		now, _ := strconv.ParseInt(values[":now"].N, 10, 64)
+		ok = !owned || owner.S == values[":me"].S || expires < now
+	case "#owner = :owner":
+		ok = owned && owner.S == values[":owner"].S
+	case "#owner = :me":
+		ok = owned && owner.S == values[":me"].S
+	default:
+		f.t.Errorf("unexpected condition %q", condition)
+	}
+	if !ok {
+		return nil, "ConditionalCheckFailedException"
+	}
+
+	switch update := decode(in, "UpdateExpression"); update {
+	case "SET #owner = :me, Expires = :expires REMOVE Handoff":
+		item["Owner"] = values[":me"]
+		item["Expires"] = values[":expires"]
+		delete(item, "Handoff")
+	case "SET Expires = :expires":
+		item["Expires"] = values[":expires"]
+	case "SET Handoff = :me":
+		item["Handoff"] = values[":me"]
+	case "REMOVE #owner, Expires, Handoff":
+		delete(item, "Owner")
+		delete(item, "Expires")
+		delete(item, "Handoff")
+	default:
+		f.t.Errorf("unexpected update %q", update)
+	}
+	f.items[stream][shardID] = item
+	return map[string]interface{}{}, ""
+}
+
+// runClock advances the clock until the test ends, so that the code under
+// test never waits for long.
+func runClock(t testing.TB, clock *kinetest.FakeClock, step time.Duration) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				clock.Advance(step)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+}
+
+// eventually fails the test when cond is not met within 10 seconds.
+func eventually(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
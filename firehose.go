@@ -0,0 +1,133 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// FirehoseConfig describes a Firehose delivery stream archiving a Kinesis
+// stream to S3.
+type FirehoseConfig struct {
+	DeliveryStreamName string
+
+	// RoleARN is the IAM role Firehose assumes both to read the Kinesis
+	// stream and to write to the bucket.
+	RoleARN   string
+	BucketARN string
+	Prefix    string
+
+	// BufferSizeMB and BufferInterval use the Firehose defaults when zero.
+	BufferSizeMB   int
+	BufferInterval time.Duration
+
+	// Compression is one of the firehose.CompressionFormat* values,
+	// UNCOMPRESSED when empty.
+	Compression string
+}
+
+// DescribeFirehose returns the delivery stream.
+func (k *Kine) DescribeFirehose(deliveryStreamName string) (*firehose.DeliveryStreamDescription, error) {
+	out, err := k.firehose.DescribeDeliveryStream(&firehose.DescribeDeliveryStreamInput{
+		DeliveryStreamName: aws.String(deliveryStreamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.DeliveryStreamDescription, nil
+}
+
+// EnsureFirehose creates a delivery stream reading from the Kinesis stream and
+// writing to S3, and waits until it is active. An existing delivery stream is
+// returned as is when its source is the stream, and is an error otherwise.
+func (k *Kine) EnsureFirehose(streamName string, c FirehoseConfig) (*firehose.DeliveryStreamDescription, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	streamARN := *out.StreamDescriptionSummary.StreamARN
+
+	d, err := k.DescribeFirehose(c.DeliveryStreamName)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == firehose.ErrCodeResourceNotFoundException {
+		err = k.createFirehose(streamARN, c)
+		if err != nil {
+			return nil, err
+		}
+		return k.waitUntilFirehoseActive(c.DeliveryStreamName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source := ""
+	if d.Source != nil && d.Source.KinesisStreamSourceDescription != nil {
+		source = aws.StringValue(d.Source.KinesisStreamSourceDescription.KinesisStreamARN)
+	}
+	if source != streamARN {
+		return nil, fmt.Errorf("delivery stream %s does not read from %s", c.DeliveryStreamName, streamName)
+	}
+	if *d.DeliveryStreamStatus == firehose.DeliveryStreamStatusCreating {
+		return k.waitUntilFirehoseActive(c.DeliveryStreamName)
+	}
+	return d, nil
+}
+
+func (k *Kine) createFirehose(streamARN string, c FirehoseConfig) error {
+	dest := &firehose.ExtendedS3DestinationConfiguration{
+		BucketARN: aws.String(c.BucketARN),
+		RoleARN:   aws.String(c.RoleARN),
+	}
+	if c.Prefix != "" {
+		dest.Prefix = aws.String(c.Prefix)
+	}
+	if c.Compression != "" {
+		dest.CompressionFormat = aws.String(c.Compression)
+	}
+	if c.BufferSizeMB > 0 || c.BufferInterval > 0 {
+		dest.BufferingHints = &firehose.BufferingHints{}
+		if c.BufferSizeMB > 0 {
+			dest.BufferingHints.SizeInMBs = aws.Int64(int64(c.BufferSizeMB))
+		}
+		if c.BufferInterval > 0 {
+			dest.BufferingHints.IntervalInSeconds = aws.Int64(int64(c.BufferInterval / time.Second))
+		}
+	}
+
+	_, err := k.firehose.CreateDeliveryStream(&firehose.CreateDeliveryStreamInput{
+		DeliveryStreamName: aws.String(c.DeliveryStreamName),
+		DeliveryStreamType: aws.String(firehose.DeliveryStreamTypeKinesisStreamAsSource),
+		KinesisStreamSourceConfiguration: &firehose.KinesisStreamSourceConfiguration{
+			KinesisStreamARN: aws.String(streamARN),
+			RoleARN:          aws.String(c.RoleARN),
+		},
+		ExtendedS3DestinationConfiguration: dest,
+	})
+	return err
+}
+
+func (k *Kine) waitUntilFirehoseActive(deliveryStreamName string) (*firehose.DeliveryStreamDescription, error) {
+	var d *firehose.DeliveryStreamDescription
+	err := k.poll(context.Background(), nil, func() (bool, error) {
+		var err error
+		d, err = k.DescribeFirehose(deliveryStreamName)
+		if err != nil {
+			return false, err
+		}
+		switch *d.DeliveryStreamStatus {
+		case firehose.DeliveryStreamStatusActive:
+			return true, nil
+		case firehose.DeliveryStreamStatusCreating:
+			return false, nil
+		}
+		return false, fmt.Errorf("delivery stream %s is %s", deliveryStreamName, *d.DeliveryStreamStatus)
+	})
+	return d, err
+}
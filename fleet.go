@@ -0,0 +1,131 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Fleet holds Kine clients for several regions (and accounts), so that one
+// tool instance can manage streams across deployments.
+// Members are identified by a key: the region for AddRegion, and
+// "account/region" for AddAccount.
+type Fleet struct {
+	mu      sync.RWMutex
+	members map[string]*Kine
+	base    *Kine
+}
+
+func NewFleet() *Fleet {
+	return &Fleet{
+		members: make(map[string]*Kine),
+	}
+}
+
+// NewFleetFrom returns a fleet whose members share the session of base,
+// see NewFromKine.
+func NewFleetFrom(base *Kine) *Fleet {
+	f := NewFleet()
+	f.base = base
+	return f
+}
+
+// Add registers a client built from opts under key.
+func (f *Fleet) Add(key string, opts ...KineOption) error {
+	var k *Kine
+	var err error
+	if f.base != nil {
+		k, err = NewFromKine(f.base, opts...)
+	} else {
+		k, err = New(opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.members[key]; ok {
+		return fmt.Errorf("fleet member %q already exists", key)
+	}
+	f.members[key] = k
+	return nil
+}
+
+// AddRegion registers a client for region, keyed by the region name.
+func (f *Fleet) AddRegion(region string, opts ...KineOption) error {
+	return f.Add(region, append([]KineOption{WithRegion(region)}, opts...)...)
+}
+
+// AddAccount registers a client for region which assumes roleARN,
+// keyed by "account/region".
+func (f *Fleet) AddAccount(account, region, roleARN string, opts ...KineOption) error {
+	return f.Add(account+"/"+region, append([]KineOption{WithRegion(region), WithAssumeRole(roleARN)}, opts...)...)
+}
+
+// Client returns the client registered under key.
+func (f *Fleet) Client(key string) (*Kine, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	k, ok := f.members[key]
+	if !ok {
+		return nil, fmt.Errorf("fleet member %q not found", key)
+	}
+	return k, nil
+}
+
+// Keys returns the keys of all members, sorted.
+func (f *Fleet) Keys() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	keys := make([]string, 0, len(f.members))
+	for key := range f.members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *Fleet) View(key, streamName string) error {
+	k, err := f.Client(key)
+	if err != nil {
+		return err
+	}
+	return k.View(streamName)
+}
+
+// ListStreams lists the streams of every member concurrently, keyed by member.
+// Members which fail are reported together as a *BatchError.
+func (f *Fleet) ListStreams() (map[string][]string, error) {
+	var mu sync.Mutex
+	streams := make(map[string][]string)
+	errs := make(map[string]error)
+
+	var wg sync.WaitGroup
+	for _, key := range f.Keys() {
+		k, err := f.Client(key)
+		if err != nil {
+			return nil, err
+		}
+
+		wg.Add(1)
+		go func(key string, k *Kine) {
+			defer wg.Done()
+			names, err := k.ListStreams()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[key] = err
+				return
+			}
+			streams[key] = names
+		}(key, k)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return streams, &BatchError{Errors: errs}
+	}
+	return streams, nil
+}
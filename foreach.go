@@ -0,0 +1,243 @@
+package kine
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+type forEachConfig struct {
+	regexp      bool
+	tags        map[string]string
+	concurrency int
+}
+
+type ForEachOption func(c *forEachConfig)
+
+// WithRegexp makes the pattern be treated as a regular expression instead of a glob.
+func WithRegexp() ForEachOption {
+	return func(c *forEachConfig) {
+		c.regexp = true
+	}
+}
+
+// WithTag restricts the streams to the ones tagged with key=value.
+func WithTag(key, value string) ForEachOption {
+	return func(c *forEachConfig) {
+		if c.tags == nil {
+			c.tags = make(map[string]string)
+		}
+		c.tags[key] = value
+	}
+}
+
+// WithConcurrency sets how many streams are processed at the same time.
+func WithConcurrency(n int) ForEachOption {
+	return func(c *forEachConfig) {
+		c.concurrency = n
+	}
+}
+
+// BatchError holds the errors of a batch operation, keyed by stream
+// (or fleet member, or monitor condition) name.
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("%d failed: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// ListStreams returns the names of all streams in the account and region.
+func (k *Kine) ListStreams() ([]string, error) {
+	var names []string
+	var startStreamName *string
+
+	for {
+		out, err := k.svc.ListStreams(&kinesis.ListStreamsInput{
+			ExclusiveStartStreamName: startStreamName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, aws.StringValueSlice(out.StreamNames)...)
+
+		if !*out.HasMoreStreams || len(out.StreamNames) == 0 {
+			break
+		}
+		startStreamName = out.StreamNames[len(out.StreamNames)-1]
+	}
+
+	return names, nil
+}
+
+func (k *Kine) listTags(streamName string) (map[string]string, error) {
+	tags := make(map[string]string)
+	var startTagKey *string
+
+	for {
+		out, err := k.svc.ListTagsForStream(&kinesis.ListTagsForStreamInput{
+			ExclusiveStartTagKey: startTagKey,
+			StreamName:           aws.String(streamName),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range out.Tags {
+			tags[*t.Key] = aws.StringValue(t.Value)
+		}
+
+		if !*out.HasMoreTags || len(out.Tags) == 0 {
+			break
+		}
+		startTagKey = out.Tags[len(out.Tags)-1].Key
+	}
+
+	return tags, nil
+}
+
+// MatchStreams returns the names of the streams matching the glob pattern
+// (or regular expression with WithRegexp) and tag filters.
+// An empty pattern matches every stream.
+func (k *Kine) MatchStreams(pattern string, opts ...ForEachOption) ([]string, error) {
+	c := &forEachConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+	return k.matchStreams(pattern, c)
+}
+
+func (k *Kine) matchStreams(pattern string, c *forEachConfig) ([]string, error) {
+
+	match := func(name string) (bool, error) {
+		if pattern == "" {
+			return true, nil
+		}
+		return path.Match(pattern, name)
+	}
+	if c.regexp {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		match = func(name string) (bool, error) {
+			return re.MatchString(name), nil
+		}
+	}
+
+	names, err := k.ListStreams()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]string, 0, len(names))
+	for _, name := range names {
+		ok, err := match(name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if len(c.tags) > 0 {
+			tags, err := k.listTags(name)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range c.tags {
+				if v, found := tags[key]; !found || v != value {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		matched = append(matched, name)
+	}
+
+	return matched, nil
+}
+
+// ForEachStream calls fn for every stream matching the pattern.
+// All streams are processed even if some of them fail; the failures are
+// returned together as a *BatchError.
+func (k *Kine) ForEachStream(pattern string, fn func(stream string) error, opts ...ForEachOption) error {
+	c := &forEachConfig{concurrency: 1}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+
+	names, err := k.matchStreams(pattern, c)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.concurrency)
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(name); err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// ViewStreams runs View on every stream matching the pattern.
+// Tables are printed one stream at a time even when processed concurrently.
+func (k *Kine) ViewStreams(pattern string, opts ...ForEachOption) error {
+	return k.ForEachStream(pattern, func(stream string) error {
+		return printStdout(func(w io.Writer) error {
+			fmt.Fprintf(w, "%s\n", stream)
+			return k.view(stream, w)
+		})
+	}, opts...)
+}
+
+// ScaleStreamsTo runs ScaleTo on every stream matching the pattern.
+func (k *Kine) ScaleStreamsTo(pattern string, shardCount int, opts ...ForEachOption) error {
+	return k.ForEachStream(pattern, func(stream string) error {
+		return k.ScaleTo(stream, shardCount)
+	}, opts...)
+}
@@ -3,10 +3,12 @@ module github.com/ingtk/kine
 require (
 	github.com/aws/aws-sdk-go v1.16.15
 	github.com/ingtk/stimulus v0.0.0-20181010131900-592370b6904f
+	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af
 	github.com/k0kubun/pp v2.3.0+incompatible
 	github.com/mattn/go-colorable v0.0.9 // indirect
-	github.com/mattn/go-isatty v0.0.4 // indirect
+	github.com/mattn/go-isatty v0.0.4
 	github.com/mattn/go-runewidth v0.0.4 // indirect
 	github.com/olekukonko/tablewriter v0.0.1
 	golang.org/x/sys v0.0.0-20190107173414-20be8e55dc7b // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,111 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LagGuard keeps kine from resharding a stream while its consumers are far
+// behind, which makes recovering the record order painful for them.
+type LagGuard struct {
+	// MaxLag is the largest iterator age, as reported by ConsumerLag,
+	// at which resharding is still allowed.
+	MaxLag time.Duration
+	// Consumers are the enhanced fan-out consumers to check; the polling
+	// consumers are checked when empty.
+	Consumers []string
+	// Wait is how long to wait for the consumers to catch up before giving
+	// up; kine refuses to reshard right away when zero.
+	Wait time.Duration
+}
+
+// LagError is returned when resharding is refused because of a LagGuard.
+type LagError struct {
+	StreamName   string
+	ConsumerName string
+	Lag          time.Duration
+	MaxLag       time.Duration
+}
+
+func (e *LagError) Error() string {
+	consumer := "polling consumers"
+	if e.ConsumerName != "" {
+		consumer = "consumer " + e.ConsumerName
+	}
+	return fmt.Sprintf("%s: %s %s behind, more than %s", e.StreamName, consumer, e.Lag, e.MaxLag)
+}
+
+// WithLagGuard makes Apply, ScaleTo, SplitShardAt, HalveShard and
+// DoubleShard check the consumer lag first and fail with a *LagError when
+// it stays above the guard's MaxLag.
+func WithLagGuard(g LagGuard) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.lagGuard = &g
+		return nil
+	})
+}
+
+// WithForce reshards regardless of the LagGuard.
+func WithForce() KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.force = true
+		return nil
+	})
+}
+
+// checkReshard runs the pre-flight checks of a resharding operation.
+func (k *Kine) checkReshard(streamName, operation string) error {
+	if err := k.checkChangeWindow(streamName, operation); err != nil {
+		return err
+	}
+	return k.checkLag(streamName)
+}
+
+func (k *Kine) checkLag(streamName string) error {
+	g := k.lagGuard
+	if g == nil || k.force {
+		return nil
+	}
+
+	deadline := k.clock.Now().Add(g.Wait)
+	var lagErr *LagError
+	err := k.poll(context.Background(), nil, func() (bool, error) {
+		var err error
+		lagErr, err = k.lagging(streamName, g)
+		if err != nil {
+			return false, err
+		}
+		return lagErr == nil || !k.clock.Now().Before(deadline), nil
+	})
+	if err != nil {
+		return err
+	}
+	if lagErr != nil {
+		return lagErr
+	}
+	return nil
+}
+
+// lagging returns the first consumer lagging behind more than the guard allows.
+func (k *Kine) lagging(streamName string, g *LagGuard) (*LagError, error) {
+	consumers := g.Consumers
+	if len(consumers) == 0 {
+		consumers = []string{""}
+	}
+	for _, name := range consumers {
+		lag, err := k.ConsumerLag(streamName, name)
+		if err != nil {
+			return nil, err
+		}
+		if lag.Latest > g.MaxLag {
+			return &LagError{
+				StreamName:   streamName,
+				ConsumerName: name,
+				Lag:          lag.Latest,
+				MaxLag:       g.MaxLag,
+			}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,171 @@
+// Package hashrange provides fixed 128-bit unsigned arithmetic for the
+// hash key ranges of Kinesis shards.
+package hashrange
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Key is a 128-bit unsigned hash key.
+type Key struct {
+	Hi, Lo uint64
+}
+
+var (
+	// MinKey and MaxKey are the bounds of the Kinesis hash key space.
+	MinKey = Key{}
+	MaxKey = Key{Hi: math.MaxUint64, Lo: math.MaxUint64}
+)
+
+var (
+	ErrSyntax   = errors.New("hashrange: invalid hash key syntax")
+	ErrOverflow = errors.New("hashrange: hash key overflows 128 bits")
+)
+
+// KeyError is returned by ParseKey and Parse for a malformed hash key.
+// Err is ErrSyntax or ErrOverflow.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%v: %q", e.Err, e.Key)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// ParseKey parses a decimal hash key, which must only consist of digits
+// and fit in 128 bits.
+func ParseKey(s string) (Key, error) {
+	if s == "" {
+		return Key{}, &KeyError{Key: s, Err: ErrSyntax}
+	}
+
+	var k Key
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return Key{}, &KeyError{Key: s, Err: ErrSyntax}
+		}
+		var overflow bool
+		k, overflow = k.mulAdd(10, uint64(c-'0'))
+		if overflow {
+			return Key{}, &KeyError{Key: s, Err: ErrOverflow}
+		}
+	}
+	return k, nil
+}
+
+// mulAdd returns k*m+a and whether it overflowed.
+func (k Key) mulAdd(m, a uint64) (Key, bool) {
+	hiHi, hi := bits.Mul64(k.Hi, m)
+	loHi, lo := bits.Mul64(k.Lo, m)
+	hi, carry := bits.Add64(hi, loHi, 0)
+	overflow := hiHi != 0 || carry != 0
+
+	lo, carry = bits.Add64(lo, a, 0)
+	hi, carry = bits.Add64(hi, 0, carry)
+	return Key{Hi: hi, Lo: lo}, overflow || carry != 0
+}
+
+// String formats the key in decimal, the format used by the Kinesis API.
+func (k Key) String() string {
+	if k.IsZero() {
+		return "0"
+	}
+
+	var buf [40]byte
+	i := len(buf)
+	for !k.IsZero() {
+		var r uint64
+		k, r = k.DivUint64(10)
+		i--
+		buf[i] = byte('0' + r)
+	}
+	return string(buf[i:])
+}
+
+func (k Key) IsZero() bool {
+	return k.Hi == 0 && k.Lo == 0
+}
+
+// Cmp returns -1, 0 or +1 depending on whether k is less than, equal to or
+// greater than o.
+func (k Key) Cmp(o Key) int {
+	switch {
+	case k.Hi < o.Hi:
+		return -1
+	case k.Hi > o.Hi:
+		return 1
+	case k.Lo < o.Lo:
+		return -1
+	case k.Lo > o.Lo:
+		return 1
+	}
+	return 0
+}
+
+// Add returns k+o, wrapping around on overflow.
+func (k Key) Add(o Key) Key {
+	lo, carry := bits.Add64(k.Lo, o.Lo, 0)
+	hi, _ := bits.Add64(k.Hi, o.Hi, carry)
+	return Key{Hi: hi, Lo: lo}
+}
+
+// Sub returns k-o, wrapping around on underflow.
+func (k Key) Sub(o Key) Key {
+	lo, borrow := bits.Sub64(k.Lo, o.Lo, 0)
+	hi, _ := bits.Sub64(k.Hi, o.Hi, borrow)
+	return Key{Hi: hi, Lo: lo}
+}
+
+// Next returns k+1.
+func (k Key) Next() Key {
+	return k.Add(Key{Lo: 1})
+}
+
+// Prev returns k-1.
+func (k Key) Prev() Key {
+	return k.Sub(Key{Lo: 1})
+}
+
+// DivUint64 returns k/d and k%d. It panics when d is zero.
+func (k Key) DivUint64(d uint64) (Key, uint64) {
+	hi, r := bits.Div64(0, k.Hi, d)
+	lo, r := bits.Div64(r, k.Lo, d)
+	return Key{Hi: hi, Lo: lo}, r
+}
+
+// MulUint64 returns k*m, wrapping around on overflow.
+func (k Key) MulUint64(m uint64) Key {
+	r, _ := k.mulAdd(m, 0)
+	return r
+}
+
+// MulFrac returns floor(k*f) for 0 <= f <= 1, using a 64-bit fixed-point
+// fraction so the result is exact to within one part in 2^53.
+func (k Key) MulFrac(f float64) Key {
+	if f <= 0 {
+		return Key{}
+	}
+	if f >= 1 {
+		return k
+	}
+
+	frac := uint64(f * (1 << 64))
+	// (Hi*2^64 + Lo) * frac / 2^64 = Hi*frac + (Lo*frac)>>64
+	hiHi, hiLo := bits.Mul64(k.Hi, frac)
+	loHi, _ := bits.Mul64(k.Lo, frac)
+	return Key{Hi: hiHi, Lo: hiLo}.Add(Key{Lo: loHi})
+}
+
+// Float64 returns the key as a floating point number.
+func (k Key) Float64() float64 {
+	return float64(k.Hi)*(1<<64) + float64(k.Lo)
+}
@@ -0,0 +1,96 @@
+package hashrange
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// benchmarkShards is the shard count of the topologies benchmarked.
+const benchmarkShards = 512
+
+func benchmarkKeys(b *testing.B) []string {
+	ranges, err := Full.Divide(benchmarkShards)
+	if err != nil {
+		b.Fatal(err)
+	}
+	keys := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		keys = append(keys, r.End.String())
+	}
+	return keys
+}
+
+func BenchmarkParseKey(b *testing.B) {
+	keys := benchmarkKeys(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseKey(keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKeyString(b *testing.B) {
+	k := MaxKey
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = k.String()
+	}
+}
+
+func BenchmarkKeyCmp(b *testing.B) {
+	ranges, err := Full.Divide(benchmarkShards)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := ranges[i%len(ranges)]
+		if r.Start.Cmp(r.End) > 0 {
+			b.Fatal("empty range")
+		}
+	}
+}
+
+func BenchmarkSplitPoint(b *testing.B) {
+	r := Range{Start: MinKey, End: MaxKey}
+	for i := 0; i < b.N; i++ {
+		if _, err := r.SplitPoint(0.3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDivide(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Full.Divide(benchmarkShards); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzParseKey(f *testing.F) {
+	for _, s := range []string{"0", "007", "340282366920938463463374607431768211455", "340282366920938463463374607431768211456", "-1", "1e10", " 1", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		k, err := ParseKey(s)
+		if err != nil {
+			var keyErr *KeyError
+			if !errors.As(err, &keyErr) || !(errors.Is(err, ErrSyntax) || errors.Is(err, ErrOverflow)) {
+				t.Fatalf("ParseKey(%q): unexpected error %v", s, err)
+			}
+			return
+		}
+		want := strings.TrimLeft(s, "0")
+		if want == "" {
+			want = "0"
+		}
+		if k.String() != want {
+			t.Fatalf("ParseKey(%q) = %s", s, k)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package hashrange
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Range is an inclusive range of hash keys, as in a shard's HashKeyRange.
+type Range struct {
+	Start, End Key
+}
+
+// Full is the whole Kinesis hash key space.
+var Full = Range{Start: MinKey, End: MaxKey}
+
+var (
+	ErrEmptyRange   = errors.New("hashrange: starting hash key is greater than ending hash key")
+	ErrInvalidRatio = errors.New("hashrange: split ratio must leave both sides non-empty")
+	ErrNotAdjacent  = errors.New("hashrange: ranges are not adjacent")
+)
+
+// RangeError is returned by Parse when the starting hash key is greater
+// than the ending one. Err is ErrEmptyRange.
+type RangeError struct {
+	Range Range
+	Err   error
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Range)
+}
+
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
+// Parse parses the decimal starting and ending hash keys of a range,
+// returning a *KeyError or *RangeError when they are invalid.
+func Parse(start, end string) (Range, error) {
+	s, err := ParseKey(start)
+	if err != nil {
+		return Range{}, err
+	}
+	e, err := ParseKey(end)
+	if err != nil {
+		return Range{}, err
+	}
+	if s.Cmp(e) > 0 {
+		return Range{}, &RangeError{Range: Range{Start: s, End: e}, Err: ErrEmptyRange}
+	}
+	return Range{Start: s, End: e}, nil
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("[%s, %s]", r.Start, r.End)
+}
+
+// Contains reports whether k is in the range.
+func (r Range) Contains(k Key) bool {
+	return r.Start.Cmp(k) <= 0 && k.Cmp(r.End) <= 0
+}
+
+// Width returns End-Start, i.e. the number of keys in the range minus one,
+// so that the width of Full still fits in 128 bits.
+func (r Range) Width() Key {
+	return r.End.Sub(r.Start)
+}
+
+// Share returns the fraction of the whole hash key space covered by the range.
+func (r Range) Share() float64 {
+	return (r.Width().Float64() + 1) / Full.Width().Float64()
+}
+
+// Adjacent reports whether o starts right after r ends.
+func (r Range) Adjacent(o Range) bool {
+	return r.End != MaxKey && r.End.Next() == o.Start
+}
+
+// Merge returns the union of r and the adjacent range o.
+func (r Range) Merge(o Range) (Range, error) {
+	if !r.Adjacent(o) {
+		return Range{}, ErrNotAdjacent
+	}
+	return Range{Start: r.Start, End: o.End}, nil
+}
+
+// SplitPoint returns the starting hash key of the upper child when the
+// range is split so that the lower child gets ratio of it.
+// A ratio of 0.5 gives the midpoint (Start+End)/2 used by SplitShard.
+func (r Range) SplitPoint(ratio float64) (Key, error) {
+	if ratio <= 0 || ratio >= 1 {
+		return Key{}, ErrInvalidRatio
+	}
+	p := r.Start.Add(r.Width().MulFrac(ratio))
+	if p.Cmp(r.Start) <= 0 {
+		return Key{}, ErrInvalidRatio
+	}
+	return p, nil
+}
+
+// SplitAt splits the range at ratio, see SplitPoint.
+func (r Range) SplitAt(ratio float64) (Range, Range, error) {
+	p, err := r.SplitPoint(ratio)
+	if err != nil {
+		return Range{}, Range{}, err
+	}
+	return Range{Start: r.Start, End: p.Prev()}, Range{Start: p, End: r.End}, nil
+}
+
+// Divide splits the range into n contiguous ranges of (almost) equal width.
+// The keys left over by the integer division go to the lower ranges, which
+// matches how CreateStream distributes the hash space.
+func (r Range) Divide(n int) ([]Range, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("hashrange: cannot divide into %d ranges", n)
+	}
+	if r.Width().Cmp(Key{Lo: uint64(n - 1)}) < 0 {
+		return nil, fmt.Errorf("hashrange: range %s is too narrow to divide into %d", r, n)
+	}
+
+	// the range holds Width+1 = q*n + rem + 1 keys
+	q, rem := r.Width().DivUint64(uint64(n))
+
+	ranges := make([]Range, 0, n)
+	start := r.Start
+	for i := 0; i < n; i++ {
+		if i == n-1 {
+			ranges = append(ranges, Range{Start: start, End: r.End})
+			break
+		}
+
+		end := start.Add(q)
+		if uint64(i) > rem {
+			end = end.Prev()
+		}
+		ranges = append(ranges, Range{Start: start, End: end})
+		start = end.Next()
+	}
+	return ranges, nil
+}
@@ -0,0 +1,31 @@
+package hashrange
+
+import (
+	"errors"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add("0", "340282366920938463463374607431768211455")
+	f.Add("10", "9")
+	f.Add("5", "5")
+	f.Add("", "1")
+	f.Add("1", "340282366920938463463374607431768211456")
+	f.Fuzz(func(t *testing.T, start, end string) {
+		r, err := Parse(start, end)
+		if err != nil {
+			var keyErr *KeyError
+			var rangeErr *RangeError
+			if !errors.As(err, &keyErr) && !errors.As(err, &rangeErr) {
+				t.Fatalf("Parse(%q, %q): unexpected error %v", start, end, err)
+			}
+			return
+		}
+		if r.Start.Cmp(r.End) > 0 {
+			t.Fatalf("Parse(%q, %q) = empty range %s", start, end, r)
+		}
+		if again, err := Parse(r.Start.String(), r.End.String()); err != nil || again != r {
+			t.Fatalf("Parse(%q, %q) = %s, parsed again as %s, %v", start, end, r, again, err)
+		}
+	})
+}
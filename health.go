@@ -0,0 +1,132 @@
+package kine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	healthThrottleLookback  = time.Hour
+	healthRecommendLookback = 24 * time.Hour
+)
+
+type HealthReport struct {
+	StreamName string
+	Status     string
+	// StreamMode is always PROVISIONED: on-demand streams are not known to
+	// the SDK version kine is built with.
+	StreamMode     string
+	EncryptionType string
+	KeyID          string
+	RetentionHours int
+	ConsumerCount  int
+
+	OpenShards        int
+	RecommendedShards int
+
+	// WriteThrottles and ReadThrottles are the throttled requests during
+	// the last hour.
+	WriteThrottles int
+	ReadThrottles  int
+
+	Topology *TopologyReport
+
+	// EventSourceMappings are nil when they cannot be listed, see
+	// StreamSummary.
+	EventSourceMappings []EventSourceMapping
+}
+
+// Problems lists what needs attention, empty when the stream is healthy.
+func (h *HealthReport) Problems() []string {
+	var problems []string
+	if h.Status != kinesis.StreamStatusActive {
+		problems = append(problems, fmt.Sprintf("stream is %s", h.Status))
+	}
+	if h.WriteThrottles > 0 {
+		problems = append(problems, fmt.Sprintf("%d write throttles in the last hour", h.WriteThrottles))
+	}
+	if h.ReadThrottles > 0 {
+		problems = append(problems, fmt.Sprintf("%d read throttles in the last hour", h.ReadThrottles))
+	}
+	if h.OpenShards < h.RecommendedShards {
+		problems = append(problems, fmt.Sprintf("%d open shards, %d recommended", h.OpenShards, h.RecommendedShards))
+	}
+	for _, m := range h.EventSourceMappings {
+		if m.Failing() {
+			problems = append(problems, fmt.Sprintf("lambda %s: %s", m.FunctionARN, m.LastProcessingResult))
+		}
+	}
+	if h.Topology != nil {
+		for _, a := range h.Topology.Anomalies {
+			if a.Broken() {
+				problems = append(problems, a.Error())
+			}
+		}
+	}
+	return problems
+}
+
+func (h *HealthReport) Healthy() bool {
+	return len(h.Problems()) == 0
+}
+
+// Health returns a consolidated health report of the stream for runbooks
+// and dashboards.
+func (k *Kine) Health(streamName string) (*HealthReport, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary := out.StreamDescriptionSummary
+
+	h := &HealthReport{
+		StreamName:     streamName,
+		Status:         *summary.StreamStatus,
+		StreamMode:     "PROVISIONED",
+		EncryptionType: aws.StringValue(summary.EncryptionType),
+		KeyID:          aws.StringValue(summary.KeyId),
+		RetentionHours: int(*summary.RetentionPeriodHours),
+		ConsumerCount:  int(aws.Int64Value(summary.ConsumerCount)),
+		OpenShards:     int(*summary.OpenShardCount),
+	}
+
+	r, err := k.Recommend(streamName, healthRecommendLookback)
+	if err != nil {
+		return nil, err
+	}
+	h.RecommendedShards = r.ShardCount
+
+	throttles := []struct {
+		metricName string
+		count      *int
+	}{
+		{kinesis.MetricsNameWriteProvisionedThroughputExceeded, &h.WriteThrottles},
+		{kinesis.MetricsNameReadProvisionedThroughputExceeded, &h.ReadThrottles},
+	}
+	for _, t := range throttles {
+		datapoints, err := k.getMetric(t.metricName, map[string]string{"StreamName": streamName},
+			cloudwatch.StatisticSum, healthThrottleLookback, healthThrottleLookback)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range datapoints {
+			*t.count += int(*d.Sum)
+		}
+	}
+
+	h.Topology, err = k.CheckTopology(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	h.EventSourceMappings = k.eventSourceMappings(streamName)
+
+	return h, nil
+}
@@ -0,0 +1,196 @@
+package kine
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// heartbeatMagic starts the data of heartbeat records, followed by the
+// 8-byte send time in Unix nanoseconds. Consumers skip them.
+const heartbeatMagic = "\x00kh"
+
+const heartbeatPartitionKey = "kine-heartbeat"
+
+func isHeartbeat(data []byte) bool {
+	return len(data) == len(heartbeatMagic)+8 && bytes.HasPrefix(data, []byte(heartbeatMagic))
+}
+
+// Heartbeat writes a tiny record to every open shard of a stream at an
+// interval, so that consumers can tell a stuck shard reader from a shard
+// without traffic, see WithHeartbeatCheck. Consumers of kine skip the
+// heartbeats; other consumers of the stream must tolerate them.
+type Heartbeat struct {
+	k          *Kine
+	streamName string
+	interval   time.Duration
+}
+
+func (k *Kine) NewHeartbeat(streamName string, interval time.Duration) *Heartbeat {
+	return &Heartbeat{
+		k:          k,
+		streamName: streamName,
+		interval:   interval,
+	}
+}
+
+// Beat writes one heartbeat to every open shard.
+func (h *Heartbeat) Beat(ctx context.Context) error {
+	shards, err := h.k.openShards(h.streamName, false)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, len(heartbeatMagic)+8)
+	copy(data, heartbeatMagic)
+	binary.BigEndian.PutUint64(data[len(heartbeatMagic):], uint64(h.k.clock.Now().UnixNano()))
+	for _, s := range shards {
+		_, err := h.k.svc.PutRecordWithContext(ctx, &kinesis.PutRecordInput{
+			Data:            data,
+			ExplicitHashKey: s.HashKeyRange.StartingHashKey,
+			PartitionKey:    aws.String(heartbeatPartitionKey),
+			StreamName:      aws.String(h.streamName),
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", *s.ShardId, err)
+		}
+	}
+	return nil
+}
+
+// Run writes heartbeats every interval until ctx is done.
+// A failing beat is logged and does not stop the loop.
+func (h *Heartbeat) Run(ctx context.Context) error {
+	for {
+		if err := h.Beat(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("kine: heartbeat %s: %v", h.streamName, err)
+		}
+
+		timer := h.k.clock.NewTimer(h.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// minHeartbeatAge bounds how often the consumer checks the heartbeats.
+const minHeartbeatAge = time.Second
+
+type heartbeatShard struct {
+	last    time.Time
+	alerted bool
+}
+
+// heartbeatCheck tracks the last heartbeat of the shards being read.
+type heartbeatCheck struct {
+	maxAge  time.Duration
+	alerter Alerter
+	clock   Clock
+
+	mu     sync.Mutex
+	shards map[string]*heartbeatShard
+}
+
+// WithHeartbeatCheck alerts when a shard being read received no heartbeat
+// for more than maxAge, catching readers which are stuck although the lag
+// metrics of the stream look fine. The stream needs a running Heartbeat
+// with an interval well under maxAge. A maxAge under a second is raised to
+// a second.
+func WithHeartbeatCheck(maxAge time.Duration, a Alerter) ConsumerOption {
+	return func(c *Consumer) {
+		if maxAge < minHeartbeatAge {
+			maxAge = minHeartbeatAge
+		}
+		c.heartbeat = &heartbeatCheck{
+			maxAge:  maxAge,
+			alerter: a,
+			clock:   c.k.clock,
+			shards:  make(map[string]*heartbeatShard),
+		}
+	}
+}
+
+// watch starts tracking a shard, as if it just received a heartbeat.
+func (h *heartbeatCheck) watch(shardID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shards[shardID] = &heartbeatShard{last: h.clock.Now()}
+}
+
+func (h *heartbeatCheck) forget(shardID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.shards, shardID)
+}
+
+func (h *heartbeatCheck) beat(shardID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.shards[shardID]; ok {
+		s.last = h.clock.Now()
+		s.alerted = false
+	}
+}
+
+// stale returns the alerts of the shards whose heartbeat is too old, once
+// per shard until it receives a heartbeat again.
+func (h *heartbeatCheck) stale(streamName string) []*Alert {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var alerts []*Alert
+	now := h.clock.Now()
+	for shardID, s := range h.shards {
+		age := now.Sub(s.last)
+		if age <= h.maxAge || s.alerted {
+			continue
+		}
+		s.alerted = true
+		alerts = append(alerts, &Alert{
+			StreamName: streamName,
+			Condition:  fmt.Sprintf("heartbeat age (s) of %s", shardID),
+			Value:      age.Seconds(),
+			Threshold:  h.maxAge.Seconds(),
+			Time:       now,
+		})
+	}
+	return alerts
+}
+
+// run checks the heartbeats until ctx is done.
+func (h *heartbeatCheck) run(ctx context.Context, streamName string) {
+	for {
+		timer := h.clock.NewTimer(h.maxAge / 2)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		for _, a := range h.stale(streamName) {
+			if err := h.alerter.Alert(a); err != nil {
+				log.Printf("kine: consumer %s: heartbeat alert: %v", streamName, err)
+			}
+		}
+	}
+}
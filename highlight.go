@@ -0,0 +1,153 @@
+package kine
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/mattn/go-isatty"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Highlight are the thresholds above which View colors the row of a shard.
+type Highlight struct {
+	// Share colors the shards covering more than this fraction of the hash
+	// space, e.g. 0.1 for 10%.
+	Share float64
+	// WriteUtilization colors the shards whose writes reached this fraction
+	// of the shard limit in the last five minutes, e.g. 0.8. It needs the
+	// shard-level IncomingBytes and IncomingRecords metrics.
+	WriteUtilization float64
+	// Closed also lists the closed shards, dimmed, and marks the parents of
+	// open shards.
+	Closed bool
+}
+
+// WithHighlight shows the state of every shard and colors the rows
+// exceeding the thresholds of h. Colors are only used on a terminal, and
+// not when NO_COLOR is set, unless WithColor says otherwise.
+func WithHighlight(h Highlight) ViewOption {
+	return func(c *viewConfig) {
+		c.highlight = &h
+	}
+}
+
+// WithColor forces colored output on or off.
+func WithColor(color bool) ViewOption {
+	return func(c *viewConfig) {
+		c.color = &color
+	}
+}
+
+// stdoutColor reports whether stdout is a terminal which wants colors.
+func stdoutColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+func colorize(s string, color bool, codes ...int) string {
+	if !color {
+		return s
+	}
+	seq := ""
+	for i, c := range codes {
+		if i > 0 {
+			seq += ";"
+		}
+		seq += fmt.Sprint(c)
+	}
+	return "\033[" + seq + "m" + s + "\033[0m"
+}
+
+const dimColor = 2
+
+func (k *Kine) viewHighlighted(streamName string, w io.Writer, c *viewConfig) error {
+	h := c.highlight
+	color := c.color != nil && *c.color
+
+	var shards []*kinesis.Shard
+	if h.Closed {
+		err := k.listShards(streamName, func(page []*kinesis.Shard) error {
+			shards = append(shards, page...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		shards, err = k.openShards(streamName, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	var open []*kinesis.Shard
+	parents := make(map[string]bool)
+	for _, s := range shards {
+		if s.SequenceNumberRange.EndingSequenceNumber != nil {
+			continue
+		}
+		open = append(open, s)
+		if s.ParentShardId != nil {
+			parents[*s.ParentShardId] = true
+		}
+		if s.AdjacentParentShardId != nil {
+			parents[*s.AdjacentParentShardId] = true
+		}
+	}
+
+	var utilization map[string]float64
+	if h.WriteUtilization > 0 {
+		var err error
+		utilization, err = k.writeUtilization(streamName, open, hotShardLookback)
+		if err != nil {
+			return err
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	header := []string{"shard", "state", "share"}
+	if utilization != nil {
+		header = append(header, "write utilization")
+	}
+	table.SetHeader(header)
+
+	for _, s := range shards {
+		share := hashRangeShare(s)
+		row := []string{*s.ShardId, "open", fmt.Sprintf("%.2f %%", share*100)}
+		if utilization != nil {
+			row = append(row, "-")
+		}
+
+		var codes []int
+		switch {
+		case s.SequenceNumberRange.EndingSequenceNumber != nil:
+			row[1] = "closed"
+			if parents[*s.ShardId] {
+				row[1] = "parent"
+			}
+			codes = []int{dimColor}
+		default:
+			if h.Share > 0 && share > h.Share {
+				codes = []int{tablewriter.FgYellowColor}
+			}
+			if u, ok := utilization[*s.ShardId]; ok {
+				row[3] = fmt.Sprintf("%.0f %%", u*100)
+				if u >= h.WriteUtilization {
+					codes = []int{tablewriter.Bold, tablewriter.FgRedColor}
+				}
+			}
+		}
+		for i := range row {
+			row[i] = colorize(row[i], color && codes != nil, codes...)
+		}
+		table.Append(row)
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,178 @@
+package kine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/olekukonko/tablewriter"
+)
+
+// AuditHistory is an AuditSink which can be queried for past events.
+type AuditHistory interface {
+	History(streamName string, since time.Time) ([]*AuditEvent, error)
+}
+
+// ErrNoAuditHistory is returned by History when the audit sink of the
+// Kine cannot be queried.
+var ErrNoAuditHistory = errors.New("audit sink does not keep a history")
+
+// History returns the operations on the stream recorded by the audit sink
+// since the given time, oldest first, to answer e.g. when the stream was
+// last resharded and by whom.
+func (k *Kine) History(streamName string, since time.Time) ([]*AuditEvent, error) {
+	h, ok := k.auditSink.(AuditHistory)
+	if !ok {
+		return nil, ErrNoAuditHistory
+	}
+	events, err := h.History(streamName, since)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// WriteHistory writes the events as a table, one row per operation.
+func WriteHistory(w io.Writer, events []*AuditEvent) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"time", "stream", "operation", "principal", "duration", "outcome"})
+	for _, e := range events {
+		outcome := "ok"
+		if e.Error != "" {
+			outcome = e.Error
+		}
+		table.Append([]string{
+			e.Time.Local().Format(time.RFC3339),
+			e.StreamName,
+			e.Operation,
+			e.Principal,
+			e.Duration.Round(time.Millisecond).String(),
+			outcome,
+		})
+	}
+	table.Render()
+}
+
+func (s *JSONFileAuditSink) History(streamName string, since time.Time) ([]*AuditEvent, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []*AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := &AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.path, err)
+		}
+		if e.StreamName == streamName && !e.Time.Before(since) {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func (s *DynamoDBAuditSink) History(streamName string, since time.Time) ([]*AuditEvent, error) {
+	var events []*AuditEvent
+	var decodeErr error
+	err := s.k.ddb.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("StreamName = :s AND #t >= :t"),
+		ExpressionAttributeNames: map[string]*string{
+			"#t": aws.String("Time"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":s": {S: aws.String(streamName)},
+			":t": {S: aws.String(since.UTC().Format(auditTimeFormat))},
+		},
+	}, func(out *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range out.Items {
+			e, err := auditEventFromItem(item)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			events = append(events, e)
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return events, err
+}
+
+func auditEventFromItem(item map[string]*dynamodb.AttributeValue) (*AuditEvent, error) {
+	str := func(name string) string {
+		if v, ok := item[name]; ok {
+			return aws.StringValue(v.S)
+		}
+		return ""
+	}
+
+	t, err := time.Parse(auditTimeFormat, str("Time"))
+	if err != nil {
+		return nil, err
+	}
+	e := &AuditEvent{
+		Time:       t,
+		Principal:  str("Principal"),
+		StreamName: str("StreamName"),
+		Operation:  str("Operation"),
+		Error:      str("Error"),
+	}
+	if params := str("Parameters"); params != "" {
+		if err := json.Unmarshal([]byte(params), &e.Parameters); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := item["DurationMs"]; ok {
+		ms, err := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		e.Duration = time.Duration(ms) * time.Millisecond
+	}
+	return e, nil
+}
+
+func (s *CloudWatchLogsAuditSink) History(streamName string, since time.Time) ([]*AuditEvent, error) {
+	var events []*AuditEvent
+	var decodeErr error
+	err := s.svc.FilterLogEventsPages(&cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:   aws.String(s.group),
+		LogStreamNames: []*string{aws.String(s.stream)},
+		StartTime:      aws.Int64(since.UnixNano() / int64(time.Millisecond)),
+		FilterPattern:  aws.String(fmt.Sprintf("{ $.stream = %q }", streamName)),
+	}, func(out *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		for _, le := range out.Events {
+			e := &AuditEvent{}
+			if err := json.Unmarshal([]byte(aws.StringValue(le.Message)), e); err != nil {
+				decodeErr = err
+				return false
+			}
+			if !e.Time.Before(since) {
+				events = append(events, e)
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return events, err
+}
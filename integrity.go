@@ -0,0 +1,196 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+type AnomalyKind string
+
+const (
+	// AnomalyInvalidRange is a shard whose hash key range cannot be parsed.
+	AnomalyInvalidRange AnomalyKind = "invalid-range"
+	// AnomalyGap is a part of the hash key space covered by no open shard.
+	AnomalyGap AnomalyKind = "gap"
+	// AnomalyOverlap is a part of the hash key space covered by two open shards.
+	AnomalyOverlap AnomalyKind = "overlap"
+	// AnomalyNotAdjacentPair is a pair merged by HalveShard which is not adjacent.
+	AnomalyNotAdjacentPair AnomalyKind = "not-adjacent-pair"
+	// AnomalyUnpairedShard is the last shard left over by HalveShard when
+	// the open shard count is odd.
+	AnomalyUnpairedShard AnomalyKind = "unpaired-shard"
+)
+
+type Anomaly struct {
+	Kind     AnomalyKind
+	ShardIDs []string
+	Range    hashrange.Range
+	Message  string
+}
+
+func (a Anomaly) Error() string {
+	return fmt.Sprintf("%s: %s", a.Kind, a.Message)
+}
+
+type TopologyReport struct {
+	StreamName string
+	OpenShards int
+	Anomalies  []Anomaly
+}
+
+// Broken reports whether the anomaly means the open shards do not tile the
+// hash key space. Anomalies about HalveShard pairing are only informational.
+func (a Anomaly) Broken() bool {
+	switch a.Kind {
+	case AnomalyInvalidRange, AnomalyGap, AnomalyOverlap:
+		return true
+	}
+	return false
+}
+
+// OK reports whether the open shards tile the hash key space exactly.
+func (r *TopologyReport) OK() bool {
+	for _, a := range r.Anomalies {
+		if a.Broken() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTopology verifies that the open shards of the stream exactly tile the
+// 128-bit hash key space without gaps or overlaps, and that the pairs
+// HalveShard would merge are adjacent.
+func (k *Kine) CheckTopology(streamName string) (*TopologyReport, error) {
+
+	shards, err := k.openShards(streamName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TopologyReport{
+		StreamName: streamName,
+		OpenShards: len(shards),
+	}
+
+	valid := make([]ShardRange, 0, len(shards))
+	for _, s := range shards {
+		r, err := hashrange.Parse(*s.HashKeyRange.StartingHashKey, *s.HashKeyRange.EndingHashKey)
+		if err != nil {
+			report.Anomalies = append(report.Anomalies, Anomaly{
+				Kind:     AnomalyInvalidRange,
+				ShardIDs: []string{*s.ShardId},
+				Message: fmt.Sprintf("shard %s has range [%s, %s]: %v", *s.ShardId,
+					*s.HashKeyRange.StartingHashKey, *s.HashKeyRange.EndingHashKey, err),
+			})
+			continue
+		}
+		valid = append(valid, ShardRange{ShardID: *s.ShardId, Range: r})
+	}
+
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].Range.Start.Cmp(valid[j].Range.Start) < 0
+	})
+
+	report.Anomalies = append(report.Anomalies, CheckTiling(valid)...)
+	report.Anomalies = append(report.Anomalies, checkPairs(valid)...)
+
+	return report, nil
+}
+
+// CheckTiling returns the gaps and overlaps of shards, which must be sorted
+// by starting hash key.
+func CheckTiling(shards []ShardRange) []Anomaly {
+	var anomalies []Anomaly
+
+	if len(shards) == 0 {
+		return []Anomaly{{
+			Kind:    AnomalyGap,
+			Range:   hashrange.Full,
+			Message: "no open shards",
+		}}
+	}
+
+	if first := shards[0]; first.Range.Start != hashrange.MinKey {
+		gap := hashrange.Range{Start: hashrange.MinKey, End: first.Range.Start.Prev()}
+		anomalies = append(anomalies, Anomaly{
+			Kind:     AnomalyGap,
+			ShardIDs: []string{first.ShardID},
+			Range:    gap,
+			Message:  fmt.Sprintf("%s is not covered below shard %s", gap, first),
+		})
+	}
+
+	for i := 1; i < len(shards); i++ {
+		prev, s := shards[i-1], shards[i]
+		ids := []string{prev.ShardID, s.ShardID}
+
+		switch {
+		case prev.Range.Adjacent(s.Range):
+		case prev.Range.End.Cmp(s.Range.Start) >= 0:
+			end := prev.Range.End
+			if s.Range.End.Cmp(end) < 0 {
+				end = s.Range.End
+			}
+			overlap := hashrange.Range{Start: s.Range.Start, End: end}
+			anomalies = append(anomalies, Anomaly{
+				Kind:     AnomalyOverlap,
+				ShardIDs: ids,
+				Range:    overlap,
+				Message:  fmt.Sprintf("%s is covered by both shard %s and %s", overlap, prev, s),
+			})
+		default:
+			gap := hashrange.Range{Start: prev.Range.End.Next(), End: s.Range.Start.Prev()}
+			anomalies = append(anomalies, Anomaly{
+				Kind:     AnomalyGap,
+				ShardIDs: ids,
+				Range:    gap,
+				Message:  fmt.Sprintf("%s is not covered between shard %s and %s", gap, prev, s),
+			})
+		}
+	}
+
+	if last := shards[len(shards)-1]; last.Range.End != hashrange.MaxKey {
+		gap := hashrange.Range{Start: last.Range.End.Next(), End: hashrange.MaxKey}
+		anomalies = append(anomalies, Anomaly{
+			Kind:     AnomalyGap,
+			ShardIDs: []string{last.ShardID},
+			Range:    gap,
+			Message:  fmt.Sprintf("%s is not covered above shard %s", gap, last),
+		})
+	}
+
+	return anomalies
+}
+
+// checkPairs checks the pairs merged by HalveShard: shards[0] with shards[1],
+// shards[2] with shards[3] and so on.
+func checkPairs(shards []ShardRange) []Anomaly {
+	var anomalies []Anomaly
+
+	for i := 0; i+1 < len(shards); i += 2 {
+		a, b := shards[i], shards[i+1]
+		if a.Range.Adjacent(b.Range) {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Kind:     AnomalyNotAdjacentPair,
+			ShardIDs: []string{a.ShardID, b.ShardID},
+			Message:  fmt.Sprintf("shard %s %s and %s %s cannot be merged", a.ShardID, a.Range, b.ShardID, b.Range),
+		})
+	}
+
+	if len(shards) > 1 && len(shards)%2 == 1 {
+		last := shards[len(shards)-1]
+		anomalies = append(anomalies, Anomaly{
+			Kind:     AnomalyUnpairedShard,
+			ShardIDs: []string{last.ShardID},
+			Range:    last.Range,
+			Message:  fmt.Sprintf("odd number of open shards, shard %s has no pair", last.ShardID),
+		})
+	}
+
+	return anomalies
+}
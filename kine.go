@@ -2,22 +2,74 @@ package kine
 
 import (
 	"fmt"
-	"math/big"
-	"os"
+	"io"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/firehose"
 	"github.com/aws/aws-sdk-go/service/kinesis"
-	"github.com/olekukonko/tablewriter"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/ingtk/kine/hashrange"
 )
 
+// Kine is safe for concurrent use by multiple goroutines once created, e.g.
+// to View, Tail and Scale different streams at the same time. Concurrent
+// operations changing the same stream are not coordinated: resharding a
+// stream from two goroutines gives the same result as from two processes.
+// Tables printed to stdout are never interleaved.
 type Kine struct {
 	svc      *kinesis.Kinesis
+	cw       *cloudwatch.CloudWatch
+	firehose *firehose.Firehose
+	lambda   *lambda.Lambda
+	kms      *kms.KMS
+	ddb      *dynamodb.DynamoDB
 	session  *session.Session
 	endpoint string
 	region   string
+	roleARN  string
+	backoff  Backoff
+
+	shardCache *shardCache
+
+	changePolicy         *ChangePolicy
+	overrideChangePolicy bool
+	lagGuard             *LagGuard
+	force                bool
+	reshardLock          *ReshardLock
+	stepTimeout          time.Duration
+	events               EventEmitter
+	clock                Clock
+	pacing               Pacing
+	pacer                *pacer
+
+	auditSink     AuditSink
+	opStore       OperationStore
+	throttles     int64
+	principalOnce sync.Once
+	principalARN  string
+
+	fips      bool
+	dualStack bool
+	resolver  endpoints.Resolver
+
+	webIdentityRoleARN   string
+	webIdentityTokenFile string
+
+	profile string
+
+	mfaSerialNumber string
+	mfaToken        func() (string, error)
 }
 
 type KineOption interface {
@@ -44,8 +96,151 @@ func WithEndpoint(endpoint string) KineOption {
 	})
 }
 
+// WithSharedConfigProfile uses the named profile of ~/.aws/config and
+// ~/.aws/credentials instead of AWS_PROFILE or the default one.
+func WithSharedConfigProfile(name string) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.profile = name
+		return nil
+	})
+}
+
+// WithAssumeRole makes the client use the credentials of the given IAM role,
+// e.g. to manage streams in another account.
+func WithAssumeRole(roleARN string) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.roleARN = roleARN
+		return nil
+	})
+}
+
+// WithFIPSEndpoint makes every AWS client use the FIPS 140-2 endpoint of its
+// service, e.g. kinesis-fips.us-gov-west-1.amazonaws.com.
+func WithFIPSEndpoint() KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.fips = true
+		return nil
+	})
+}
+
+// WithDualStackEndpoint makes every AWS client use the IPv4/IPv6 endpoint of
+// its service, e.g. kinesis.us-east-1.api.aws.
+func WithDualStackEndpoint() KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.dualStack = true
+		return nil
+	})
+}
+
+// WithEndpointResolver resolves the endpoints of every AWS client (Kinesis,
+// STS, CloudWatch, DynamoDB, ...) with r, e.g. for VPC endpoints.
+// WithEndpoint, WithFIPSEndpoint and WithDualStackEndpoint take precedence.
+func WithEndpointResolver(r endpoints.Resolver) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.resolver = r
+		return nil
+	})
+}
+
+// WithServiceEndpoints redirects services by endpoint ID, e.g.
+// {"kinesis": "http://localhost:4566", "dynamodb": "http://localhost:8000"}.
+// Other services resolve to their default endpoint.
+func WithServiceEndpoints(urls map[string]string) KineOption {
+	return WithEndpointResolver(endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		resolved, err := endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		url, ok := urls[service]
+		if !ok {
+			return resolved, err
+		}
+		// keep the signing name and region of the service
+		resolved.URL = url
+		if resolved.SigningRegion == "" {
+			resolved.SigningRegion = region
+		}
+		return resolved, nil
+	}))
+}
+
+// serviceConfig returns the client config of the service, with the FIPS or
+// dual-stack endpoint when requested. The SDK does not know these endpoints,
+// so they are built from the endpoint ID of the service and the region.
+func (k *Kine) serviceConfig(endpointsID string) *aws.Config {
+	conf := &aws.Config{}
+	if !k.fips && !k.dualStack {
+		return conf
+	}
+
+	host := endpointsID
+	if k.fips {
+		host += "-fips"
+	}
+	domain := "amazonaws.com"
+	if k.dualStack {
+		domain = "api.aws"
+	}
+	region := aws.StringValue(k.session.Config.Region)
+	return conf.WithEndpoint(fmt.Sprintf("https://%s.%s.%s", host, region, domain))
+}
+
+// WithMFATokenProvider sets how the MFA token is asked for roles requiring
+// MFA, both for the roles of the shared config (mfa_serial) and for
+// WithAssumeRole with WithMFASerialNumber. stscreds.StdinTokenProvider
+// prompts on the terminal.
+func WithMFATokenProvider(fn func() (string, error)) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.mfaToken = fn
+		return nil
+	})
+}
+
+// WithMFASerialNumber sets the MFA device (ARN or serial number) used for the
+// role of WithAssumeRole.
+func WithMFASerialNumber(serialNumber string) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.mfaSerialNumber = serialNumber
+		return nil
+	})
+}
+
 func New(opts ...KineOption) (*Kine, error) {
-	k := &Kine{}
+	k := &Kine{
+		backoff:    DefaultBackoff,
+		clock:      realClock{},
+		pacing:     DefaultPacing,
+		shardCache: newShardCache(),
+	}
+	return k.init(opts)
+}
+
+// NewFromKine returns a client sharing the session of base, and so its HTTP
+// transport and credentials, instead of building new ones. opts can change
+// the region, assume another role or set a Kinesis endpoint; the options
+// which only apply to building a session (profile, endpoint resolver, web
+// identity) are ignored. The endpoint and FIPS/dual-stack settings of base
+// are kept.
+func NewFromKine(base *Kine, opts ...KineOption) (*Kine, error) {
+	k := &Kine{
+		session:      base.session,
+		endpoint:     base.endpoint,
+		region:       base.region,
+		backoff:      base.backoff,
+		fips:         base.fips,
+		dualStack:    base.dualStack,
+		changePolicy: base.changePolicy,
+		lagGuard:     base.lagGuard,
+		reshardLock:  base.reshardLock,
+		stepTimeout:  base.stepTimeout,
+		events:       base.events,
+		clock:        base.clock,
+		pacing:       base.pacing,
+		auditSink:    base.auditSink,
+		opStore:      base.opStore,
+		shardCache:   newShardCache(),
+	}
+	return k.init(opts)
+}
+
+func (k *Kine) init(opts []KineOption) (*Kine, error) {
 	for _, o := range opts {
 		err := o.Apply(k)
 		if err != nil {
@@ -55,24 +250,76 @@ func New(opts ...KineOption) (*Kine, error) {
 
 	if k.session == nil {
 		conf := &aws.Config{}
-		if k.endpoint != "" {
-			conf = conf.WithEndpoint(k.endpoint)
-		}
 		if k.region != "" {
 			conf = conf.WithRegion(k.region)
 		}
-		k.session = session.New(conf)
+		if k.resolver != nil {
+			conf = conf.WithEndpointResolver(k.resolver)
+		}
+		// the region, role and credential process of the shared config are
+		// honored as by the AWS CLI
+		var err error
+		k.session, err = session.NewSessionWithOptions(session.Options{
+			Config:                  *conf,
+			Profile:                 k.profile,
+			SharedConfigState:       session.SharedConfigEnable,
+			AssumeRoleTokenProvider: k.mfaToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if k.region != "" && k.region != aws.StringValue(k.session.Config.Region) {
+			k.session = k.session.Copy(&aws.Config{Region: aws.String(k.region)})
+		}
+		k.webIdentityRoleARN = ""
 	}
 
-	k.svc = kinesis.New(k.session)
+	if k.webIdentityRoleARN != "" {
+		k.session = k.session.Copy(&aws.Config{
+			Credentials: k.webIdentityCredentials(),
+		})
+	}
+
+	if k.roleARN != "" {
+		k.session = k.session.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(k.session.Copy(k.serviceConfig(sts.EndpointsID)), k.roleARN, func(p *stscreds.AssumeRoleProvider) {
+				if k.mfaSerialNumber != "" {
+					p.SerialNumber = aws.String(k.mfaSerialNumber)
+					p.TokenProvider = k.mfaToken
+				}
+			}),
+		})
+	}
+
+	// the endpoint is only for Kinesis, other services use their default one
+	conf := k.serviceConfig(kinesis.EndpointsID)
+	if k.endpoint != "" {
+		conf = conf.WithEndpoint(k.endpoint)
+	}
+	k.svc = kinesis.New(k.session, conf)
+	if k.pacing.Max > 0 {
+		k.pacer = newPacer(k, k.pacing)
+		k.svc.Handlers.Sign.PushFront(k.pacer.wait)
+		k.svc.Handlers.CompleteAttempt.PushBack(k.pacer.observe)
+	}
+	if k.auditSink != nil {
+		k.svc.Handlers.Complete.PushBack(k.auditRequest)
+	}
+	if k.opStore != nil {
+		k.svc.Handlers.CompleteAttempt.PushBack(k.countThrottle)
+	}
+	k.cw = cloudwatch.New(k.session, k.serviceConfig(cloudwatch.EndpointsID))
+	k.firehose = firehose.New(k.session, k.serviceConfig(firehose.EndpointsID))
+	k.lambda = lambda.New(k.session, k.serviceConfig(lambda.EndpointsID))
+	k.kms = kms.New(k.session, k.serviceConfig(kms.EndpointsID))
+	k.ddb = dynamodb.New(k.session, k.serviceConfig(dynamodb.EndpointsID))
 
 	return k, nil
 }
 
 const (
 	defaultWaitSecond time.Duration = 5 * time.Second
-
-	maxPartitionKey = "340282366920938463463374607431768211456"
 )
 
 func (k *Kine) AWSKinesis() *kinesis.Kinesis {
@@ -82,137 +329,256 @@ func (k *Kine) AWSKinesis() *kinesis.Kinesis {
 // 全シャード取得してから返す
 func (k *Kine) DescribeStream(streamName string) (*kinesis.StreamDescription, error) {
 
-	var stream *kinesis.DescribeStreamOutput
+	summary, err := k.activeSummary(streamName)
+	if err != nil {
+		return nil, err
+	}
 
-	var shards []*kinesis.Shard
-	var startShardID *string
+	shards := make([]*kinesis.Shard, 0, int(aws.Int64Value(summary.OpenShardCount)))
+	err = k.listShards(streamName, func(page []*kinesis.Shard) error {
+		shards = append(shards, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		var err error
-		stream, err = k.svc.DescribeStream(&kinesis.DescribeStreamInput{
-			ExclusiveStartShardId: startShardID,
-			StreamName:            aws.String(streamName),
-		})
-		if err != nil {
-			return nil, err
-		}
+	return &kinesis.StreamDescription{
+		EncryptionType:          summary.EncryptionType,
+		EnhancedMonitoring:      summary.EnhancedMonitoring,
+		HasMoreShards:           aws.Bool(false),
+		KeyId:                   summary.KeyId,
+		RetentionPeriodHours:    summary.RetentionPeriodHours,
+		Shards:                  shards,
+		StreamARN:               summary.StreamARN,
+		StreamCreationTimestamp: summary.StreamCreationTimestamp,
+		StreamName:              summary.StreamName,
+		StreamStatus:            summary.StreamStatus,
+	}, nil
+}
 
-		sd := stream.StreamDescription
+type halveConfig struct {
+	mergeRemainder bool
+}
 
-		// activeになるまでは最初から再度読み込み続ける
-		if *sd.StreamStatus != kinesis.StreamStatusActive {
-			// 途中まで読み込んでても最初から読み込み直す
-			startShardID = nil
-			shards = make([]*kinesis.Shard, 0)
-		} else {
-			shards = append(shards, sd.Shards...)
-			if *sd.HasMoreShards == true {
-				startShardID = sd.Shards[len(sd.Shards)-1].ShardId
-			} else {
-				break
-			}
-		}
+type HalveOption func(c *halveConfig)
 
-		time.Sleep(5 * time.Second)
+// WithRemainderMerge makes HalveShard merge the shards left over by the
+// pairing, e.g. the last one of an odd number of shards, in a second pass
+// with the merged shard next to them. The result is one shard fewer, but
+// uneven.
+func WithRemainderMerge() HalveOption {
+	return func(c *halveConfig) {
+		c.mergeRemainder = true
 	}
+}
 
-	stream.StreamDescription.Shards = shards
+// HalveShard merges the open shards pairwise in hash key order. Only shards
+// whose ranges touch are merged. A shard left over next to a merged pair,
+// e.g. the last of an odd number of shards, is left untouched unless
+// WithRemainderMerge is given. Shards with no adjacent shard at all are
+// reported in an *UnmergedError once the pairs are merged.
+func (k *Kine) HalveShard(streamName string, opts ...HalveOption) error {
 
-	return stream.StreamDescription, nil
+	c := &halveConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+
+	// a change refused by the checks is not an operation
+	if err := k.checkReshard(streamName, "halve"); err != nil {
+		return err
+	}
+	end, err := k.beginReshard(streamName, "halve", nil)
+	if err != nil {
+		return err
+	}
+	err = k.halveShard(streamName, c)
+	end(err)
+	return err
 }
 
-func (k *Kine) HalveShard(streamName string) error {
+func (k *Kine) halveShard(streamName string, c *halveConfig) error {
 
-	stream, err := k.DescribeStream(streamName)
+	topology, err := k.Topology(streamName)
 	if err != nil {
 		return err
 	}
 
-	shards := filterOpenShards(stream.Shards, true)
-
-	if len(shards) == 1 {
+	if len(topology.Shards) == 1 {
 		return nil
 	}
 
-	for i := 0; i < len(shards); i += 2 {
-		params := &kinesis.MergeShardsInput{
-			AdjacentShardToMerge: shards[i+1].ShardId,    // Required
-			ShardToMerge:         shards[i].ShardId,      // Required
-			StreamName:           aws.String(streamName), // Required
+	plan, isolated := halvePlan(topology, c.mergeRemainder)
+	if err := k.applyAndView(plan); err != nil {
+		return err
+	}
+
+	if len(isolated) > 0 {
+		e := &UnmergedError{StreamName: streamName}
+		for _, s := range isolated {
+			e.ShardIDs = append(e.ShardIDs, s.ShardID)
 		}
-		_, err := k.svc.MergeShards(params)
-		if err != nil {
+		return e
+	}
+	return nil
+}
+
+// applyAndView applies the plan like Apply, printing the topology after
+// every step.
+func (k *Kine) applyAndView(plan *Plan) error {
+	for _, step := range plan.Steps {
+		if err := k.applyStep(plan.StreamName, step); err != nil {
+			return fmt.Errorf("%s: %w", plan.StreamName, err)
+		}
+		if err := k.View(plan.StreamName); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		err = k.View(streamName)
-		if err != nil {
-			return err
+// UnmergedError is returned by HalveShard after merging what it could, for
+// the shards without an adjacent shard to merge with, because of gaps in the
+// hash key ranges.
+type UnmergedError struct {
+	StreamName string
+	ShardIDs   []string
+}
+
+func (e *UnmergedError) Error() string {
+	return fmt.Sprintf("%s: no adjacent shard to merge %s with", e.StreamName, strings.Join(e.ShardIDs, ", "))
+}
+
+// pairAdjacentShards pairs the shards, sorted by hash key, whose ranges touch.
+// A shard not adjacent to the next one is left unpaired, and the pairing goes
+// on with the next one. Unpaired shards adjacent to the previous shard are
+// the remainder, which can be merged once the previous pair is; the others
+// are isolated.
+func pairAdjacentShards(shards []ShardRange) (pairs [][2]ShardRange, remainder, isolated []ShardRange) {
+	for i := 0; i < len(shards); {
+		if i+1 < len(shards) && shards[i].Range.Adjacent(shards[i+1].Range) {
+			pairs = append(pairs, [2]ShardRange{shards[i], shards[i+1]})
+			i += 2
+			continue
 		}
+		if i > 0 && shards[i-1].Range.Adjacent(shards[i].Range) {
+			remainder = append(remainder, shards[i])
+		} else {
+			isolated = append(isolated, shards[i])
+		}
+		i++
 	}
+	return pairs, remainder, isolated
+}
 
-	return nil
+type doubleConfig struct {
+	partial bool
+}
+
+type DoubleOption func(c *doubleConfig)
+
+// WithPartialDoubling makes DoubleShard split as many shards as the shard
+// limit of the account allows, widest first, instead of splitting none.
+func WithPartialDoubling() DoubleOption {
+	return func(c *doubleConfig) {
+		c.partial = true
+	}
+}
+
+// ShardLimitError is returned by DoubleShard when splitting every open shard
+// would exceed the shard limit of the account. Split are the shards split
+// anyway with WithPartialDoubling, Skipped the others.
+type ShardLimitError struct {
+	StreamName string
+	Limit      int
+	Available  int
+	Split      []string
+	Skipped    []string
 }
 
-func (k *Kine) DoubleShard(streamName string) error {
+func (e *ShardLimitError) Error() string {
+	return fmt.Sprintf("%s: doubling needs %d more shards, only %d of the limit of %d are available; split %d, skipped %s",
+		e.StreamName, len(e.Split)+len(e.Skipped), e.Available, e.Limit, len(e.Split), strings.Join(e.Skipped, ", "))
+}
+
+// DoubleShard splits every open shard in two halves. It checks the shard
+// limit of the account first and fails with a *ShardLimitError before
+// splitting anything when the limit would be exceeded, unless
+// WithPartialDoubling is given.
+func (k *Kine) DoubleShard(streamName string, opts ...DoubleOption) error {
+
+	c := &doubleConfig{}
+	for _, o := range opts {
+		o(c)
+	}
 
-	stream, err := k.DescribeStream(streamName)
+	if err := k.checkReshard(streamName, "double"); err != nil {
+		return err
+	}
+	end, err := k.beginReshard(streamName, "double", nil)
 	if err != nil {
 		return err
 	}
+	err = k.doubleShard(streamName, c)
+	end(err)
+	return err
+}
 
-	shards := filterOpenShards(stream.Shards, false)
+func (k *Kine) doubleShard(streamName string, c *doubleConfig) error {
 
-	for _, shard := range shards {
-		newStartingHashKey := calcNewStartingHashKey(
-			*shard.HashKeyRange.StartingHashKey,
-			*shard.HashKeyRange.EndingHashKey,
-		)
-
-		params := &kinesis.SplitShardInput{
-			NewStartingHashKey: aws.String(newStartingHashKey),
-			ShardToSplit:       shard.ShardId,
-			StreamName:         aws.String(streamName),
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return err
+	}
+
+	limits, err := k.svc.DescribeLimits(&kinesis.DescribeLimitsInput{})
+	if err != nil {
+		return err
+	}
+	n := len(topology.Shards)
+	var limitErr *ShardLimitError
+	if available := int(*limits.ShardLimit - *limits.OpenShardCount); available < n {
+		if available < 0 {
+			available = 0
 		}
-		_, err := k.svc.SplitShard(params)
-		if err != nil {
-			return err
+		limitErr = &ShardLimitError{
+			StreamName: streamName,
+			Limit:      int(*limits.ShardLimit),
+			Available:  available,
+		}
+		n = 0
+		if c.partial {
+			n = available
 		}
+	}
 
-		// Wait until active
-		for {
-			stream, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
-				StreamName: aws.String(streamName),
-			})
-			if err != nil {
-				return err
-			}
-			if *stream.StreamDescriptionSummary.StreamStatus == kinesis.StreamStatusActive {
-				break
-			}
-			time.Sleep(5 * time.Second)
+	// every shard is validated before splitting any of them
+	plan, split, skipped, err := doublePlan(topology, n)
+	if err != nil {
+		return err
+	}
+	if limitErr != nil {
+		for _, s := range skipped {
+			limitErr.Skipped = append(limitErr.Skipped, s.ShardID)
 		}
+	}
 
-		err = k.View(streamName)
-		if err != nil {
+	for i, step := range plan.Steps {
+		if err := k.applyAndView(&Plan{StreamName: streamName, Steps: []Step{step}}); err != nil {
 			return err
 		}
+		if limitErr != nil {
+			limitErr.Split = append(limitErr.Split, split[i].ShardID)
+		}
 	}
 
+	if limitErr != nil {
+		return limitErr
+	}
 	return nil
 }
 
-func calcNewStartingHashKey(startingHashKey, endingHashKey string) string {
-
-	skey, _ := big.NewInt(0).SetString(startingHashKey, 10)
-	ekey, _ := big.NewInt(0).SetString(endingHashKey, 10)
-
-	newStartingHashKey := big.NewInt(0)
-	newStartingHashKey.Add(skey, ekey).Div(newStartingHashKey, big.NewInt(2))
-
-	return newStartingHashKey.String()
-}
-
 func filterOpenShards(shards []*kinesis.Shard, sorted bool) []*kinesis.Shard {
 	filtered := make([]*kinesis.Shard, 0, len(shards))
 	i := 0
@@ -226,51 +592,64 @@ func filterOpenShards(shards []*kinesis.Shard, sorted bool) []*kinesis.Shard {
 	if !sorted {
 		return filtered
 	}
-	sort.Slice(filtered, func(i, j int) bool {
-		endingHashKey1 := big.NewInt(0)
-		endingHashKey2 := big.NewInt(0)
-		endingHashKey1.SetString(*filtered[i].HashKeyRange.EndingHashKey, 10)
-		endingHashKey2.SetString(*filtered[j].HashKeyRange.EndingHashKey, 10)
-		return endingHashKey1.Cmp(endingHashKey2) < 0
-	})
+	// the keys are parsed once rather than on every comparison
+	byKey := shardsByEndingHashKey{
+		shards: filtered,
+		keys:   make([]hashrange.Key, len(filtered)),
+	}
+	for i, s := range filtered {
+		byKey.keys[i], _ = hashrange.ParseKey(*s.HashKeyRange.EndingHashKey)
+	}
+	sort.Sort(byKey)
 
 	return filtered
 }
 
-func (k *Kine) View(streamName string) error {
+type shardsByEndingHashKey struct {
+	shards []*kinesis.Shard
+	keys   []hashrange.Key
+}
 
-	maxHashKey, _ := big.NewInt(0).SetString(maxPartitionKey, 10)
+func (s shardsByEndingHashKey) Len() int {
+	return len(s.shards)
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
+func (s shardsByEndingHashKey) Less(i, j int) bool {
+	return s.keys[i].Cmp(s.keys[j]) < 0
+}
 
-	data := make([][]string, 0)
+func (s shardsByEndingHashKey) Swap(i, j int) {
+	s.shards[i], s.shards[j] = s.shards[j], s.shards[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
 
-	stream, err := k.DescribeStream(streamName)
-	if err != nil {
-		return err
+// View prints the open shards of the stream in hash key order and their
+// share of the hash space, as Render does with TopologyTable, or an
+// aggregate of them with WithQuantiles or WithTop.
+func (k *Kine) View(streamName string, opts ...ViewOption) error {
+	c := &viewConfig{}
+	for _, o := range opts {
+		o(c)
 	}
-	if err != nil {
-		return err
+	if c.color == nil {
+		color := stdoutColor()
+		c.color = &color
 	}
+	return printStdout(func(w io.Writer) error {
+		return k.viewWith(streamName, w, c)
+	})
+}
+
+// hashRangeShare returns the fraction of the hash space covered by the shard.
+func hashRangeShare(s *kinesis.Shard) float64 {
+	r, _ := hashrange.Parse(*s.HashKeyRange.StartingHashKey, *s.HashKeyRange.EndingHashKey)
+	return r.Share()
+}
 
+func (k *Kine) view(streamName string, w io.Writer) error {
+	t, err := k.Topology(streamName)
 	if err != nil {
 		return err
 	}
-
-	openShards := filterOpenShards(stream.Shards, false)
-
-	for _, s := range openShards {
-		skey, _ := big.NewInt(0).SetString(*s.HashKeyRange.StartingHashKey, 10)
-		ekey, _ := big.NewInt(0).SetString(*s.HashKeyRange.EndingHashKey, 10)
-
-		diff := big.NewInt(0).Sub(ekey, skey)
-		r := big.NewRat(1, 1).SetFrac(diff, maxHashKey)
-		v, _ := r.Float32()
-		data = append(data, []string{*s.ShardId, fmt.Sprintf("%.2f %%", (v * 100.0))})
-	}
-
-	table.AppendBulk(data)
-	table.Render()
-
-	return nil
+	return Render(t, TopologyTable, w)
 }
@@ -0,0 +1,152 @@
+package kine_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/kinetest"
+)
+
+// syncBuffer is a buffer written and read by different goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestConcurrentStreams runs operations on different streams at the same
+// time on one client.
+func TestConcurrentStreams(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	streams := []string{"orders", "payments", "shipments", "returns"}
+	for i, name := range streams {
+		f.createStream(name, i+1)
+		f.put(name, name)
+	}
+	runClock(t, clock, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	tails := make([]*syncBuffer, len(streams))
+	tailErrs := make(chan error, len(streams))
+	for i, name := range streams {
+		tails[i] = &syncBuffer{}
+		go func(name string, w *syncBuffer) {
+			tailErrs <- k.Tail(ctx, name, w, kine.WithTailStart(kine.StartTrimHorizon), kine.WithColumns())
+		}(name, tails[i])
+
+		for j := 0; j < 3; j++ {
+			wg.Add(2)
+			go func(name string, n int) {
+				defer wg.Done()
+				topology, err := k.Topology(name)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(topology.Shards) != n {
+					t.Errorf("%s: %d shards, want %d", name, len(topology.Shards), n)
+				}
+				if err := kine.Render(topology, kine.TopologyTable, &bytes.Buffer{}); err != nil {
+					t.Error(err)
+				}
+			}(name, i+1)
+			go func(name string) {
+				defer wg.Done()
+				if _, err := k.EstimateThroughput(name, time.Second); err != nil {
+					t.Error(err)
+				}
+			}(name)
+		}
+	}
+	wg.Wait()
+
+	for i, name := range streams {
+		want := strings.Repeat(name+"\n", i+1)
+		eventually(t, "the records of "+name, func() bool {
+			return tails[i].String() == want
+		})
+	}
+	cancel()
+	for range streams {
+		if err := <-tailErrs; err != nil && err != context.Canceled {
+			t.Error(err)
+		}
+	}
+}
+
+// largeShardCount is the shard count of the large on-demand streams.
+const largeShardCount = 5000
+
+func TestTopologyListsShardsInPages(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("large", largeShardCount)
+
+	topology, err := k.Topology("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := f.callCount("ListShards"); n != 5 {
+		t.Errorf("%d ListShards calls, want 5 pages of 1000 shards", n)
+	}
+	if len(topology.Shards) != largeShardCount {
+		t.Fatalf("%d shards, want %d", len(topology.Shards), largeShardCount)
+	}
+	if anomalies := kine.CheckTiling(topology.Shards); len(anomalies) > 0 {
+		t.Errorf("shards do not tile the hash key space: %v", anomalies)
+	}
+}
+
+func BenchmarkTopologyLargeStream(b *testing.B) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(b, clock, kine.WithShardCacheTTL(0))
+	f.createStream("large", largeShardCount)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := k.Topology("large"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestShardCacheExpiresOnTheClock(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock, kine.WithShardCacheTTL(time.Minute))
+	f.createStream("orders", 2)
+
+	list := func(want int) {
+		t.Helper()
+		if _, err := k.Topology("orders"); err != nil {
+			t.Fatal(err)
+		}
+		if n := f.callCount("ListShards"); n != want {
+			t.Errorf("%d ListShards calls, want %d", n, want)
+		}
+	}
+	list(1)
+	clock.Advance(30 * time.Second)
+	list(1)
+	clock.Advance(30 * time.Second)
+	list(2)
+}
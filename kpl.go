@@ -0,0 +1,134 @@
+package kine
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+)
+
+// kplMagic starts the records aggregated by the Kinesis Producer Library,
+// followed by an AggregatedRecord protobuf message and its MD5 digest.
+var kplMagic = []byte{0xf3, 0x89, 0x9a, 0xc2}
+
+var errMalformedProtobuf = errors.New("malformed protobuf")
+
+// userRecord is a record packed in a KPL aggregated record.
+type userRecord struct {
+	PartitionKey    string
+	ExplicitHashKey string
+	Data            []byte
+}
+
+// isAggregated reports whether data is a KPL aggregated record.
+func isAggregated(data []byte) bool {
+	if len(data) < len(kplMagic)+md5.Size || !bytes.HasPrefix(data, kplMagic) {
+		return false
+	}
+	body := data[len(kplMagic) : len(data)-md5.Size]
+	sum := md5.Sum(body)
+	return bytes.Equal(sum[:], data[len(data)-md5.Size:])
+}
+
+// deaggregate unpacks a KPL aggregated record.
+func deaggregate(data []byte) ([]userRecord, error) {
+	body := data[len(kplMagic) : len(data)-md5.Size]
+
+	var partitionKeys, hashKeys []string
+	type entry struct {
+		pk, ehk uint64
+		data    []byte
+	}
+	var entries []entry
+
+	err := parseProtobuf(body, func(field int, varint uint64, b []byte) error {
+		switch field {
+		case 1:
+			partitionKeys = append(partitionKeys, string(b))
+		case 2:
+			hashKeys = append(hashKeys, string(b))
+		case 3:
+			var e entry
+			err := parseProtobuf(b, func(field int, varint uint64, b []byte) error {
+				switch field {
+				case 1:
+					e.pk = varint
+				case 2:
+					e.ehk = varint
+				case 3:
+					e.data = b
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]userRecord, 0, len(entries))
+	for _, e := range entries {
+		if e.pk >= uint64(len(partitionKeys)) {
+			return nil, errMalformedProtobuf
+		}
+		r := userRecord{PartitionKey: partitionKeys[e.pk], Data: e.data}
+		if e.ehk < uint64(len(hashKeys)) {
+			r.ExplicitHashKey = hashKeys[e.ehk]
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// parseProtobuf calls fn with each field of a protobuf message, passing the
+// value of varint fields and the bytes of length-delimited fields.
+func parseProtobuf(b []byte, fn func(field int, varint uint64, b []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformedProtobuf
+		}
+		b = b[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformedProtobuf
+			}
+			b = b[n:]
+			if err := fn(field, v, nil); err != nil {
+				return err
+			}
+		case 1:
+			if len(b) < 8 {
+				return errMalformedProtobuf
+			}
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errMalformedProtobuf
+			}
+			v := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := fn(field, 0, v); err != nil {
+				return err
+			}
+		case 5:
+			if len(b) < 4 {
+				return errMalformedProtobuf
+			}
+			b = b[4:]
+		default:
+			return errMalformedProtobuf
+		}
+	}
+	return nil
+}
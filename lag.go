@@ -0,0 +1,191 @@
+package kine
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	lagPeriod   = time.Minute
+	lagLookback = 5 * time.Minute
+)
+
+// Lag is the consumer backlog of a stream as reported by CloudWatch.
+type Lag struct {
+	StreamName   string
+	ConsumerName string
+	MetricName   string
+
+	// Latest is the most recent one-minute maximum, Max the maximum over
+	// the lookback window. Timestamp is zero when no datapoint was found.
+	Latest    time.Duration
+	Max       time.Duration
+	Timestamp time.Time
+
+	// Shards holds the per-shard iterator age. It is only filled for
+	// polling consumers when shard-level IteratorAgeMilliseconds is enabled.
+	Shards map[string]time.Duration
+}
+
+// ConsumerLag reports how far consumers are behind the tip of the stream.
+// With an empty consumerName it reports GetRecords.IteratorAgeMilliseconds of
+// the polling (GetRecords) consumers; otherwise the
+// SubscribeToShardEvent.MillisBehindLatest of the named enhanced fan-out consumer.
+// CheckpointLag reports the backlog of kine's own checkpointing consumers.
+func (k *Kine) ConsumerLag(streamName, consumerName string) (*Lag, error) {
+
+	lag := &Lag{
+		StreamName:   streamName,
+		ConsumerName: consumerName,
+		MetricName:   "GetRecords.IteratorAgeMilliseconds",
+	}
+	dims := map[string]string{"StreamName": streamName}
+	if consumerName != "" {
+		lag.MetricName = "SubscribeToShardEvent.MillisBehindLatest"
+		dims["ConsumerName"] = consumerName
+	}
+
+	datapoints, err := k.getMetric(lag.MetricName, dims, cloudwatch.StatisticMaximum, lagPeriod, lagLookback)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range datapoints {
+		v := time.Duration(*d.Maximum) * time.Millisecond
+		if v > lag.Max {
+			lag.Max = v
+		}
+		lag.Latest = v
+		lag.Timestamp = *d.Timestamp
+	}
+
+	if consumerName != "" {
+		return lag, nil
+	}
+
+	enabled, err := k.shardMetricEnabled(streamName, kinesis.MetricsNameIteratorAgeMilliseconds)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return lag, nil
+	}
+
+	shards, err := k.openShards(streamName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	lag.Shards = make(map[string]time.Duration)
+	for _, shard := range shards {
+		datapoints, err := k.getMetric(kinesis.MetricsNameIteratorAgeMilliseconds, map[string]string{
+			"StreamName": streamName,
+			"ShardId":    *shard.ShardId,
+		}, cloudwatch.StatisticMaximum, lagPeriod, lagLookback)
+		if err != nil {
+			return nil, err
+		}
+		if len(datapoints) > 0 {
+			lag.Shards[*shard.ShardId] = time.Duration(*datapoints[len(datapoints)-1].Maximum) * time.Millisecond
+		}
+	}
+
+	return lag, nil
+}
+
+// CheckpointLag is how far a consumer checkpointing with kine is behind the
+// tip of a shard.
+type CheckpointLag struct {
+	ShardID string
+	// Checkpoint is the sequence number of the last record handled, empty
+	// when the shard has no checkpoint.
+	Checkpoint string
+	// Tip is the sequence number of the last record of the shard, empty
+	// when it received no record for a day.
+	Tip string
+	// Distance is Tip minus Checkpoint, nil when either is unknown.
+	// Sequence numbers grow with time but are not dense, so it compares
+	// shards and points in time rather than counting records.
+	Distance *big.Int
+	// Behind is how far the records after the checkpoint are behind the
+	// tip, as reported by GetRecords.
+	Behind time.Duration
+}
+
+// CheckpointLag reports, by shard ID, how far the consumer checkpointing in
+// store is behind the tip of every open shard, complementing the iterator
+// age of ConsumerLag with the backlog of the consumer itself.
+func (k *Kine) CheckpointLag(streamName string, store CheckpointStore) (map[string]*CheckpointLag, error) {
+
+	tips, err := k.Peek(streamName, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	lags := make(map[string]*CheckpointLag, len(tips))
+	for shardID, records := range tips {
+		lag := &CheckpointLag{ShardID: shardID}
+		lags[shardID] = lag
+		if len(records) > 0 {
+			lag.Tip = records[0].SequenceNumber
+		}
+
+		lag.Checkpoint, err = store.GetCheckpoint(context.Background(), streamName, shardID)
+		if err != nil {
+			return nil, err
+		}
+		if lag.Checkpoint == "" || lag.Checkpoint == ShardEnd {
+			lag.Checkpoint = ""
+			continue
+		}
+
+		if lag.Tip != "" {
+			tip, ok1 := new(big.Int).SetString(lag.Tip, 10)
+			checkpoint, ok2 := new(big.Int).SetString(lag.Checkpoint, 10)
+			if ok1 && ok2 {
+				lag.Distance = tip.Sub(tip, checkpoint)
+			}
+		}
+
+		it, err := k.svc.GetShardIterator(&kinesis.GetShardIteratorInput{
+			StreamName:             aws.String(streamName),
+			ShardId:                aws.String(shardID),
+			ShardIteratorType:      aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber),
+			StartingSequenceNumber: aws.String(lag.Checkpoint),
+		})
+		if err != nil {
+			return nil, err
+		}
+		out, err := k.svc.GetRecords(&kinesis.GetRecordsInput{
+			ShardIterator: it.ShardIterator,
+			Limit:         aws.Int64(1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		lag.Behind = time.Duration(aws.Int64Value(out.MillisBehindLatest)) * time.Millisecond
+	}
+	return lags, nil
+}
+
+func (k *Kine) shardMetricEnabled(streamName, metricName string) (bool, error) {
+	stream, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range stream.StreamDescriptionSummary.EnhancedMonitoring {
+		for _, name := range m.ShardLevelMetrics {
+			if *name == metricName || *name == kinesis.MetricsNameAll {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
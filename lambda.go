@@ -0,0 +1,74 @@
+package kine
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// EventSourceMapping is a Lambda function consuming a stream.
+//
+// The parallelization factor is not reported: it is not known to the SDK
+// version kine is built with, and is always 1 as far as kine can tell.
+type EventSourceMapping struct {
+	UUID                 string
+	FunctionARN          string
+	State                string
+	BatchSize            int
+	LastModified         time.Time
+	LastProcessingResult string
+}
+
+// Failing reports whether Lambda could not process the last batch,
+// e.g. "PROBLEM: Function call failed".
+func (m EventSourceMapping) Failing() bool {
+	return strings.HasPrefix(m.LastProcessingResult, "PROBLEM")
+}
+
+// EventSourceMappings lists the Lambda functions attached to the stream.
+func (k *Kine) EventSourceMappings(streamName string) ([]EventSourceMapping, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []EventSourceMapping
+	err = k.lambda.ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		EventSourceArn: out.StreamDescriptionSummary.StreamARN,
+	}, func(page *lambda.ListEventSourceMappingsOutput, lastPage bool) bool {
+		for _, m := range page.EventSourceMappings {
+			mappings = append(mappings, EventSourceMapping{
+				UUID:                 aws.StringValue(m.UUID),
+				FunctionARN:          aws.StringValue(m.FunctionArn),
+				State:                aws.StringValue(m.State),
+				BatchSize:            int(aws.Int64Value(m.BatchSize)),
+				LastModified:         aws.TimeValue(m.LastModified),
+				LastProcessingResult: aws.StringValue(m.LastProcessingResult),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// eventSourceMappings lists the Lambda functions attached to the stream for
+// the reports, which leave them out when they cannot be listed.
+func (k *Kine) eventSourceMappings(streamName string) []EventSourceMapping {
+	mappings, err := k.EventSourceMappings(streamName)
+	if err != nil {
+		log.Printf("kine: event source mappings of %s: %v", streamName, err)
+		return nil
+	}
+	return mappings
+}
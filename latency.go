@@ -0,0 +1,232 @@
+package kine
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// latencyMagic starts the data of the markers of MeasureLatency, followed by
+// the 8-byte ID of the measurement and the 8-byte send time in Unix nanoseconds.
+const latencyMagic = "\x00kl"
+
+const (
+	defaultLatencyMarkers  = 100
+	defaultLatencyInterval = 100 * time.Millisecond
+	// latencyDrain is how long the markers may take to arrive after the
+	// last one was sent.
+	latencyDrain = 30 * time.Second
+)
+
+type latencyConfig struct {
+	markers  int
+	interval time.Duration
+	producer []ProducerOption
+	consumer []ConsumerOption
+}
+
+type LatencyOption func(c *latencyConfig)
+
+// WithMarkers sets the number of markers sent, 100 by default.
+func WithMarkers(n int) LatencyOption {
+	return func(c *latencyConfig) {
+		c.markers = n
+	}
+}
+
+// WithMarkerInterval sets the delay between markers, 100ms by default.
+func WithMarkerInterval(d time.Duration) LatencyOption {
+	return func(c *latencyConfig) {
+		c.interval = d
+	}
+}
+
+// WithLatencyProducer sets the options of the producer sending the markers,
+// e.g. to measure the effect of compression or buffering.
+func WithLatencyProducer(opts ...ProducerOption) LatencyOption {
+	return func(c *latencyConfig) {
+		c.producer = opts
+	}
+}
+
+// WithLatencyConsumer sets the options of the consumer receiving the markers.
+func WithLatencyConsumer(opts ...ConsumerOption) LatencyOption {
+	return func(c *latencyConfig) {
+		c.consumer = opts
+	}
+}
+
+// LatencySummary is the distribution of the latencies of some markers.
+type LatencySummary struct {
+	Count              int
+	P50, P90, P99, Max time.Duration
+}
+
+func summarizeLatencies(latencies []time.Duration) LatencySummary {
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return latencies[i]
+	}
+	return LatencySummary{
+		Count: len(latencies),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+		Max:   percentile(1),
+	}
+}
+
+// LatencyReport is the end-to-end latency from Put in a producer to the
+// handler of a consumer.
+type LatencyReport struct {
+	StreamName string
+	Sent       int
+	Overall    LatencySummary
+	Shards     map[string]LatencySummary
+}
+
+// Render writes the report as a table, one row per shard and the total.
+func (r *LatencyReport) Render(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"shard", "markers", "p50", "p90", "p99", "max"})
+	row := func(name string, s LatencySummary) []string {
+		return []string{name, fmt.Sprintf("%d", s.Count),
+			s.P50.String(), s.P90.String(), s.P99.String(), s.Max.String()}
+	}
+
+	shards := make([]string, 0, len(r.Shards))
+	for shardID := range r.Shards {
+		shards = append(shards, shardID)
+	}
+	sort.Strings(shards)
+	for _, shardID := range shards {
+		table.Append(row(shardID, r.Shards[shardID]))
+	}
+	table.SetFooter(row(fmt.Sprintf("total (%d sent)", r.Sent), r.Overall))
+	table.Render()
+}
+
+// MeasureLatency sends timestamped markers to every shard of the stream in
+// turn and measures when a consumer receives them. The consumer ignores the
+// other records, so it can be run on a live stream, whose consumers must
+// in turn tolerate the markers.
+func (k *Kine) MeasureLatency(ctx context.Context, streamName string, opts ...LatencyOption) (*LatencyReport, error) {
+	c := &latencyConfig{
+		markers:  defaultLatencyMarkers,
+		interval: defaultLatencyInterval,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	prefix := append([]byte(latencyMagic), id...)
+
+	var mu sync.Mutex
+	latencies := make(map[string][]time.Duration)
+	received := 0
+	all := make(chan struct{})
+
+	handler := func(ctx context.Context, r *Record) error {
+		if len(r.Data) != len(prefix)+8 || !bytes.HasPrefix(r.Data, prefix) {
+			return nil
+		}
+		sent := time.Unix(0, int64(binary.BigEndian.Uint64(r.Data[len(prefix):])))
+		latency := time.Since(sent)
+
+		mu.Lock()
+		defer mu.Unlock()
+		latencies[r.ShardID] = append(latencies[r.ShardID], latency)
+		received++
+		if received == c.markers {
+			close(all)
+		}
+		return nil
+	}
+
+	// markers are sent after now, so none is missed while the consumer starts
+	consumerOpts := append([]ConsumerOption{WithStartPosition(StartAtTimestamp(time.Now()))}, c.consumer...)
+	consumer := k.NewConsumer(streamName, handler, consumerOpts...)
+	consumed := make(chan error, 1)
+	go func() {
+		consumed <- consumer.Run(ctx)
+	}()
+
+	producerOpts := append([]ProducerOption{WithPartitionKeys(KeyRoundRobin)}, c.producer...)
+	producer := k.NewProducer(streamName, producerOpts...)
+	err := sendMarkers(ctx, producer, prefix, c)
+	if cerr := producer.Close(ctx); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		drain := time.NewTimer(latencyDrain)
+		select {
+		case <-all:
+		case <-drain.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+		case err = <-consumed:
+		}
+		drain.Stop()
+	}
+	if serr := consumer.Shutdown(context.Background()); err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	report := &LatencyReport{
+		StreamName: streamName,
+		Sent:       c.markers,
+		Shards:     make(map[string]LatencySummary, len(latencies)),
+	}
+	var overall []time.Duration
+	for shardID, l := range latencies {
+		overall = append(overall, l...)
+		report.Shards[shardID] = summarizeLatencies(l)
+	}
+	report.Overall = summarizeLatencies(overall)
+	return report, nil
+}
+
+func sendMarkers(ctx context.Context, p *Producer, prefix []byte, c *latencyConfig) error {
+	for i := 0; i < c.markers; i++ {
+		if i > 0 {
+			if err := p.k.sleep(ctx, c.interval); err != nil {
+				return err
+			}
+		}
+		marker := make([]byte, len(prefix)+8)
+		copy(marker, prefix)
+		binary.BigEndian.PutUint64(marker[len(prefix):], uint64(time.Now().UnixNano()))
+		if err := p.Put(ctx, "", marker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
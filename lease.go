@@ -0,0 +1,559 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	defaultLeaseTTL = 30 * time.Second
+	// handoffPollInterval is how often workers look for leases to take over
+	// while another worker hands its leases off.
+	handoffPollInterval = time.Second
+)
+
+// Leases spread the shards of a stream across the consumer processes, the
+// workers, sharing a DynamoDB table with the string partition key
+// "StreamName" and the string sort key "ShardID", e.g. the checkpoint
+// table. Each worker renews its leases and rebalances every TTL/3: it takes
+// the shards nobody holds, and steals one shard at a time from the most
+// loaded worker until every worker holds its even share. The leases of a
+// worker which stops renewing them expire after TTL and are taken over.
+//
+// A worker shut down hands its leases off instead: it marks them for
+// transfer, which makes the other workers poll every second and leave it out
+// of the even shares, then releases each of them once its shard is
+// checkpointed, so a successor resumes from that checkpoint right away.
+type Leases struct {
+	TableName string
+	// Worker identifies the consumer process; it defaults to hostname:pid.
+	Worker string
+	// TTL defaults to 30 seconds.
+	TTL time.Duration
+}
+
+// WithLeases makes the consumer read only the shards it holds the lease of.
+// The workers must share a checkpoint store, where a new owner resumes a
+// shard; a shard moved to another worker may see the records since its
+// last checkpoint again.
+func WithLeases(l Leases) ConsumerOption {
+	return func(c *Consumer) {
+		if l.TTL == 0 {
+			l.TTL = defaultLeaseTTL
+		}
+		if l.Worker == "" {
+			host, _ := os.Hostname()
+			l.Worker = fmt.Sprintf("%s:%d", host, os.Getpid())
+		}
+		c.leases = &leaseTable{k: c.k, streamName: c.streamName, Leases: l}
+	}
+}
+
+// errLeaseLost is returned when another worker took the lease.
+var errLeaseLost = errors.New("lease lost")
+
+type lease struct {
+	shardID string
+	owner   string
+	expires time.Time
+	handoff bool
+}
+
+type leaseTable struct {
+	Leases
+	k          *Kine
+	streamName string
+}
+
+func (t *leaseTable) key(shardID string) map[string]*dynamodb.AttributeValue {
+	return checkpointKey(t.streamName, shardID)
+}
+
+func (t *leaseTable) list(ctx context.Context) ([]lease, error) {
+	var leases []lease
+	err := t.k.ddb.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(t.TableName),
+		KeyConditionExpression: aws.String("StreamName = :stream"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":stream": {S: aws.String(t.streamName)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}, func(out *dynamodb.QueryOutput, last bool) bool {
+		for _, item := range out.Items {
+			l := lease{shardID: aws.StringValue(item["ShardID"].S)}
+			if v, ok := item["Owner"]; ok {
+				l.owner = aws.StringValue(v.S)
+			}
+			if _, ok := item["Handoff"]; ok {
+				l.handoff = true
+			}
+			if v, ok := item["Expires"]; ok {
+				ms, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+				l.expires = time.Unix(0, ms*int64(time.Millisecond))
+			}
+			leases = append(leases, l)
+		}
+		return true
+	})
+	return leases, err
+}
+
+// update sets this worker as the owner of the lease for another TTL, under
+// the condition, mapping a failed condition to errLeaseLost. A lease taken
+// over is not handed off anymore.
+func (t *leaseTable) update(ctx context.Context, shardID, condition string, values map[string]*dynamodb.AttributeValue) error {
+	now := t.k.clock.Now()
+	values[":me"] = &dynamodb.AttributeValue{S: aws.String(t.Worker)}
+	values[":expires"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(t.TTL).UnixNano()/int64(time.Millisecond), 10))}
+	_, err := t.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.TableName),
+		Key:                 t.key(shardID),
+		UpdateExpression:    aws.String("SET #owner = :me, Expires = :expires REMOVE Handoff"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: values,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errLeaseLost
+	}
+	return err
+}
+
+// take acquires a lease nobody holds, or an expired one.
+func (t *leaseTable) take(ctx context.Context, shardID string) error {
+	now := t.k.clock.Now().UnixNano() / int64(time.Millisecond)
+	return t.update(ctx, shardID, "attribute_not_exists(#owner) OR #owner = :me OR Expires < :now", map[string]*dynamodb.AttributeValue{
+		":now": {N: aws.String(strconv.FormatInt(now, 10))},
+	})
+}
+
+// steal takes the lease from its current owner.
+func (t *leaseTable) steal(ctx context.Context, shardID, owner string) error {
+	return t.update(ctx, shardID, "#owner = :owner", map[string]*dynamodb.AttributeValue{
+		":owner": {S: aws.String(owner)},
+	})
+}
+
+func (t *leaseTable) renew(ctx context.Context, shardID string) error {
+	return t.update(ctx, shardID, "#owner = :me", map[string]*dynamodb.AttributeValue{})
+}
+
+// hold checks that the worker still holds the lease, extending it without
+// changing its handoff mark.
+func (t *leaseTable) hold(ctx context.Context, shardID string) error {
+	expires := t.k.clock.Now().Add(t.TTL).UnixNano() / int64(time.Millisecond)
+	_, err := t.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.TableName),
+		Key:                 t.key(shardID),
+		UpdateExpression:    aws.String("SET Expires = :expires"),
+		ConditionExpression: aws.String("#owner = :me"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":me":      {S: aws.String(t.Worker)},
+			":expires": {N: aws.String(strconv.FormatInt(expires, 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errLeaseLost
+	}
+	return err
+}
+
+// markHandoff marks the lease for transfer to another worker.
+func (t *leaseTable) markHandoff(ctx context.Context, shardID string) error {
+	_, err := t.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.TableName),
+		Key:                 t.key(shardID),
+		UpdateExpression:    aws.String("SET Handoff = :me"),
+		ConditionExpression: aws.String("#owner = :me"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":me": {S: aws.String(t.Worker)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return errLeaseLost
+	}
+	return err
+}
+
+// release gives the lease up, keeping the item and its checkpoint.
+func (t *leaseTable) release(ctx context.Context, shardID string) error {
+	_, err := t.k.ddb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.TableName),
+		Key:                 t.key(shardID),
+		UpdateExpression:    aws.String("REMOVE #owner, Expires, Handoff"),
+		ConditionExpression: aws.String("#owner = :me"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":me": {S: aws.String(t.Worker)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+// leasedShard is a shard read by this worker.
+type leasedShard struct {
+	cancel context.CancelFunc
+	lost   bool
+}
+
+type leaseResult struct {
+	shardID string
+	ended   bool
+	err     error
+}
+
+// leaseState is what runLeased knows about the shards of the stream.
+type leaseState struct {
+	shards map[string]*kinesis.Shard
+	// finished are the shards read to their end, or skipped because they
+	// were closed when the consumer started from the latest records.
+	finished map[string]bool
+	skipped  map[string]bool
+	// stopped are the shards this worker stopped with FailureStopShard,
+	// left to the other workers.
+	stopped map[string]bool
+}
+
+// runLeased consumes the shards this worker holds the leases of, renewing
+// and rebalancing them until ctx is done, a shard fails or the consumer is
+// shut down, when it hands the leases off.
+func (c *Consumer) runLeased(ctx context.Context) error {
+	if c.checkpoint == nil {
+		return errors.New("leases need a checkpoint store shared by the workers")
+	}
+	t := c.leases
+
+	state := &leaseState{
+		shards:   make(map[string]*kinesis.Shard),
+		finished: make(map[string]bool),
+		skipped:  make(map[string]bool),
+		stopped:  make(map[string]bool),
+	}
+	if err := state.refresh(c.k, c.streamName); err != nil {
+		return err
+	}
+	if c.start.Type == kinesis.ShardIteratorTypeLatest {
+		if err := state.skipClosed(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	if c.heartbeat != nil {
+		go c.heartbeat.run(ctx, c.streamName)
+	}
+
+	owned := make(map[string]*leasedShard)
+	results := make(chan leaseResult)
+	var runErr error
+
+	if c.standby != nil {
+		if err := c.standby.validate(t.TTL); err != nil {
+			return err
+		}
+		leases, err := c.standBy(ctx, state)
+		if err != nil || leases == nil {
+			return err
+		}
+		if err := c.takeOver(ctx, state, leases, owned, results); err != nil {
+			return err
+		}
+	}
+	handingOff := false
+
+	for len(owned) > 0 || (runErr == nil && ctx.Err() == nil && !c.stopping()) {
+		// once shutting down, only wait for the shards in flight
+		stop, done := c.stop, ctx.Done()
+		interval := t.TTL / 3
+		if runErr == nil && ctx.Err() == nil && !c.stopping() {
+			handoffs, err := c.balanceLeases(ctx, state, owned, results)
+			if err != nil {
+				log.Printf("kine: leases of %s: %v", c.streamName, err)
+			}
+			if handoffs && handoffPollInterval < interval {
+				interval = handoffPollInterval
+			}
+		} else {
+			stop, done = nil, nil
+			if runErr == nil && ctx.Err() == nil && !handingOff {
+				handingOff = true
+				c.markHandoffs(ctx, owned)
+			}
+		}
+
+		timer := c.k.clock.NewTimer(interval)
+		select {
+		case r := <-results:
+			s := owned[r.shardID]
+			delete(owned, r.shardID)
+			switch {
+			case s.lost:
+			case r.err == errLeaseLost:
+				log.Printf("kine: lease of %s %s taken by another worker", c.streamName, r.shardID)
+			case r.err != nil:
+				if runErr == nil && ctx.Err() == nil {
+					runErr = r.err
+					for _, o := range owned {
+						o.cancel()
+					}
+				}
+			case r.ended:
+				state.finished[r.shardID] = true
+				if err := t.release(context.Background(), r.shardID); err != nil {
+					log.Printf("kine: release lease of %s %s: %v", c.streamName, r.shardID, err)
+				}
+			default:
+				// handed off or stopped by FailureStopShard, the records
+				// handled are checkpointed
+				if !c.stopping() {
+					state.stopped[r.shardID] = true
+				}
+				if err := t.release(context.Background(), r.shardID); err != nil {
+					log.Printf("kine: release lease of %s %s: %v", c.streamName, r.shardID, err)
+				}
+			}
+		case <-stop:
+		case <-done:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	return ctx.Err()
+}
+
+// markHandoffs marks the leases of the worker for transfer, while the shards
+// in flight are checkpointed.
+func (c *Consumer) markHandoffs(ctx context.Context, owned map[string]*leasedShard) {
+	for id, s := range owned {
+		if s.lost {
+			continue
+		}
+		if err := c.leases.markHandoff(ctx, id); err != nil && err != errLeaseLost {
+			log.Printf("kine: hand off lease of %s %s: %v", c.streamName, id, err)
+		}
+	}
+}
+
+// skipClosed skips the closed shards nobody read, as the records of closed
+// shards are older than the latest ones. A closed shard leased by a worker
+// is read to its end first, see skipClosedShards.
+func (s *leaseState) skipClosed(ctx context.Context, c *Consumer) error {
+	leases, err := c.leases.list(ctx)
+	if err != nil {
+		return err
+	}
+	owners := make(map[string]string)
+	for _, l := range leases {
+		owners[l.shardID] = l.owner
+	}
+	return skipClosedShards(ctx, c, s.shards, owners, s.finished, s.skipped)
+}
+
+// refresh lists the shards of the stream.
+func (s *leaseState) refresh(k *Kine, streamName string) error {
+	return k.listShards(streamName, func(page []*kinesis.Shard) error {
+		for _, shard := range page {
+			s.shards[*shard.ShardId] = shard
+		}
+		return nil
+	})
+}
+
+// available returns the shards which can be read now: not finished, with
+// their parents finished.
+func (s *leaseState) available(ctx context.Context, c *Consumer) (map[string]bool, error) {
+	done := func(id string) (bool, error) {
+		if _, ok := s.shards[id]; !ok || s.finished[id] {
+			// parents which are not known anymore have expired
+			return true, nil
+		}
+		if s.shards[id].SequenceNumberRange.EndingSequenceNumber == nil {
+			return false, nil
+		}
+		seq, err := c.checkpoint.GetCheckpoint(ctx, c.streamName, id)
+		if err != nil {
+			return false, err
+		}
+		s.finished[id] = seq == ShardEnd
+		return s.finished[id], nil
+	}
+
+	available := make(map[string]bool)
+	for id, shard := range s.shards {
+		ok, err := done(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		ready := true
+		for _, parent := range []*string{shard.ParentShardId, shard.AdjacentParentShardId} {
+			if parent == nil {
+				continue
+			}
+			ok, err := done(*parent)
+			if err != nil {
+				return nil, err
+			}
+			ready = ready && ok
+		}
+		if ready {
+			available[id] = true
+		}
+	}
+	return available, nil
+}
+
+// balanceLeases renews the leases of the worker, takes free ones up to its
+// share and steals one when still short, starting to read the shards taken.
+// It reports whether another worker is handing leases off.
+func (c *Consumer) balanceLeases(ctx context.Context, state *leaseState, owned map[string]*leasedShard, results chan<- leaseResult) (bool, error) {
+	t := c.leases
+
+	for id, s := range owned {
+		if s.lost {
+			continue
+		}
+		if err := t.renew(ctx, id); err == errLeaseLost {
+			log.Printf("kine: lease of %s %s taken by another worker", c.streamName, id)
+			s.lost = true
+			s.cancel()
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	if err := state.refresh(c.k, c.streamName); err != nil {
+		return false, err
+	}
+	available, err := state.available(ctx, c)
+	if err != nil {
+		return false, err
+	}
+	for id := range state.stopped {
+		delete(available, id)
+	}
+	leases, err := t.list(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	now := c.k.clock.Now()
+	held := make(map[string]lease)
+	counts := map[string]int{t.Worker: 0}
+	handoffs := false
+	for _, l := range leases {
+		if available[l.shardID] && l.owner != "" && now.Before(l.expires) {
+			held[l.shardID] = l
+			if l.handoff {
+				// the leases are shared out among the other workers
+				handoffs = true
+				continue
+			}
+			counts[l.owner]++
+		}
+	}
+	mine := 0
+	for _, s := range owned {
+		if !s.lost {
+			mine++
+		}
+	}
+	target := (len(available) + len(counts) - 1) / len(counts)
+
+	take := func(id string, steal func() error) error {
+		if err := steal(); err == errLeaseLost {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.startLeased(ctx, state, owned, results, id)
+		mine++
+		return nil
+	}
+
+	for id := range available {
+		if mine >= target {
+			break
+		}
+		if _, ok := owned[id]; ok {
+			continue
+		}
+		if _, ok := held[id]; ok {
+			continue
+		}
+		id := id
+		if err := take(id, func() error { return t.take(ctx, id) }); err != nil {
+			return handoffs, err
+		}
+	}
+
+	if mine < target {
+		// steal one shard from the most loaded worker
+		var victim string
+		for owner, n := range counts {
+			if owner != t.Worker && n > target && (victim == "" || n > counts[victim]) {
+				victim = owner
+			}
+		}
+		if victim != "" {
+			for id, l := range held {
+				if l.owner == victim && !l.handoff {
+					if err := take(id, func() error { return t.steal(ctx, id, victim) }); err != nil {
+						return handoffs, err
+					}
+					break
+				}
+			}
+		}
+	}
+	return handoffs, nil
+}
+
+// startLeased reads a shard whose lease was just taken. A shard is read
+// from its checkpoint, else children from their start and the other shards
+// from the start position.
+func (c *Consumer) startLeased(ctx context.Context, state *leaseState, owned map[string]*leasedShard, results chan<- leaseResult, shardID string) {
+	from := state.startPosition(c, shardID)
+
+	shardCtx, cancel := context.WithCancel(ctx)
+	owned[shardID] = &leasedShard{cancel: cancel}
+	go func() {
+		defer cancel()
+		ended, err := c.consumeWarm(shardCtx, shardID, from)
+		results <- leaseResult{shardID: shardID, ended: ended, err: err}
+	}()
+}
+
+// startPosition returns where a shard without checkpoint is read from:
+// children from their start, the other shards from the start position.
+func (s *leaseState) startPosition(c *Consumer, shardID string) StartPosition {
+	return startPosition(c, s.shards, s.skipped, shardID)
+}
@@ -0,0 +1,131 @@
+package kine_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/kinetest"
+)
+
+func leasedConsumer(k *kine.Kine, worker string, h *handled, checkpoints kine.CheckpointStore) *kine.Consumer {
+	return k.NewConsumer("orders", h.handle,
+		kine.WithStartPosition(kine.StartTrimHorizon),
+		kine.WithCheckpointStore(checkpoints),
+		kine.WithLeases(kine.Leases{TableName: "leases", Worker: worker}),
+	)
+}
+
+// countOwners returns the number of leases held by each worker.
+func countOwners(owners map[string]string) map[string]int {
+	counts := make(map[string]int)
+	for _, owner := range owners {
+		if owner != "" {
+			counts[owner]++
+		}
+	}
+	return counts
+}
+
+func TestLeasesBalanceAndHandOff(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 4)
+	for i := 0; i < 5; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	runClock(t, clock, 200*time.Millisecond)
+
+	h := newHandled()
+	checkpoints := kine.NewMemoryCheckpointStore()
+	run := func(c *kine.Consumer) chan error {
+		errc := make(chan error, 1)
+		go func() {
+			errc <- c.Run(context.Background())
+		}()
+		return errc
+	}
+
+	a := leasedConsumer(k, "a", h, checkpoints)
+	errA := run(a)
+	eventually(t, "a to take every lease", func() bool {
+		return countOwners(f.owners("orders"))["a"] == 4
+	})
+	eventually(t, "the first records", func() bool { return h.len() == 20 })
+
+	// a second worker steals its share of the leases
+	b := leasedConsumer(k, "b", h, checkpoints)
+	errB := run(b)
+	eventually(t, "the leases to be balanced", func() bool {
+		counts := countOwners(f.owners("orders"))
+		return counts["a"] == 2 && counts["b"] == 2
+	})
+	for i := 5; i < 10; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	eventually(t, "the records put while balanced", func() bool { return h.len() == 40 })
+
+	// a hands its leases off to b when shut down
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errA; err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "b to take the leases over", func() bool {
+		return countOwners(f.owners("orders"))["b"] == 4
+	})
+	for i := 10; i < 15; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+	eventually(t, "the records put after the handoff", func() bool { return h.len() == 60 })
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errB; err != nil {
+		t.Fatal(err)
+	}
+	if counts := countOwners(f.owners("orders")); len(counts) > 0 {
+		t.Errorf("leases held after shutdown: %v", counts)
+	}
+}
+
+func TestLeasesConcurrentWorkers(t *testing.T) {
+	clock := kinetest.NewFakeClock(time.Unix(0, 0))
+	f, k := newFakeAWS(t, clock)
+	f.createStream("orders", 6)
+	runClock(t, clock, 200*time.Millisecond)
+
+	h := newHandled()
+	checkpoints := kine.NewMemoryCheckpointStore()
+	workers := make([]*kine.Consumer, 3)
+	errs := make([]chan error, len(workers))
+	for i := range workers {
+		workers[i] = leasedConsumer(k, fmt.Sprint("worker-", i), h, checkpoints)
+		errs[i] = make(chan error, 1)
+		go func(c *kine.Consumer, errc chan error) {
+			errc <- c.Run(context.Background())
+		}(workers[i], errs[i])
+	}
+	for i := 0; i < 10; i++ {
+		f.put("orders", fmt.Sprint(i))
+	}
+
+	eventually(t, "every lease to be held", func() bool {
+		counts := countOwners(f.owners("orders"))
+		return counts["worker-0"]+counts["worker-1"]+counts["worker-2"] == 6
+	})
+	eventually(t, "every record", func() bool { return h.len() == 60 })
+
+	for i, c := range workers {
+		if err := c.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs[i]; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
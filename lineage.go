@@ -0,0 +1,172 @@
+package kine
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// lineage tracks which shards a consumer may read: a shard is read only once
+// its parents are read to their end, so that the records of a partition key
+// are handled in order across splits and merges.
+type lineage struct {
+	mu       sync.Mutex
+	shards   map[string]*kinesis.Shard
+	started  map[string]bool
+	finished map[string]bool
+	// skipped are the closed shards not read at all, see skipClosedShards
+	skipped map[string]bool
+}
+
+func newLineage(shards []*kinesis.Shard) *lineage {
+	l := &lineage{
+		shards:   make(map[string]*kinesis.Shard),
+		started:  make(map[string]bool),
+		finished: make(map[string]bool),
+		skipped:  make(map[string]bool),
+	}
+	l.add(shards)
+	return l
+}
+
+func (l *lineage) add(shards []*kinesis.Shard) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range shards {
+		l.shards[*s.ShardId] = s
+	}
+}
+
+func (l *lineage) finish(shardID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finished[shardID] = true
+}
+
+func (l *lineage) children(shardID string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var children []string
+	for id, s := range l.shards {
+		if aws.StringValue(s.ParentShardId) == shardID || aws.StringValue(s.AdjacentParentShardId) == shardID {
+			children = append(children, id)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// ready returns the shards which can be read now, and marks them as started.
+// Parents which are not known anymore have expired and do not block.
+func (l *lineage) ready() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ready []string
+	for id, s := range l.shards {
+		if l.started[id] || l.finished[id] {
+			continue
+		}
+		if l.blocks(s.ParentShardId) || l.blocks(s.AdjacentParentShardId) {
+			continue
+		}
+		l.started[id] = true
+		ready = append(ready, id)
+	}
+	sort.Strings(ready)
+	return ready
+}
+
+func (l *lineage) blocks(parentID *string) bool {
+	if parentID == nil {
+		return false
+	}
+	_, known := l.shards[*parentID]
+	return known && !l.finished[*parentID]
+}
+
+// skipClosed skips the closed shards, when the consumer starts from the
+// latest records, see skipClosedShards.
+func (l *lineage) skipClosed(ctx context.Context, c *Consumer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return skipClosedShards(ctx, c, l.shards, nil, l.finished, l.skipped)
+}
+
+// startPosition returns where a shard without checkpoint is read from.
+func (l *lineage) startPosition(c *Consumer, shardID string) StartPosition {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return startPosition(c, l.shards, l.skipped, shardID)
+}
+
+// skipClosedShards marks the closed shards to skip as finished and skipped,
+// as the records of closed shards are older than the latest ones. A closed
+// shard owned by a worker or checkpointed, or whose parent is not skipped,
+// is read to its end first, so that its records are not lost and come
+// before its children's. A closed shard checkpointed at its end is finished.
+func skipClosedShards(ctx context.Context, c *Consumer, shards map[string]*kinesis.Shard, owners map[string]string, finished, skipped map[string]bool) error {
+	visited := make(map[string]bool)
+	var visit func(id string) error
+	visit = func(id string) error {
+		shard, ok := shards[id]
+		if !ok || visited[id] {
+			return nil
+		}
+		visited[id] = true
+		skip := shard.SequenceNumberRange.EndingSequenceNumber != nil && owners[id] == ""
+		for _, parent := range []*string{shard.ParentShardId, shard.AdjacentParentShardId} {
+			if parent == nil {
+				continue
+			}
+			if err := visit(*parent); err != nil {
+				return err
+			}
+			if _, ok := shards[*parent]; ok && !skipped[*parent] {
+				skip = false
+			}
+		}
+		if !skip {
+			return nil
+		}
+		var seq string
+		if c.checkpoint != nil {
+			var err error
+			seq, err = c.checkpoint.GetCheckpoint(ctx, c.streamName, id)
+			if err != nil {
+				return err
+			}
+		}
+		switch seq {
+		case "":
+			finished[id] = true
+			skipped[id] = true
+		case ShardEnd:
+			finished[id] = true
+		}
+		return nil
+	}
+	for id := range shards {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startPosition returns where a shard without checkpoint is read from:
+// children of shards read from their start, the other shards from the start
+// position of the consumer.
+func startPosition(c *Consumer, shards map[string]*kinesis.Shard, skipped map[string]bool, shardID string) StartPosition {
+	shard := shards[shardID]
+	for _, parent := range []*string{shard.ParentShardId, shard.AdjacentParentShardId} {
+		if parent != nil && shards[*parent] != nil && !skipped[*parent] {
+			return StartTrimHorizon
+		}
+	}
+	return c.start
+}
@@ -0,0 +1,152 @@
+package kine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const defaultReshardLockTTL = 5 * time.Minute
+
+// ReshardLock is a stream-scoped lease in a DynamoDB table with the string
+// partition key "StreamName", so that two kine processes never reshard the
+// same stream at once. The lease is renewed while the operation runs and
+// expires after TTL when its owner dies.
+type ReshardLock struct {
+	TableName string
+	// TTL defaults to five minutes.
+	TTL time.Duration
+	// Owner identifies the lock holder in a *LockedError; it defaults to
+	// hostname:pid.
+	Owner string
+}
+
+// LockedError is returned when another owner holds the lock of the stream.
+type LockedError struct {
+	StreamName string
+	Owner      string
+	Expires    time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s: locked by %s until %s", e.StreamName, e.Owner, e.Expires.Format(time.RFC3339))
+}
+
+// WithReshardLock makes Apply, ScaleTo, SplitShardAt, HalveShard and
+// DoubleShard hold the lock of the stream while they run, failing with a
+// *LockedError when another owner holds it.
+func WithReshardLock(l ReshardLock) KineOption {
+	return OptionFn(func(k *Kine) error {
+		if l.TTL == 0 {
+			l.TTL = defaultReshardLockTTL
+		}
+		if l.Owner == "" {
+			host, _ := os.Hostname()
+			l.Owner = fmt.Sprintf("%s:%d", host, os.Getpid())
+		}
+		k.reshardLock = &l
+		return nil
+	})
+}
+
+// lockReshard acquires the lock of the stream and returns the function
+// releasing it. Without a ReshardLock it does nothing.
+func (k *Kine) lockReshard(streamName string) (func(), error) {
+	l := k.reshardLock
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if err := k.putLease(l, streamName); err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			timer := k.clock.NewTimer(l.TTL / 3)
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+			if err := k.putLease(l, streamName); err != nil {
+				log.Printf("kine: renew lock of %s: %v", streamName, err)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		_, err := k.ddb.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName:           aws.String(l.TableName),
+			Key:                 map[string]*dynamodb.AttributeValue{"StreamName": {S: aws.String(streamName)}},
+			ConditionExpression: aws.String("#owner = :owner"),
+			ExpressionAttributeNames: map[string]*string{
+				"#owner": aws.String("Owner"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":owner": {S: aws.String(l.Owner)},
+			},
+		})
+		if err != nil {
+			log.Printf("kine: release lock of %s: %v", streamName, err)
+		}
+	}, nil
+}
+
+// putLease takes or renews the lease, unless another owner holds an
+// unexpired one.
+func (k *Kine) putLease(l *ReshardLock, streamName string) error {
+	now := k.clock.Now()
+	_, err := k.ddb.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(l.TableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"StreamName": {S: aws.String(streamName)},
+			"Owner":      {S: aws.String(l.Owner)},
+			"Expires":    {N: aws.String(strconv.FormatInt(now.Add(l.TTL).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(StreamName) OR #owner = :owner OR Expires < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(l.Owner)},
+			":now":   {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return k.lockedError(l, streamName)
+	}
+	return err
+}
+
+func (k *Kine) lockedError(l *ReshardLock, streamName string) error {
+	out, err := k.ddb.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(l.TableName),
+		Key:            map[string]*dynamodb.AttributeValue{"StreamName": {S: aws.String(streamName)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	e := &LockedError{StreamName: streamName}
+	if v, ok := out.Item["Owner"]; ok {
+		e.Owner = aws.StringValue(v.S)
+	}
+	if v, ok := out.Item["Expires"]; ok {
+		sec, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		e.Expires = time.Unix(sec, 0)
+	}
+	return e
+}
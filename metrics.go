@@ -0,0 +1,156 @@
+package kine
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics receives the measurements of producers and consumers. Labels are
+// given as key/value pairs, e.g. "stream", "events", "shard", "shardId-0".
+type Metrics interface {
+	// Add adds value to a counter.
+	Add(name string, value float64, labels ...string)
+	// Set sets a gauge.
+	Set(name string, value float64, labels ...string)
+	// Observe records a sample of a distribution, e.g. a latency.
+	Observe(name string, value float64, labels ...string)
+}
+
+// Metric names reported by producers and consumers.
+const (
+	MetricRecordsSent     = "kine_producer_records_sent_total"
+	MetricBytesSent       = "kine_producer_bytes_sent_total"
+	MetricPutThrottles    = "kine_producer_throttles_total"
+	MetricPutRetries      = "kine_producer_retries_total"
+	MetricBatchSize       = "kine_producer_batch_records"
+	MetricRecordsReceived = "kine_consumer_records_total"
+	MetricConsumerLag     = "kine_consumer_millis_behind_latest"
+	MetricHandlerLatency  = "kine_consumer_handler_seconds"
+)
+
+type nopMetrics struct{}
+
+func (nopMetrics) Add(name string, value float64, labels ...string)     {}
+func (nopMetrics) Set(name string, value float64, labels ...string)     {}
+func (nopMetrics) Observe(name string, value float64, labels ...string) {}
+
+type promKind int
+
+const (
+	promCounter promKind = iota
+	promGauge
+	promSummary
+)
+
+type promSeries struct {
+	labels string
+	value  float64
+	count  float64
+}
+
+type promMetric struct {
+	kind   promKind
+	series map[string]*promSeries
+}
+
+// PrometheusMetrics keeps the metrics in memory and serves them in the
+// Prometheus text format, e.g. with http.Handle("/metrics", m).
+// Observed samples are exposed as summaries without quantiles.
+type PrometheusMetrics struct {
+	mu      sync.Mutex
+	metrics map[string]*promMetric
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{metrics: make(map[string]*promMetric)}
+}
+
+func (m *PrometheusMetrics) series(kind promKind, name string, labels []string) *promSeries {
+	metric, ok := m.metrics[name]
+	if !ok {
+		metric = &promMetric{kind: kind, series: make(map[string]*promSeries)}
+		m.metrics[name] = metric
+	}
+	key := formatLabels(labels)
+	s, ok := metric.series[key]
+	if !ok {
+		s = &promSeries{labels: key}
+		metric.series[key] = s
+	}
+	return s
+}
+
+func (m *PrometheusMetrics) Add(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series(promCounter, name, labels).value += value
+}
+
+func (m *PrometheusMetrics) Set(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series(promGauge, name, labels).value = value
+}
+
+func (m *PrometheusMetrics) Observe(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.series(promSummary, name, labels)
+	s.value += value
+	s.count++
+}
+
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.metrics))
+	for name := range m.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metric := m.metrics[name]
+		keys := make([]string, 0, len(metric.series))
+		for key := range metric.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		switch metric.kind {
+		case promCounter:
+			fmt.Fprintf(w, "# TYPE %s counter\n", name)
+		case promGauge:
+			fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		case promSummary:
+			fmt.Fprintf(w, "# TYPE %s summary\n", name)
+		}
+		for _, key := range keys {
+			s := metric.series[key]
+			if metric.kind == promSummary {
+				fmt.Fprintf(w, "%s_sum%s %g\n", name, s.labels, s.value)
+				fmt.Fprintf(w, "%s_count%s %g\n", name, s.labels, s.count)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s %g\n", name, s.labels, s.value)
+		}
+	}
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], v))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
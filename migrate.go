@@ -0,0 +1,137 @@
+package kine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// migrationCheckInterval is how often a migration checks its lag.
+const migrationCheckInterval = time.Second
+
+// Migration moves the traffic of a stream to a new one, e.g. to reshard a
+// stream into an arbitrary topology or to change settings which require
+// recreating it. The steps are:
+//
+//  1. Migrate creates the target and replicates the source into it
+//  2. wait for CutOver, when the replication has caught up
+//  3. switch the producers to the target
+//  4. Finish copies the records still arriving on the source and stops
+//  5. switch the consumers to the target
+type Migration struct {
+	Source string
+	Target StreamSpec
+
+	replicator *Replicator
+	cutOverLag time.Duration
+
+	cutOver     chan struct{}
+	cutOverOnce sync.Once
+	stop        chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+	err         error
+}
+
+// Migrate creates the target stream, or converges it to the spec when it
+// exists, starts replicating sourceStream into it from TRIM_HORIZON and
+// signals CutOver once the replication lag is at most cutOverLag.
+func (k *Kine) Migrate(sourceStream string, target StreamSpec, cutOverLag time.Duration) (*Migration, error) {
+
+	if _, err := k.EnsureStream(target); err != nil {
+		return nil, err
+	}
+
+	m := &Migration{
+		Source:     sourceStream,
+		Target:     target,
+		replicator: k.NewReplicator(sourceStream, target.Name),
+		cutOverLag: cutOverLag,
+		cutOver:    make(chan struct{}),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := m.replicator.begin(); err != nil {
+		return nil, err
+	}
+	go m.run()
+	go m.watch()
+	return m, nil
+}
+
+func (m *Migration) run() {
+	m.err = m.replicator.run(context.Background())
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	close(m.done)
+}
+
+func (m *Migration) watch() {
+	t := time.NewTicker(migrationCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+		if lag, ok := m.replicator.Lag(); ok && lag <= m.cutOverLag {
+			m.cutOverOnce.Do(func() {
+				close(m.cutOver)
+			})
+			return
+		}
+	}
+}
+
+// Lag returns the replication lag, false before it is known.
+func (m *Migration) Lag() (time.Duration, bool) {
+	return m.replicator.Lag()
+}
+
+// Copied returns the number of records copied to the target so far.
+func (m *Migration) Copied() int64 {
+	return m.replicator.Copied()
+}
+
+// CutOver is closed when the replication has caught up, i.e. when the
+// producers can be switched to the target.
+func (m *Migration) CutOver() <-chan struct{} {
+	return m.cutOver
+}
+
+// Done is closed when the replication stopped, by Finish or an error.
+func (m *Migration) Done() <-chan struct{} {
+	return m.done
+}
+
+// Finish must be called after the producers were switched. It waits until
+// the replication reached the tip of the source, so that the records they
+// put last into the source are copied, stops it and returns its error.
+// When ctx is done first, the replication is stopped anyway and ctx.Err()
+// is returned.
+func (m *Migration) Finish(ctx context.Context) error {
+	switched := time.Now()
+	t := time.NewTicker(migrationCheckInterval)
+	defer t.Stop()
+	for {
+		if m.replicator.lag.caughtUp(switched) {
+			break
+		}
+		select {
+		case <-m.done:
+			return m.err
+		case <-ctx.Done():
+			m.replicator.Shutdown(context.Background())
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	if err := m.replicator.Shutdown(ctx); err != nil {
+		return err
+	}
+	<-m.done
+	return m.err
+}
@@ -0,0 +1,188 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+type Alert struct {
+	StreamName string
+	Condition  string
+	Value      float64
+	Threshold  float64
+	Time       time.Time
+}
+
+func (a *Alert) String() string {
+	return fmt.Sprintf("%s: %s is %.2f (threshold %.2f)", a.StreamName, a.Condition, a.Value, a.Threshold)
+}
+
+// Condition is evaluated by Monitor on every tick. It returns nil when the
+// stream is fine.
+type Condition interface {
+	Check(k *Kine, streamName string) (*Alert, error)
+}
+
+type ConditionFn func(k *Kine, streamName string) (*Alert, error)
+
+func (f ConditionFn) Check(k *Kine, streamName string) (*Alert, error) {
+	return f(k, streamName)
+}
+
+const throttleLookback = 5 * time.Minute
+
+// WriteThrottlesAbove alerts when more than n writes were throttled
+// during the last five minutes.
+func WriteThrottlesAbove(n int) Condition {
+	return ConditionFn(func(k *Kine, streamName string) (*Alert, error) {
+		datapoints, err := k.getMetric(kinesis.MetricsNameWriteProvisionedThroughputExceeded,
+			map[string]string{"StreamName": streamName},
+			cloudwatch.StatisticSum, time.Minute, throttleLookback)
+		if err != nil {
+			return nil, err
+		}
+
+		var sum float64
+		for _, d := range datapoints {
+			sum += *d.Sum
+		}
+		if sum <= float64(n) {
+			return nil, nil
+		}
+		return &Alert{
+			StreamName: streamName,
+			Condition:  "write throttles",
+			Value:      sum,
+			Threshold:  float64(n),
+		}, nil
+	})
+}
+
+// IteratorAgeAbove alerts when the latest iterator age of the polling
+// consumers exceeds d.
+func IteratorAgeAbove(d time.Duration) Condition {
+	return ConditionFn(func(k *Kine, streamName string) (*Alert, error) {
+		lag, err := k.ConsumerLag(streamName, "")
+		if err != nil {
+			return nil, err
+		}
+		if lag.Latest <= d {
+			return nil, nil
+		}
+		return &Alert{
+			StreamName: streamName,
+			Condition:  "iterator age (ms)",
+			Value:      float64(lag.Latest / time.Millisecond),
+			Threshold:  float64(d / time.Millisecond),
+		}, nil
+	})
+}
+
+// ShardSkewAbove alerts when the widest open shard covers more than
+// percent % above the even share of the hash space.
+func ShardSkewAbove(percent float64) Condition {
+	return ConditionFn(func(k *Kine, streamName string) (*Alert, error) {
+		shards, err := k.openShards(streamName, false)
+		if err != nil {
+			return nil, err
+		}
+
+		skew := shardSkew(shards)
+		if skew <= percent {
+			return nil, nil
+		}
+		return &Alert{
+			StreamName: streamName,
+			Condition:  "shard skew (%)",
+			Value:      skew,
+			Threshold:  percent,
+		}, nil
+	})
+}
+
+// shardSkew returns how many percent the widest shard exceeds the even share.
+func shardSkew(shards []*kinesis.Shard) float64 {
+	if len(shards) == 0 {
+		return 0
+	}
+	var max float64
+	for _, s := range shards {
+		if v := hashRangeShare(s); v > max {
+			max = v
+		}
+	}
+	even := 1 / float64(len(shards))
+	return (max/even - 1) * 100
+}
+
+type Monitor struct {
+	k          *Kine
+	streamName string
+	interval   time.Duration
+	alerter    Alerter
+	conditions []Condition
+}
+
+func (k *Kine) NewMonitor(streamName string, interval time.Duration, alerter Alerter, conditions ...Condition) *Monitor {
+	return &Monitor{
+		k:          k,
+		streamName: streamName,
+		interval:   interval,
+		alerter:    alerter,
+		conditions: conditions,
+	}
+}
+
+// Check evaluates every condition once and sends the resulting alerts.
+// A condition failing to evaluate does not stop the others: the errors are
+// returned together as a *BatchError, keyed by the position of the
+// condition.
+func (m *Monitor) Check() ([]*Alert, error) {
+	var alerts []*Alert
+	errs := make(map[string]error)
+	for i, c := range m.conditions {
+		a, err := c.Check(m.k, m.streamName)
+		if err != nil {
+			errs[fmt.Sprintf("condition %d", i+1)] = err
+			continue
+		}
+		if a == nil {
+			continue
+		}
+		if a.Time.IsZero() {
+			a.Time = m.k.clock.Now()
+		}
+		alerts = append(alerts, a)
+
+		if err := m.alerter.Alert(a); err != nil {
+			errs[fmt.Sprintf("alert of condition %d", i+1)] = err
+		}
+	}
+	if len(errs) > 0 {
+		return alerts, &BatchError{Errors: errs}
+	}
+	return alerts, nil
+}
+
+// Run checks the conditions every interval until ctx is done.
+// A failing check is logged and does not stop the loop.
+func (m *Monitor) Run(ctx context.Context) error {
+	for {
+		if _, err := m.Check(); err != nil {
+			log.Printf("kine: monitor %s: %v", m.streamName, err)
+		}
+
+		timer := m.k.clock.NewTimer(m.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package kine_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ingtk/kine"
+	"github.com/ingtk/kine/kinetest"
+)
+
+func TestMonitorCheckEvaluatesEveryCondition(t *testing.T) {
+	_, k := newFakeAWS(t, kinetest.NewFakeClock(time.Unix(0, 0)))
+
+	failing := kine.ConditionFn(func(k *kine.Kine, streamName string) (*kine.Alert, error) {
+		return nil, errors.New("throttled")
+	})
+	firing := kine.ConditionFn(func(k *kine.Kine, streamName string) (*kine.Alert, error) {
+		return &kine.Alert{StreamName: streamName, Condition: "always"}, nil
+	})
+	var sent []*kine.Alert
+	alerter := kine.AlerterFunc(func(a *kine.Alert) error {
+		sent = append(sent, a)
+		return nil
+	})
+
+	m := k.NewMonitor("orders", time.Minute, alerter, failing, firing, failing)
+	alerts, err := m.Check()
+	if len(alerts) != 1 || len(sent) != 1 {
+		t.Errorf("%d alerts, %d sent, want the alert of the condition after the failing one", len(alerts), len(sent))
+	}
+	var batchErr *kine.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 2 {
+		t.Errorf("error %v, want the errors of both failing conditions", err)
+	}
+}
@@ -0,0 +1,139 @@
+package kine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// ReadTopology reads a topology saved with Render in TopologyJSON, so plans
+// can be made without access to the stream. The shares are ignored.
+func ReadTopology(streamName string, r io.Reader) (*Topology, error) {
+	var shards []topologyShard
+	if err := json.NewDecoder(r).Decode(&shards); err != nil {
+		return nil, err
+	}
+
+	t := &Topology{
+		StreamName: streamName,
+		Shards:     make([]ShardRange, 0, len(shards)),
+	}
+	for _, s := range shards {
+		r, err := hashrange.Parse(s.StartingHashKey, s.EndingHashKey)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", s.ShardID, err)
+		}
+		t.Shards = append(t.Shards, ShardRange{ShardID: s.ShardID, Range: r})
+	}
+	return t, nil
+}
+
+// PlanRepair returns the plan evenly tiling the topology. With
+// WithShardCount, this is the offline equivalent of ScaleTo.
+func (t *Topology) PlanRepair(opts ...RepairOption) (*Plan, error) {
+	current := t.Ranges()
+	if err := checkTiling(sortedRanges(current)); err != nil {
+		return nil, fmt.Errorf("cannot repair %s: %w", t.StreamName, err)
+	}
+	target, err := RepairBoundaries(current, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return PlanTopology(t.StreamName, current, target)
+}
+
+// PlanDesign returns the plan rebalancing the topology for the heat map.
+func (t *Topology) PlanDesign(heat HeatMap, shardCount int) (*Plan, error) {
+	target, err := DesignBoundaries(heat, shardCount)
+	if err != nil {
+		return nil, err
+	}
+	return PlanTopology(t.StreamName, t.Ranges(), target)
+}
+
+// PlanBoundaries returns the plan turning the topology into the ranges
+// between the boundaries.
+func (t *Topology) PlanBoundaries(boundaries []string) (*Plan, error) {
+	target, err := RangesFromBoundaries(boundaries)
+	if err != nil {
+		return nil, err
+	}
+	return PlanTopology(t.StreamName, t.Ranges(), target)
+}
+
+type planFile struct {
+	StreamName string     `json:"stream"`
+	Steps      []stepFile `json:"steps"`
+}
+
+type stepFile struct {
+	Action        StepAction `json:"action"`
+	StartingKey   string     `json:"startingHashKey"`
+	EndingKey     string     `json:"endingHashKey"`
+	At            string     `json:"at,omitempty"`
+	AdjacentStart string     `json:"adjacentStartingHashKey,omitempty"`
+	AdjacentEnd   string     `json:"adjacentEndingHashKey,omitempty"`
+}
+
+// WritePlan writes the plan as JSON, to be reviewed and later applied with
+// ReadPlan and Apply, possibly by someone else.
+func WritePlan(w io.Writer, plan *Plan) error {
+	f := planFile{
+		StreamName: plan.StreamName,
+		Steps:      make([]stepFile, 0, len(plan.Steps)),
+	}
+	for _, s := range plan.Steps {
+		step := stepFile{
+			Action:      s.Action,
+			StartingKey: s.Range.Start.String(),
+			EndingKey:   s.Range.End.String(),
+		}
+		switch s.Action {
+		case ActionSplit:
+			step.At = s.At.String()
+		case ActionMerge:
+			step.AdjacentStart = s.Adjacent.Start.String()
+			step.AdjacentEnd = s.Adjacent.End.String()
+		}
+		f.Steps = append(f.Steps, step)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// ReadPlan reads a plan written by WritePlan.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	var f planFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		StreamName: f.StreamName,
+		Steps:      make([]Step, 0, len(f.Steps)),
+	}
+	for i, s := range f.Steps {
+		step := Step{Action: s.Action}
+		var err error
+		step.Range, err = hashrange.Parse(s.StartingKey, s.EndingKey)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		switch s.Action {
+		case ActionSplit:
+			step.At, err = hashrange.ParseKey(s.At)
+		case ActionMerge:
+			step.Adjacent, err = hashrange.Parse(s.AdjacentStart, s.AdjacentEnd)
+		default:
+			err = fmt.Errorf("unknown action %q", s.Action)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
@@ -0,0 +1,54 @@
+package kine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+func FuzzReadPlan(f *testing.F) {
+	ranges, err := hashrange.Full.Divide(2)
+	if err != nil {
+		f.Fatal(err)
+	}
+	at, err := ranges[0].SplitPoint(0.5)
+	if err != nil {
+		f.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = WritePlan(buf, &Plan{
+		StreamName: "orders",
+		Steps: []Step{
+			{Action: ActionSplit, Range: ranges[0], At: at},
+			{Action: ActionMerge, Range: ranges[0], Adjacent: ranges[1]},
+		},
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.String())
+	f.Add(`{"stream":"orders","steps":[{"action":"split","startingHashKey":"10","endingHashKey":"1"}]}`)
+	f.Add(`{"stream":"orders","steps":[{"action":"merge","startingHashKey":"0","endingHashKey":"x"}]}`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		plan, err := ReadPlan(strings.NewReader(s))
+		if err != nil {
+			return
+		}
+		// a plan read is written and read back unchanged
+		buf := &bytes.Buffer{}
+		if err := WritePlan(buf, plan); err != nil {
+			t.Fatal(err)
+		}
+		again, err := ReadPlan(buf)
+		if err != nil {
+			t.Fatalf("plan written from %q cannot be read: %v", s, err)
+		}
+		if again.StreamName != plan.StreamName || !reflect.DeepEqual(again.Steps, plan.Steps) {
+			t.Fatalf("plan read from %q changed once written: %v, then %v", s, plan, again)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package kine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// OperationRecord is a completed resharding operation, as kept by an
+// OperationStore.
+type OperationRecord struct {
+	StreamName string        `json:"stream"`
+	Operation  string        `json:"operation"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration"`
+	// Steps is the number of steps of an applied plan, 0 for the other
+	// operations.
+	Steps int `json:"steps,omitempty"`
+	// Throttles is the number of control plane calls throttled by Kinesis
+	// during the operation, including those of other operations of the
+	// same Kine running at the same time.
+	Throttles int64  `json:"throttles"`
+	Error     string `json:"error,omitempty"`
+	// Shards is the resulting topology; it is empty when the operation or
+	// listing the shards failed.
+	Shards []topologyShard `json:"shards,omitempty"`
+}
+
+// OperationStore keeps the resharding operations of a Kine, to learn how
+// long they take over time without any AWS-side storage.
+type OperationStore interface {
+	RecordOperation(r *OperationRecord) error
+	// Operations returns the operations on the stream started at or after
+	// since, of every stream when streamName is empty.
+	Operations(streamName string, since time.Time) ([]*OperationRecord, error)
+}
+
+// WithOperationStore records every resharding operation in s. A failing
+// store is logged and does not fail the operation.
+func WithOperationStore(s OperationStore) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.opStore = s
+		return nil
+	})
+}
+
+// countThrottle counts the throttled control plane calls for the
+// OperationStore.
+func (k *Kine) countThrottle(r *request.Request) {
+	if !dataPlaneOperations[r.Operation.Name] && throttled(r.Error) {
+		atomic.AddInt64(&k.throttles, 1)
+	}
+}
+
+// recordOperation returns the function recording the outcome of the
+// operation started now.
+func (k *Kine) recordOperation(streamName, operation string, detail map[string]interface{}) func(err error) {
+	if k.opStore == nil {
+		return func(error) {}
+	}
+	started := k.clock.Now()
+	throttles := atomic.LoadInt64(&k.throttles)
+
+	return func(err error) {
+		r := &OperationRecord{
+			StreamName: streamName,
+			Operation:  operation,
+			Started:    started,
+			Duration:   k.clock.Now().Sub(started),
+			Throttles:  atomic.LoadInt64(&k.throttles) - throttles,
+		}
+		r.Steps, _ = detail["steps"].(int)
+		if err != nil {
+			r.Error = err.Error()
+		} else if t, err := k.Topology(streamName); err == nil {
+			for _, s := range t.Shards {
+				r.Shards = append(r.Shards, topologyShard{
+					ShardID:         s.ShardID,
+					StartingHashKey: s.Range.Start.String(),
+					EndingHashKey:   s.Range.End.String(),
+					Share:           roundShare(s.Range.Share()),
+				})
+			}
+		}
+		if err := k.opStore.RecordOperation(r); err != nil {
+			log.Printf("kine: record %s %s: %v", operation, streamName, err)
+		}
+	}
+}
+
+// EstimateDuration estimates how long applying the plan takes from the
+// average step duration of the plans applied successfully before, on the
+// same stream if any, on any stream otherwise. It returns 0 without an
+// OperationStore or history.
+func (k *Kine) EstimateDuration(plan *Plan) (time.Duration, error) {
+	if k.opStore == nil {
+		return 0, nil
+	}
+	for _, streamName := range []string{plan.StreamName, ""} {
+		records, err := k.opStore.Operations(streamName, time.Time{})
+		if err != nil {
+			return 0, err
+		}
+		var steps int
+		var total time.Duration
+		for _, r := range records {
+			if r.Steps > 0 && r.Error == "" {
+				steps += r.Steps
+				total += r.Duration
+			}
+		}
+		if steps > 0 {
+			return total / time.Duration(steps) * time.Duration(len(plan.Steps)), nil
+		}
+	}
+	return 0, nil
+}
+
+// FileOperationStore appends the operations to a file as JSON lines.
+type FileOperationStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func NewFileOperationStore(path string) (*FileOperationStore, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileOperationStore{path: path, f: f}, nil
+}
+
+func (s *FileOperationStore) RecordOperation(r *OperationRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+// maxOperationLine bounds a line of the file, which holds the topology.
+const maxOperationLine = 16 << 20
+
+func (s *FileOperationStore) Operations(streamName string, since time.Time) ([]*OperationRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []*OperationRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxOperationLine)
+	for scanner.Scan() {
+		r := &OperationRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.path, err)
+		}
+		if (streamName == "" || r.StreamName == streamName) && !r.Started.Before(since) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (s *FileOperationStore) Close() error {
+	return s.f.Close()
+}
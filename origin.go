@@ -0,0 +1,143 @@
+package kine
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// originMagic starts the data of records tagged with the stream they come
+// from, followed by the uint16 length of the stream name, the name and the
+// original data.
+const originMagic = "\x00ko"
+
+func tagOrigin(streamName string, data []byte) []byte {
+	buf := make([]byte, 0, len(originMagic)+2+len(streamName)+len(data))
+	buf = append(buf, originMagic...)
+	buf = append(buf, 0, 0)
+	binary.BigEndian.PutUint16(buf[len(originMagic):], uint16(len(streamName)))
+	buf = append(buf, streamName...)
+	return append(buf, data...)
+}
+
+// untagOrigin returns the origin of a tagged record and its original data,
+// or an empty origin and data itself when it is not tagged.
+func untagOrigin(data []byte) (string, []byte) {
+	if !bytes.HasPrefix(data, []byte(originMagic)) || len(data) < len(originMagic)+2 {
+		return "", data
+	}
+	n := int(binary.BigEndian.Uint16(data[len(originMagic):]))
+	rest := data[len(originMagic)+2:]
+	if len(rest) < n {
+		return "", data
+	}
+	return string(rest[:n]), rest[n:]
+}
+
+// Aggregator consumes several streams into one, keeping the partition keys
+// and tagging every record with the stream it comes from, see
+// Record.Origin. Each source is checkpointed on its own.
+type Aggregator struct {
+	consumers []*Consumer
+	producer  *Producer
+
+	mu      sync.Mutex
+	running bool
+	err     error
+	done    chan struct{}
+}
+
+// NewAggregator returns an aggregator of srcStreams into dstStream. opts
+// apply to the consumer of every source.
+func (k *Kine) NewAggregator(srcStreams []string, dstStream string, opts ...ConsumerOption) *Aggregator {
+	a := &Aggregator{
+		producer: k.NewProducer(dstStream),
+		done:     make(chan struct{}),
+	}
+	for _, src := range srcStreams {
+		src := src
+		a.consumers = append(a.consumers, k.NewConsumer(src, func(ctx context.Context, r *Record) error {
+			return a.producer.Put(ctx, r.PartitionKey, tagOrigin(src, r.Data))
+		}, opts...))
+	}
+	return a
+}
+
+// Run consumes the sources until ctx is done, one of them fails or the
+// aggregator is shut down, and flushes the records put before returning.
+// An aggregator runs only once.
+func (a *Aggregator) Run(ctx context.Context) error {
+	if len(a.consumers) == 0 {
+		return errors.New("no source stream to aggregate")
+	}
+
+	a.mu.Lock()
+	if a.running {
+		a.mu.Unlock()
+		return errors.New("aggregator already ran")
+	}
+	a.running = true
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for _, c := range a.consumers {
+		wg.Add(1)
+		go func(c *Consumer) {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil && ctx.Err() == nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	err := firstErr
+	if err == nil {
+		err = ctx.Err()
+	}
+	if cerr := a.producer.Close(context.Background()); err == nil {
+		err = cerr
+	}
+
+	a.mu.Lock()
+	a.err = err
+	a.mu.Unlock()
+	close(a.done)
+	return err
+}
+
+// Shutdown stops reading the sources and waits until the records read are
+// written, returning the error Run returned.
+func (a *Aggregator) Shutdown(ctx context.Context) error {
+	for _, c := range a.consumers {
+		if err := c.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	running := a.running
+	a.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	select {
+	case <-a.done:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
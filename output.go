@@ -0,0 +1,25 @@
+package kine
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// stdoutMu serializes the tables printed to stdout, so that the output of
+// concurrent calls is not interleaved.
+var stdoutMu sync.Mutex
+
+// printStdout renders fn into a buffer and prints it to stdout at once.
+func printStdout(fn func(w io.Writer) error) error {
+	buf := &bytes.Buffer{}
+	if err := fn(buf); err != nil {
+		return err
+	}
+
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err := buf.WriteTo(os.Stdout)
+	return err
+}
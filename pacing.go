@@ -0,0 +1,126 @@
+package kine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Pacing is how kine spaces its control-plane calls (describe, list and
+// resharding calls, but not reads and writes of records) when Kinesis
+// throttles them. The delay between calls starts at zero, is doubled (at
+// least to Initial) on every throttled call, up to Max, and shrinks by
+// Decrease on every call which went through.
+type Pacing struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Decrease time.Duration
+}
+
+var DefaultPacing = Pacing{
+	Initial:  200 * time.Millisecond,
+	Max:      10 * time.Second,
+	Decrease: 100 * time.Millisecond,
+}
+
+// WithPacing replaces DefaultPacing. A zero Max disables pacing.
+func WithPacing(p Pacing) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.pacing = p
+		return nil
+	})
+}
+
+// dataPlaneOperations are paced by their own shard limits, and their
+// throttles say nothing about the control plane.
+var dataPlaneOperations = map[string]bool{
+	"GetRecords":       true,
+	"GetShardIterator": true,
+	"PutRecord":        true,
+	"PutRecords":       true,
+	"SubscribeToShard": true,
+}
+
+// pacer is an AIMD controller shared by the control-plane calls of a Kine.
+type pacer struct {
+	k      *Kine
+	pacing Pacing
+
+	mu    sync.Mutex
+	delay time.Duration
+	next  time.Time
+}
+
+func newPacer(k *Kine, p Pacing) *pacer {
+	return &pacer{k: k, pacing: p}
+}
+
+// Delay returns the current delay between control-plane calls.
+func (p *pacer) Delay() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.delay
+}
+
+// wait is a Sign handler delaying every attempt of a call until its turn,
+// so that the signature is fresh when it is sent.
+func (p *pacer) wait(r *request.Request) {
+	if dataPlaneOperations[r.Operation.Name] {
+		return
+	}
+
+	p.mu.Lock()
+	now := p.k.clock.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	d := p.next.Sub(now)
+	p.next = p.next.Add(p.delay)
+	p.mu.Unlock()
+
+	if d > 0 {
+		if err := p.k.sleep(r.Context(), d); err != nil {
+			r.Error = awserr.New(request.CanceledErrorCode, "request context canceled", err)
+		}
+	}
+}
+
+// observe is a CompleteAttempt handler adapting the delay to the outcome.
+func (p *pacer) observe(r *request.Request) {
+	if dataPlaneOperations[r.Operation.Name] {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if throttled(r.Error) {
+		p.delay *= 2
+		if p.delay < p.pacing.Initial {
+			p.delay = p.pacing.Initial
+		}
+		if p.delay > p.pacing.Max {
+			p.delay = p.pacing.Max
+		}
+		return
+	}
+	if r.Error == nil {
+		p.delay -= p.pacing.Decrease
+		if p.delay < 0 {
+			p.delay = 0
+		}
+	}
+}
+
+// throttled reports whether the error is a control-plane rate limit, which
+// Kinesis reports as LimitExceededException.
+func throttled(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == kinesis.ErrCodeLimitExceededException
+}
@@ -0,0 +1,67 @@
+package kine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// PartitionKeyStrategy is how a producer spreads its records over the shards.
+type PartitionKeyStrategy int
+
+const (
+	// KeyExplicit uses the partition key given to Put.
+	KeyExplicit PartitionKeyStrategy = iota
+	// KeyRandom ignores the given key and uses a random one per record.
+	KeyRandom
+	// KeyRoundRobin sends the records to the open shards in turn, with the
+	// middle of their hash key range as explicit hash key.
+	KeyRoundRobin
+	// KeySticky ignores the given key and uses one random key for all the
+	// records of the producer, keeping them in order on a single shard.
+	KeySticky
+)
+
+// WithPartitionKeys sets how the records are spread, KeyExplicit by default.
+func WithPartitionKeys(s PartitionKeyStrategy) ProducerOption {
+	return func(p *Producer) {
+		p.keys = s
+		if s == KeySticky {
+			p.stickyKey = randomKey()
+		}
+	}
+}
+
+func randomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// keyOf returns the partition key and explicit hash key of a record put
+// with the key.
+func (p *Producer) keyOf(key string) (string, string) {
+	switch p.keys {
+	case KeyRandom:
+		return randomKey(), ""
+	case KeySticky:
+		return p.stickyKey, ""
+	case KeyRoundRobin:
+		shards := p.shards.get()
+		if key == "" {
+			key = randomKey()
+		}
+		if len(shards) == 0 {
+			return key, ""
+		}
+		n := atomic.AddUint64(&p.roundRobin, 1)
+		r := shards[n%uint64(len(shards))].Range
+		mid, err := r.SplitPoint(0.5)
+		if err != nil {
+			// a shard of a single hash key
+			mid = r.Start
+		}
+		return key, mid.String()
+	}
+	return key, ""
+}
@@ -0,0 +1,90 @@
+package kine
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// peekLookbacks are how far back Peek reads, widening until it finds
+// enough records.
+var peekLookbacks = []time.Duration{time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// Peek returns up to perShard of the most recent records of every open
+// shard by shard ID, oldest first, to check that data is flowing and which
+// keys land where. Kinesis cannot read backwards, so Peek reads each shard
+// from a minute ago, and from further back up to a day while it finds too
+// few records; on a busy shard it reads the whole last minute. The data is
+// returned as stored, without decryption or decompression.
+func (k *Kine) Peek(streamName string, perShard int) (map[string][]*Record, error) {
+
+	shards, err := k.openShards(streamName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	peeked := make(map[string][]*Record, len(shards))
+	for _, shard := range shards {
+		var records []*Record
+		for _, lookback := range peekLookbacks {
+			records, err = k.peekShard(streamName, *shard.ShardId, k.clock.Now().Add(-lookback), perShard)
+			if err != nil {
+				return nil, err
+			}
+			if len(records) >= perShard {
+				break
+			}
+		}
+		peeked[*shard.ShardId] = records
+	}
+	return peeked, nil
+}
+
+// peekShard reads the shard from since up to the tip, keeping the last n records.
+func (k *Kine) peekShard(streamName, shardID string, since time.Time, n int) ([]*Record, error) {
+	it, err := k.svc.GetShardIterator(StartAtTimestamp(since).iteratorInput(streamName, shardID))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	iterator := it.ShardIterator
+	for iterator != nil {
+		out, err := k.svc.GetRecords(&kinesis.GetRecordsInput{
+			ShardIterator: iterator,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range out.Records {
+			records = append(records, rawRecord(streamName, shardID, r))
+		}
+		if len(records) > n {
+			records = records[len(records)-n:]
+		}
+		if aws.Int64Value(out.MillisBehindLatest) == 0 {
+			break
+		}
+		iterator = out.NextShardIterator
+		k.sleep(context.Background(), getRecordsInterval)
+	}
+	return records, nil
+}
+
+// rawRecord converts a record read outside of a consumer, keeping its data
+// as stored.
+func rawRecord(streamName, shardID string, r *kinesis.Record) *Record {
+	origin, data := untagOrigin(r.Data)
+	return &Record{
+		StreamName:     streamName,
+		ShardID:        shardID,
+		SequenceNumber: *r.SequenceNumber,
+		PartitionKey:   *r.PartitionKey,
+		ArrivalTime:    aws.TimeValue(r.ApproximateArrivalTimestamp),
+		Data:           data,
+		Origin:         origin,
+		codec:          RawCodec{},
+	}
+}
@@ -0,0 +1,273 @@
+package kine
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ingtk/kine/hashrange"
+)
+
+type StepAction string
+
+const (
+	ActionSplit StepAction = "split"
+	ActionMerge StepAction = "merge"
+)
+
+// Step is one SplitShard or MergeShards call. Shards are identified by their
+// hash key ranges rather than IDs, because the IDs of shards created by
+// earlier steps are not known when the plan is made.
+type Step struct {
+	Action StepAction
+
+	// Range is the shard to split, or the lower of the two shards to merge.
+	Range hashrange.Range
+
+	// At is the starting hash key of the upper child of a split.
+	At hashrange.Key
+
+	// Adjacent is the upper of the two shards to merge.
+	Adjacent hashrange.Range
+}
+
+func (s Step) String() string {
+	switch s.Action {
+	case ActionSplit:
+		return fmt.Sprintf("split %s at %s", s.Range, s.At)
+	case ActionMerge:
+		return fmt.Sprintf("merge %s and %s", s.Range, s.Adjacent)
+	}
+	return string(s.Action)
+}
+
+// Plan is a sequence of steps turning the topology of a stream into another one.
+type Plan struct {
+	StreamName string
+	Steps      []Step
+
+	mu        sync.Mutex
+	completed int
+	started   time.Time
+	lastDone  time.Time
+}
+
+// Progress is how far Apply got through a plan.
+type Progress struct {
+	Completed int
+	Total     int
+	Started   time.Time
+	// ETA is when the last step should complete, going by the average
+	// duration of the completed steps. It is zero until a step completed.
+	ETA time.Time
+}
+
+// Percent is the share of the steps completed, from 0 to 100.
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Completed) * 100 / float64(p.Total)
+}
+
+// Progress returns how far Apply got through the plan. It can be called
+// while Apply is running, e.g. to show a progress bar.
+func (p *Plan) Progress() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	progress := Progress{
+		Completed: p.completed,
+		Total:     len(p.Steps),
+		Started:   p.started,
+	}
+	if p.completed > 0 {
+		perStep := p.lastDone.Sub(p.started) / time.Duration(p.completed)
+		progress.ETA = p.lastDone.Add(perStep * time.Duration(len(p.Steps)-p.completed))
+	}
+	return progress
+}
+
+func (p *Plan) start(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed = 0
+	p.started = now
+}
+
+func (p *Plan) stepDone(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed++
+	p.lastDone = now
+}
+
+func (p *Plan) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s: %d step(s)\n", p.StreamName, len(p.Steps))
+	for i, s := range p.Steps {
+		fmt.Fprintf(buf, "%3d. %s\n", i+1, s)
+	}
+	return buf.String()
+}
+
+// checkTiling verifies that sorted ranges cover the whole hash key space
+// without gaps or overlaps.
+func checkTiling(ranges []hashrange.Range) error {
+	shards := make([]ShardRange, 0, len(ranges))
+	for _, r := range ranges {
+		shards = append(shards, ShardRange{Range: r})
+	}
+	if anomalies := CheckTiling(shards); len(anomalies) > 0 {
+		return anomalies[0]
+	}
+	return nil
+}
+
+// PlanTopology returns the steps turning the current ranges into the target
+// ones. It sweeps the hash key space from the bottom and builds one target
+// range at a time, splitting a shard only where the target has a new boundary
+// and merging only where a current boundary goes away, so the shard count
+// stays close to the larger of the current and target counts throughout.
+func PlanTopology(streamName string, current, target []hashrange.Range) (*Plan, error) {
+
+	current = sortedRanges(current)
+	target = sortedRanges(target)
+	if err := checkTiling(current); err != nil {
+		return nil, fmt.Errorf("current topology: %w", err)
+	}
+	if err := checkTiling(target); err != nil {
+		return nil, fmt.Errorf("target topology: %w", err)
+	}
+
+	plan := &Plan{StreamName: streamName}
+	split := func(r hashrange.Range, at hashrange.Key) (hashrange.Range, hashrange.Range) {
+		plan.Steps = append(plan.Steps, Step{Action: ActionSplit, Range: r, At: at})
+		return hashrange.Range{Start: r.Start, End: at.Prev()}, hashrange.Range{Start: at, End: r.End}
+	}
+
+	pieces := current
+	next := 0
+	for _, t := range target {
+		// a piece always starts at t.Start here
+		acc := pieces[next]
+		next++
+
+		for acc.End.Cmp(t.End) < 0 {
+			p := pieces[next]
+			if p.End.Cmp(t.End) > 0 {
+				// put the part above t back to be used for the next target
+				p, pieces[next] = split(p, t.End.Next())
+			} else {
+				next++
+			}
+			plan.Steps = append(plan.Steps, Step{Action: ActionMerge, Range: acc, Adjacent: p})
+			acc = hashrange.Range{Start: acc.Start, End: p.End}
+		}
+
+		if acc.End.Cmp(t.End) > 0 {
+			next--
+			_, pieces[next] = split(acc, t.End.Next())
+		}
+	}
+
+	return plan, nil
+}
+
+func sortedRanges(ranges []hashrange.Range) []hashrange.Range {
+	sorted := make([]hashrange.Range, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Cmp(sorted[j].Start) < 0
+	})
+	return sorted
+}
+
+// Apply executes the steps of the plan one by one, waiting for the stream to
+// become ACTIVE after each of them.
+func (k *Kine) Apply(plan *Plan) error {
+	if len(plan.Steps) == 0 {
+		return nil
+	}
+	if err := k.checkReshard(plan.StreamName, "apply"); err != nil {
+		return err
+	}
+	end, err := k.beginReshard(plan.StreamName, "apply", map[string]interface{}{
+		"steps": len(plan.Steps),
+	})
+	if err != nil {
+		return err
+	}
+	err = k.apply(plan)
+	end(err)
+	return err
+}
+
+func (k *Kine) apply(plan *Plan) error {
+	plan.start(k.clock.Now())
+	for i, step := range plan.Steps {
+		if err := k.applyStep(plan.StreamName, step); err != nil {
+			return fmt.Errorf("%s: step %d of %d (%s): %w", plan.StreamName, i+1, len(plan.Steps), step, err)
+		}
+		plan.stepDone(k.clock.Now())
+	}
+	return nil
+}
+
+func (k *Kine) applyStep(streamName string, step Step) error {
+
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return err
+	}
+
+	shard, ok := topology.Find(step.Range)
+	if !ok {
+		return fmt.Errorf("no open shard covers %s", step.Range)
+	}
+
+	var parents []string
+	var operation string
+	switch step.Action {
+	case ActionSplit:
+		parents = []string{shard.ShardID}
+		operation = fmt.Sprintf("SplitShard %s", shard.ShardID)
+		_, err = k.svc.SplitShard(&kinesis.SplitShardInput{
+			NewStartingHashKey: aws.String(step.At.String()),
+			ShardToSplit:       aws.String(shard.ShardID),
+			StreamName:         aws.String(streamName),
+		})
+		if err != nil {
+			err = fmt.Errorf("%s: %w", operation, err)
+		}
+	case ActionMerge:
+		adjacent, ok := topology.Find(step.Adjacent)
+		if !ok {
+			return fmt.Errorf("no open shard covers %s", step.Adjacent)
+		}
+		parents = []string{shard.ShardID, adjacent.ShardID}
+		operation = fmt.Sprintf("MergeShards %s and %s", shard.ShardID, adjacent.ShardID)
+		_, err = k.svc.MergeShards(&kinesis.MergeShardsInput{
+			AdjacentShardToMerge: aws.String(adjacent.ShardID),
+			ShardToMerge:         aws.String(shard.ShardID),
+			StreamName:           aws.String(streamName),
+		})
+		if err != nil {
+			err = fmt.Errorf("%s: %w", operation, err)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+	k.shardCache.invalidate(streamName)
+	if err != nil {
+		return err
+	}
+
+	if err := k.waitUntilSettled(streamName, operation, parents...); err != nil {
+		return fmt.Errorf("waiting for ACTIVE: %w", err)
+	}
+	return k.waitUntilReplaced(streamName, parents...)
+}
@@ -0,0 +1,53 @@
+package kine
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// StartPosition is where a consumer starts reading each shard.
+type StartPosition struct {
+	// Type is one of the kinesis.ShardIteratorType* values.
+	Type      string
+	Timestamp time.Time
+	// SequenceNumbers are the positions per shard ID for AT_SEQUENCE_NUMBER
+	// and AFTER_SEQUENCE_NUMBER. Shards without one start from LATEST.
+	SequenceNumbers map[string]string
+}
+
+var (
+	StartLatest      = StartPosition{Type: kinesis.ShardIteratorTypeLatest}
+	StartTrimHorizon = StartPosition{Type: kinesis.ShardIteratorTypeTrimHorizon}
+)
+
+// StartAtTimestamp starts from the first records which arrived at or after t.
+func StartAtTimestamp(t time.Time) StartPosition {
+	return StartPosition{Type: kinesis.ShardIteratorTypeAtTimestamp, Timestamp: t}
+}
+
+// StartAtSequenceNumbers starts each shard at the given sequence number.
+func StartAtSequenceNumbers(sequenceNumbers map[string]string) StartPosition {
+	return StartPosition{Type: kinesis.ShardIteratorTypeAtSequenceNumber, SequenceNumbers: sequenceNumbers}
+}
+
+func (p StartPosition) iteratorInput(streamName, shardID string) *kinesis.GetShardIteratorInput {
+	in := &kinesis.GetShardIteratorInput{
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(p.Type),
+		StreamName:        aws.String(streamName),
+	}
+	switch p.Type {
+	case kinesis.ShardIteratorTypeAtTimestamp:
+		in.Timestamp = aws.Time(p.Timestamp)
+	case kinesis.ShardIteratorTypeAtSequenceNumber, kinesis.ShardIteratorTypeAfterSequenceNumber:
+		seq, ok := p.SequenceNumbers[shardID]
+		if !ok {
+			in.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeLatest)
+			break
+		}
+		in.StartingSequenceNumber = aws.String(seq)
+	}
+	return in
+}
@@ -0,0 +1,597 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	// PutRecords accepts up to 500 records and 5MiB per request.
+	maxBatchRecords = 500
+	maxBatchBytes   = 5 << 20
+	// a record, data and partition key, is at most 1MiB
+	maxRecordBytes = 1 << 20
+
+	defaultBufferSize = 10000
+	producerLinger    = 100 * time.Millisecond
+
+	defaultPutAttempts = 5
+)
+
+// defaultPutBackoff is the delay between the retries of failed records.
+var defaultPutBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        2 * time.Second,
+	Multiplier: 2,
+}
+
+// FullBehavior is what Put does when the buffer of the producer is full.
+type FullBehavior int
+
+const (
+	// FullBlock makes Put wait for room, or for its context to be done.
+	FullBlock FullBehavior = iota
+	// FullError makes Put return ErrBufferFull.
+	FullError
+	// FullDropOldest drops the oldest buffered record to make room.
+	FullDropOldest
+)
+
+var (
+	ErrBufferFull     = errors.New("producer buffer is full")
+	ErrProducerClosed = errors.New("producer is closed")
+)
+
+// PutResult is the outcome of a record put by a Producer.
+type PutResult struct {
+	PartitionKey   string
+	ShardID        string
+	SequenceNumber string
+	// Err is set when the record could not be put after all the attempts.
+	Err error
+}
+
+// PutRecordError is a record rejected by PutRecords,
+// e.g. ProvisionedThroughputExceededException.
+type PutRecordError struct {
+	Code    string
+	Message string
+}
+
+func (e *PutRecordError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// RecordTooLargeError is returned by Put for a record whose data and
+// partition key, once encoded, are over the 1MiB limit of Kinesis.
+type RecordTooLargeError struct {
+	PartitionKey string
+	Size         int
+}
+
+func (e *RecordTooLargeError) Error() string {
+	return fmt.Sprintf("record of %s is %d bytes, over %d", e.PartitionKey, e.Size, maxRecordBytes)
+}
+
+// UnsentRecord is a record the producer did not send, encoded.
+type UnsentRecord struct {
+	PartitionKey    string
+	ExplicitHashKey string
+	Data            []byte
+}
+
+// UnsentError is returned by Close when its context is done before the
+// buffered records are sent. Records holds them, in order, to be put again
+// or saved.
+type UnsentError struct {
+	Records []UnsentRecord
+	Err     error
+}
+
+func (e *UnsentError) Error() string {
+	return fmt.Sprintf("%d records unsent: %v", len(e.Records), e.Err)
+}
+
+func (e *UnsentError) Unwrap() error {
+	return e.Err
+}
+
+type pendingRecord struct {
+	partitionKey    string
+	explicitHashKey string
+	data            []byte
+}
+
+func (r *pendingRecord) size() int {
+	return len(r.partitionKey) + len(r.data)
+}
+
+// Producer puts values into a stream, encoding them with its codec.
+// Records are buffered and sent in batches in the background; Flush waits
+// until they are sent and Close stops the producer.
+type Producer struct {
+	k          *Kine
+	streamName string
+	codec      Codec
+
+	compression Compression
+	encrypter   *encrypter
+
+	attempts int
+	backoff  Backoff
+	callback func(PutResult)
+	shards   *shardMap
+	shaper   *shaper
+	metrics  Metrics
+
+	keys       PartitionKeyStrategy
+	stickyKey  string
+	roundRobin uint64
+
+	full    FullBehavior
+	queue   chan *pendingRecord
+	flushCh chan struct{}
+	closing chan struct{}
+	quit    chan struct{}
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+	err     error
+	dropped int
+	closed  bool
+	unsent  []UnsentRecord
+}
+
+type ProducerOption func(p *Producer)
+
+// WithProducerCodec sets how values are encoded, RawCodec by default.
+func WithProducerCodec(c Codec) ProducerOption {
+	return func(p *Producer) {
+		p.codec = c
+	}
+}
+
+// WithCompression compresses records of 1KiB or more with c when it makes
+// them smaller. Consumers detect and decompress them automatically.
+func WithCompression(c Compression) ProducerOption {
+	return func(p *Producer) {
+		p.compression = c
+	}
+}
+
+// WithEncryption encrypts the records with AES-GCM using data keys generated
+// by the KMS key, on top of the server-side encryption of the stream.
+// Consumers detect and decrypt them automatically.
+func WithEncryption(kmsKeyID string) ProducerOption {
+	return func(p *Producer) {
+		p.encrypter = &encrypter{kms: p.k.kms, keyID: kmsKeyID, clock: p.k.clock}
+	}
+}
+
+// WithBuffer bounds the number of buffered records, 10000 by default,
+// and sets what Put does when the buffer is full, FullBlock by default.
+func WithBuffer(size int, full FullBehavior) ProducerOption {
+	return func(p *Producer) {
+		p.queue = make(chan *pendingRecord, size)
+		p.full = full
+	}
+}
+
+// WithPutRetry sets how many times a batch, or the records of a batch which
+// failed, are put before giving up, 5 by default, and the delay in between.
+func WithPutRetry(attempts int, b Backoff) ProducerOption {
+	return func(p *Producer) {
+		p.attempts = attempts
+		p.backoff = b
+	}
+}
+
+// WithResultCallback calls fn with the result of every record, from the
+// goroutine sending the records; fn must not block.
+func WithResultCallback(fn func(PutResult)) ProducerOption {
+	return func(p *Producer) {
+		p.callback = fn
+	}
+}
+
+// WithShardShaping throttles the producer locally to the write limits of each
+// shard (1MiB/s and 1000 records/s), smoothing bursts instead of having them
+// rejected with ProvisionedThroughputExceededException. The shards are
+// re-read every minute to follow resharding.
+func WithShardShaping() ProducerOption {
+	return func(p *Producer) {
+		p.shaper = newShaper(p.shards)
+	}
+}
+
+// WithProducerMetrics reports the records and bytes sent, the throttles,
+// the retries and the batch sizes to m.
+func WithProducerMetrics(m Metrics) ProducerOption {
+	return func(p *Producer) {
+		p.metrics = m
+	}
+}
+
+func (k *Kine) NewProducer(streamName string, opts ...ProducerOption) *Producer {
+	p := &Producer{
+		k:          k,
+		streamName: streamName,
+		codec:      RawCodec{},
+		shards:     &shardMap{k: k, streamName: streamName},
+		metrics:    nopMetrics{},
+		attempts:   defaultPutAttempts,
+		backoff:    defaultPutBackoff,
+		full:       FullBlock,
+		queue:      make(chan *pendingRecord, defaultBufferSize),
+		flushCh:    make(chan struct{}, 1),
+		closing:    make(chan struct{}),
+		quit:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	go p.run()
+	return p
+}
+
+func (p *Producer) encode(ctx context.Context, v interface{}) ([]byte, error) {
+	data, err := p.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if p.compression != nil {
+		data, err = compress(p.compression, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.encrypter != nil {
+		return p.encrypter.encrypt(ctx, data)
+	}
+	return data, nil
+}
+
+// Put encodes v and buffers it to be put into the stream with the partition
+// key, unless the partition key strategy replaces it. Records over 1MiB are
+// rejected with a RecordTooLargeError. Errors of the background sends are
+// returned by Flush.
+func (p *Producer) Put(ctx context.Context, partitionKey string, v interface{}) error {
+	data, err := p.encode(ctx, v)
+	if err != nil {
+		return err
+	}
+	r := &pendingRecord{data: data}
+	r.partitionKey, r.explicitHashKey = p.keyOf(partitionKey)
+	if r.size() > maxRecordBytes {
+		return &RecordTooLargeError{PartitionKey: r.partitionKey, Size: r.size()}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrProducerClosed
+	}
+	p.pending++
+	p.mu.Unlock()
+
+	if err := p.enqueue(ctx, r); err != nil {
+		p.done(1, nil)
+		return err
+	}
+	return nil
+}
+
+func (p *Producer) enqueue(ctx context.Context, r *pendingRecord) error {
+	switch p.full {
+	case FullError:
+		select {
+		case p.queue <- r:
+			return nil
+		default:
+			return ErrBufferFull
+		}
+	case FullDropOldest:
+		for {
+			select {
+			case p.queue <- r:
+				return nil
+			default:
+			}
+			select {
+			case <-p.queue:
+				p.mu.Lock()
+				p.dropped++
+				p.mu.Unlock()
+				p.done(1, nil)
+			default:
+			}
+		}
+	}
+
+	select {
+	case p.queue <- r:
+		return nil
+	default:
+	}
+	select {
+	case p.queue <- r:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closing:
+		return ErrProducerClosed
+	}
+}
+
+// done accounts for n records leaving the producer, sent or not.
+func (p *Producer) done(n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	p.pending -= n
+	if p.pending == 0 && p.idle != nil {
+		close(p.idle)
+		p.idle = nil
+	}
+}
+
+// Dropped returns the number of records dropped with FullDropOldest.
+func (p *Producer) Dropped() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped
+}
+
+// Flush sends the buffered records and waits until they are sent or ctx is
+// done. It returns the first error of the sends since the last Flush.
+func (p *Producer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	var idle chan struct{}
+	if p.pending > 0 {
+		if p.idle == nil {
+			p.idle = make(chan struct{})
+		}
+		idle = p.idle
+	}
+	p.mu.Unlock()
+
+	if idle != nil {
+		select {
+		case p.flushCh <- struct{}{}:
+		default:
+		}
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.err
+	p.err = nil
+	return err
+}
+
+// Close flushes the producer and stops it. Put fails after Close, and the
+// calls of Put blocked on a full buffer return ErrProducerClosed. When ctx is
+// done before the records are flushed, the records left are returned in an
+// UnsentError.
+func (p *Producer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	close(p.closing)
+
+	err := p.Flush(ctx)
+	close(p.quit)
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.unsent) > 0 {
+		return &UnsentError{Records: p.unsent, Err: err}
+	}
+	return err
+}
+
+// run batches the buffered records, sending a batch when it is full, when it
+// waited for producerLinger, or on Flush.
+func (p *Producer) run() {
+	defer close(p.stopped)
+
+	var batch []*pendingRecord
+	var size int
+	// linger runs while a batch is open
+	var linger Timer
+	defer func() {
+		if linger != nil {
+			linger.Stop()
+		}
+	}()
+
+	send := func() {
+		if linger != nil {
+			linger.Stop()
+			linger = nil
+		}
+		if len(batch) > 0 {
+			p.send(batch)
+		}
+		batch, size = nil, 0
+	}
+	add := func(r *pendingRecord) {
+		if p.shaper != nil {
+			if d := p.shaper.delay(r); d > 0 {
+				// what is batched already is not held back by the shard
+				send()
+				p.k.sleep(context.Background(), d)
+			}
+		}
+		if len(batch) == maxBatchRecords || size+r.size() > maxBatchBytes {
+			send()
+		}
+		if len(batch) == 0 {
+			linger = p.k.clock.NewTimer(producerLinger)
+		}
+		batch = append(batch, r)
+		size += r.size()
+	}
+
+	// leave keeps what was not flushed before Close gave up
+	leave := func() {
+		for {
+			select {
+			case r := <-p.queue:
+				batch = append(batch, r)
+			default:
+				p.keepUnsent(batch)
+				return
+			}
+		}
+	}
+
+	for {
+		// once closed, nothing is sent anymore
+		select {
+		case <-p.quit:
+			leave()
+			return
+		default:
+		}
+
+		var lingered <-chan time.Time
+		if linger != nil {
+			lingered = linger.C()
+		}
+		select {
+		case r := <-p.queue:
+			add(r)
+		case <-lingered:
+			send()
+		case <-p.flushCh:
+			// drain what was buffered before the flush
+		drain:
+			for n := len(p.queue); n > 0; n-- {
+				select {
+				case r := <-p.queue:
+					add(r)
+				default:
+					break drain
+				}
+			}
+			send()
+		case <-p.quit:
+			leave()
+			return
+		}
+	}
+}
+
+// keepUnsent keeps the records for Close to return.
+func (p *Producer) keepUnsent(batch []*pendingRecord) {
+	p.mu.Lock()
+	for _, r := range batch {
+		p.unsent = append(p.unsent, UnsentRecord{
+			PartitionKey:    r.partitionKey,
+			ExplicitHashKey: r.explicitHashKey,
+			Data:            r.data,
+		})
+	}
+	p.mu.Unlock()
+	p.done(len(batch), nil)
+}
+
+// send puts the batch, retrying the records which failed with the backoff.
+func (p *Producer) send(batch []*pendingRecord) {
+	remaining := batch
+	var firstErr error
+	var d time.Duration
+	p.metrics.Observe(MetricBatchSize, float64(len(batch)), "stream", p.streamName)
+
+	for attempt := 1; len(remaining) > 0; attempt++ {
+		entries := make([]*kinesis.PutRecordsRequestEntry, 0, len(remaining))
+		for _, r := range remaining {
+			entry := &kinesis.PutRecordsRequestEntry{
+				Data:         r.data,
+				PartitionKey: aws.String(r.partitionKey),
+			}
+			if r.explicitHashKey != "" {
+				entry.ExplicitHashKey = aws.String(r.explicitHashKey)
+			}
+			entries = append(entries, entry)
+		}
+
+		out, err := p.k.svc.PutRecords(&kinesis.PutRecordsInput{
+			Records:    entries,
+			StreamName: aws.String(p.streamName),
+		})
+
+		var failed []*pendingRecord
+		var errs []error
+		if err != nil {
+			failed = remaining
+			for range remaining {
+				errs = append(errs, err)
+			}
+		} else {
+			for i, r := range out.Records {
+				if r.ErrorCode != nil {
+					failed = append(failed, remaining[i])
+					errs = append(errs, &PutRecordError{Code: *r.ErrorCode, Message: aws.StringValue(r.ErrorMessage)})
+					if *r.ErrorCode == kinesis.ErrCodeProvisionedThroughputExceededException {
+						p.metrics.Add(MetricPutThrottles, 1, "stream", p.streamName)
+					}
+					continue
+				}
+				p.metrics.Add(MetricRecordsSent, 1, "stream", p.streamName)
+				p.metrics.Add(MetricBytesSent, float64(remaining[i].size()), "stream", p.streamName)
+				p.report(PutResult{
+					PartitionKey:   remaining[i].partitionKey,
+					ShardID:        aws.StringValue(r.ShardId),
+					SequenceNumber: aws.StringValue(r.SequenceNumber),
+				})
+			}
+		}
+
+		if len(failed) > 0 && attempt >= p.attempts {
+			for i, r := range failed {
+				p.report(PutResult{PartitionKey: r.partitionKey, Err: errs[i]})
+			}
+			firstErr = fmt.Errorf("%d of %d records failed: %w", len(failed), len(batch), errs[0])
+			break
+		}
+
+		remaining = failed
+		if len(remaining) > 0 {
+			p.metrics.Add(MetricPutRetries, float64(len(remaining)), "stream", p.streamName)
+			d = p.backoff.next(d)
+			p.k.sleep(context.Background(), d)
+		}
+	}
+
+	p.done(len(batch), firstErr)
+}
+
+func (p *Producer) report(r PutResult) {
+	if p.callback != nil {
+		p.callback(r)
+	}
+}
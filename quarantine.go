@@ -0,0 +1,120 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+type quarantine struct {
+	threshold int
+	queue     DeadLetterQueue
+}
+
+// WithQuarantine counts the attempts to handle each record in the
+// checkpoint store, which must implement AttemptStore, and sends the records
+// attempted more than threshold times to q instead of handling them again,
+// e.g. records crashing the process or failing to decrypt. The retries of
+// a failing handler count as attempts too. This keeps a single malformed
+// record from stalling a shard. Counting costs a write to the store per
+// record. Run fails when q is nil.
+func WithQuarantine(threshold int, q DeadLetterQueue) ConsumerOption {
+	return func(c *Consumer) {
+		c.quarantine = &quarantine{threshold: threshold, queue: q}
+	}
+}
+
+// checkQuarantine verifies that the quarantine has a queue and that the
+// checkpoint store can count attempts.
+func (c *Consumer) checkQuarantine() error {
+	if c.quarantine == nil {
+		return nil
+	}
+	if c.quarantine.queue == nil {
+		return errors.New("quarantine: no queue")
+	}
+	if _, ok := c.checkpoint.(AttemptStore); !ok {
+		return fmt.Errorf("quarantine: checkpoint store %T does not count attempts", c.checkpoint)
+	}
+	return nil
+}
+
+// process decodes and handles the record, unless it is a heartbeat, is
+// quarantined or was handled within the dedup window.
+func (c *Consumer) process(ctx context.Context, shardID string, r *kinesis.Record) error {
+	record, err := c.prepare(ctx, shardID, r)
+	if record == nil || err != nil {
+		return err
+	}
+	if err := c.handle(ctx, record); err != nil {
+		return err
+	}
+	c.dedup.add(record)
+	return nil
+}
+
+// prepare decodes the record, returning nil when it is a heartbeat, is
+// quarantined or was handled within the dedup window.
+func (c *Consumer) prepare(ctx context.Context, shardID string, r *kinesis.Record) (*Record, error) {
+	if isHeartbeat(r.Data) {
+		c.heartbeat.beat(shardID)
+		return nil, nil
+	}
+
+	if c.quarantine != nil {
+		n, err := c.checkpoint.(AttemptStore).AddAttempt(ctx, c.streamName, shardID, *r.SequenceNumber)
+		if err != nil {
+			return nil, err
+		}
+		if n > c.quarantine.threshold {
+			log.Printf("kine: consumer %s: quarantined %s %s after %d attempts", c.streamName, shardID, *r.SequenceNumber, n-1)
+			record := &Record{
+				StreamName:     c.streamName,
+				ShardID:        shardID,
+				SequenceNumber: *r.SequenceNumber,
+				PartitionKey:   *r.PartitionKey,
+				ArrivalTime:    aws.TimeValue(r.ApproximateArrivalTimestamp),
+				Data:           r.Data,
+				codec:          c.codec,
+			}
+			return nil, c.quarantine.queue.Send(ctx, record, fmt.Errorf("quarantined after %d attempts", n-1))
+		}
+	}
+
+	record, err := c.newRecord(ctx, shardID, r)
+	if err != nil {
+		return nil, err
+	}
+	if c.dedup.seen(record) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// quarantineRetry counts a retry of the records, and sends them to the
+// quarantine queue when the last of them was attempted more than threshold
+// times, reporting whether it did.
+func (c *Consumer) quarantineRetry(ctx context.Context, records []*Record, err error) (bool, error) {
+	if c.quarantine == nil {
+		return false, nil
+	}
+	last := records[len(records)-1]
+	n, aerr := c.checkpoint.(AttemptStore).AddAttempt(ctx, c.streamName, last.ShardID, last.SequenceNumber)
+	if aerr != nil {
+		return false, aerr
+	}
+	if n <= c.quarantine.threshold {
+		return false, nil
+	}
+	for _, r := range records {
+		log.Printf("kine: consumer %s: quarantined %s %s after %d attempts: %v", c.streamName, r.ShardID, r.SequenceNumber, n-1, err)
+		if err := c.quarantine.queue.Send(ctx, r, fmt.Errorf("quarantined after %d attempts: %w", n-1, err)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
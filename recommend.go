@@ -0,0 +1,124 @@
+package kine
+
+import (
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Per-shard limits of Kinesis Data Streams.
+const (
+	shardWriteBytesPerSecond   = 1024 * 1024
+	shardWriteRecordsPerSecond = 1000
+	shardReadBytesPerSecond    = 2 * 1024 * 1024
+
+	defaultHeadroom = 0.2
+
+	// GetMetricStatistics returns at most 1440 datapoints per call.
+	maxDatapoints = 1440
+)
+
+type recommendConfig struct {
+	headroom float64
+}
+
+type RecommendOption func(c *recommendConfig)
+
+// WithHeadroom sets the spare capacity added on top of the observed peak,
+// e.g. 0.2 for 20%. The default is 20%.
+func WithHeadroom(headroom float64) RecommendOption {
+	return func(c *recommendConfig) {
+		c.headroom = headroom
+	}
+}
+
+type Recommendation struct {
+	StreamName        string
+	Lookback          time.Duration
+	CurrentShardCount int
+	ShardCount        int
+
+	PeakIncomingBytesPerSecond   float64
+	PeakIncomingRecordsPerSecond float64
+	PeakOutgoingBytesPerSecond   float64
+}
+
+// Recommend looks at the stream-level CloudWatch metrics over the lookback
+// window and recommends the shard count needed for the observed peak plus headroom.
+func (k *Kine) Recommend(streamName string, lookback time.Duration, opts ...RecommendOption) (*Recommendation, error) {
+	c := &recommendConfig{headroom: defaultHeadroom}
+	for _, o := range opts {
+		o(c)
+	}
+
+	stream, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recommendation{
+		StreamName:        streamName,
+		Lookback:          lookback,
+		CurrentShardCount: int(*stream.StreamDescriptionSummary.OpenShardCount),
+	}
+
+	period := metricPeriod(lookback)
+	peaks := []struct {
+		metricName string
+		peak       *float64
+	}{
+		{kinesis.MetricsNameIncomingBytes, &r.PeakIncomingBytesPerSecond},
+		{kinesis.MetricsNameIncomingRecords, &r.PeakIncomingRecordsPerSecond},
+		{"GetRecords.Bytes", &r.PeakOutgoingBytesPerSecond},
+	}
+	for _, p := range peaks {
+		*p.peak, err = k.peakPerSecond(streamName, p.metricName, period, lookback)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	needed := math.Max(
+		r.PeakIncomingBytesPerSecond/shardWriteBytesPerSecond,
+		r.PeakIncomingRecordsPerSecond/shardWriteRecordsPerSecond,
+	)
+	needed = math.Max(needed, r.PeakOutgoingBytesPerSecond/shardReadBytesPerSecond)
+
+	r.ShardCount = int(math.Ceil(needed * (1 + c.headroom)))
+	if r.ShardCount < 1 {
+		r.ShardCount = 1
+	}
+
+	return r, nil
+}
+
+// metricPeriod returns the smallest whole-minute period which fits the
+// lookback window into one GetMetricStatistics call.
+func metricPeriod(lookback time.Duration) time.Duration {
+	period := time.Minute
+	for lookback/period > maxDatapoints {
+		period += time.Minute
+	}
+	return period
+}
+
+func (k *Kine) peakPerSecond(streamName, metricName string, period, lookback time.Duration) (float64, error) {
+	datapoints, err := k.getMetric(metricName, map[string]string{"StreamName": streamName},
+		cloudwatch.StatisticSum, period, lookback)
+	if err != nil {
+		return 0, err
+	}
+
+	var peak float64
+	for _, d := range datapoints {
+		if v := *d.Sum / period.Seconds(); v > peak {
+			peak = v
+		}
+	}
+	return peak, nil
+}
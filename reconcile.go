@@ -0,0 +1,161 @@
+package kine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// StreamSpecFile is the format of the files read by LoadStreamSpecs:
+//
+//	streams:
+//	  - name: events
+//	    shardCount: 4
+//	    retentionHours: 48
+//	    kmsKeyId: alias/aws/kinesis
+//	    tags:
+//	      team: data
+type StreamSpecFile struct {
+	Streams []StreamSpec `json:"streams" yaml:"streams"`
+}
+
+// LoadStreamSpecs reads stream specs in YAML (or JSON, which is valid YAML).
+func LoadStreamSpecs(r io.Reader) ([]StreamSpec, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var f StreamSpecFile
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, err
+	}
+	return f.Streams, nil
+}
+
+func LoadStreamSpecsFile(path string) ([]StreamSpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadStreamSpecs(f)
+}
+
+// StreamPlan is the changes planned for one stream.
+type StreamPlan struct {
+	Spec    StreamSpec
+	Changes []Change
+}
+
+func (p *StreamPlan) creates() bool {
+	return len(p.Changes) > 0 && p.Changes[0].Field == "stream"
+}
+
+// ReconcilePlan is the difference between the desired and actual
+// configuration of many streams.
+type ReconcilePlan struct {
+	Streams []StreamPlan
+}
+
+// Empty reports whether every stream already matches its spec.
+func (p *ReconcilePlan) Empty() bool {
+	for _, s := range p.Streams {
+		if len(s.Changes) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the plan like a Terraform plan preview.
+func (p *ReconcilePlan) String() string {
+	buf := &bytes.Buffer{}
+	var create, update int
+	for _, s := range p.Streams {
+		switch {
+		case len(s.Changes) == 0:
+			continue
+		case s.creates():
+			create++
+			fmt.Fprintf(buf, "+ %s\n", s.Spec.Name)
+			fmt.Fprintf(buf, "    shard count: %d\n", s.Spec.ShardCount)
+			fmt.Fprintf(buf, "    retention hours: %d\n", s.Spec.retentionHours())
+			if s.Spec.KMSKeyID != "" {
+				fmt.Fprintf(buf, "    encryption key: %s\n", s.Spec.KMSKeyID)
+			}
+			keys := make([]string, 0, len(s.Spec.Tags))
+			for key := range s.Spec.Tags {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(buf, "    tag %s: %s\n", key, s.Spec.Tags[key])
+			}
+		default:
+			update++
+			fmt.Fprintf(buf, "~ %s\n", s.Spec.Name)
+			for _, c := range s.Changes {
+				fmt.Fprintf(buf, "    %s: %q -> %q\n", c.Field, c.From, c.To)
+			}
+		}
+	}
+	fmt.Fprintf(buf, "Plan: %d to create, %d to change.\n", create, update)
+	return buf.String()
+}
+
+// PlanReconcile diffs the specs against the actual streams without changing anything.
+func (k *Kine) PlanReconcile(specs []StreamSpec) (*ReconcilePlan, error) {
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("stream %s is declared twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		if spec.ShardCount < 1 {
+			return nil, fmt.Errorf("%s: invalid shard count %d", spec.Name, spec.ShardCount)
+		}
+	}
+
+	plan := &ReconcilePlan{}
+	for _, spec := range specs {
+		changes, err := k.DiffStream(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.Name, err)
+		}
+		plan.Streams = append(plan.Streams, StreamPlan{Spec: spec, Changes: changes})
+	}
+	return plan, nil
+}
+
+// ApplyReconcile converges every stream of the plan which has changes.
+// Failed streams do not stop the others; they are returned as a *BatchError.
+func (k *Kine) ApplyReconcile(plan *ReconcilePlan) error {
+	errs := make(map[string]error)
+	for _, s := range plan.Streams {
+		if len(s.Changes) == 0 {
+			continue
+		}
+		if _, err := k.EnsureStream(s.Spec); err != nil {
+			errs[s.Spec.Name] = err
+		}
+	}
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// Reconcile plans and applies the specs, returning the plan.
+func (k *Kine) Reconcile(specs []StreamSpec) (*ReconcilePlan, error) {
+	plan, err := k.PlanReconcile(specs)
+	if err != nil {
+		return nil, err
+	}
+	return plan, k.ApplyReconcile(plan)
+}
@@ -0,0 +1,85 @@
+package kine
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// TopologyFormat is an output format of Render.
+type TopologyFormat string
+
+const (
+	// TopologyTable is the table printed by View: the shard ID and its
+	// share of the hash space.
+	TopologyTable TopologyFormat = "table"
+	// TopologyJSON is an array of objects with the shard ID, hash key
+	// range and share.
+	TopologyJSON TopologyFormat = "json"
+	// TopologyCSV has the columns of TopologyJSON and a header line.
+	TopologyCSV TopologyFormat = "csv"
+)
+
+type topologyShard struct {
+	ShardID         string  `json:"shardId"`
+	StartingHashKey string  `json:"startingHashKey"`
+	EndingHashKey   string  `json:"endingHashKey"`
+	Share           float64 `json:"share"`
+}
+
+// Render writes the topology in the format. The output only depends on the
+// topology, so that tools can render topologies obtained elsewhere, and
+// changes to it are deliberate: the shards are written in hash key order
+// and the shares are rounded to the printed precision.
+func Render(t *Topology, format TopologyFormat, w io.Writer) error {
+	switch format {
+	case TopologyTable:
+		table := tablewriter.NewWriter(w)
+		for _, s := range t.Shards {
+			table.Append([]string{s.ShardID, fmt.Sprintf("%.2f %%", s.Range.Share()*100)})
+		}
+		table.Render()
+		return nil
+
+	case TopologyJSON:
+		shards := make([]topologyShard, 0, len(t.Shards))
+		for _, s := range t.Shards {
+			shards = append(shards, topologyShard{
+				ShardID:         s.ShardID,
+				StartingHashKey: s.Range.Start.String(),
+				EndingHashKey:   s.Range.End.String(),
+				Share:           roundShare(s.Range.Share()),
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(shards)
+
+	case TopologyCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"shard_id", "starting_hash_key", "ending_hash_key", "share"})
+		for _, s := range t.Shards {
+			cw.Write([]string{
+				s.ShardID,
+				s.Range.Start.String(),
+				s.Range.End.String(),
+				fmt.Sprintf("%.6f", s.Range.Share()),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return fmt.Errorf("unknown topology format %q", format)
+	}
+}
+
+// roundShare rounds to six decimals, so that float noise does not change
+// the JSON output.
+func roundShare(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,48 @@
+package kine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// renderTopology returns the topology of a stream of three shards, the
+// first of which was split in two.
+func renderTopology(t *testing.T) *Topology {
+	ranges, err := hashrange.Full.Divide(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, upper, err := ranges[0].SplitAt(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Topology{
+		StreamName: "orders",
+		Shards: []ShardRange{
+			{ShardID: "shardId-000000000003", Range: lower},
+			{ShardID: "shardId-000000000004", Range: upper},
+			{ShardID: "shardId-000000000001", Range: ranges[1]},
+			{ShardID: "shardId-000000000002", Range: ranges[2]},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, format := range []TopologyFormat{TopologyTable, TopologyJSON, TopologyCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Render(renderTopology(t), format, buf); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "render_"+string(format), buf.Bytes())
+		})
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if err := Render(renderTopology(t), "yaml", &bytes.Buffer{}); err == nil {
+		t.Error("no error for an unknown format")
+	}
+}
@@ -0,0 +1,138 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+type repairConfig struct {
+	shardCount int
+	tolerance  float64
+}
+
+type RepairOption func(c *repairConfig)
+
+// WithShardCount sets the shard count of the repaired topology.
+// By default the current open shard count is kept.
+func WithShardCount(n int) RepairOption {
+	return func(c *repairConfig) {
+		c.shardCount = n
+	}
+}
+
+// WithTolerance lets an existing boundary be kept when it is within
+// tolerance of an even share (e.g. 0.05 for 5%) of the ideal boundary,
+// trading exactness for fewer splits and merges.
+func WithTolerance(tolerance float64) RepairOption {
+	return func(c *repairConfig) {
+		c.tolerance = tolerance
+	}
+}
+
+// RepairBoundaries returns the evenly tiled target for the current ranges,
+// reusing current boundaries within the tolerance.
+func RepairBoundaries(current []hashrange.Range, opts ...RepairOption) ([]hashrange.Range, error) {
+	c := &repairConfig{shardCount: len(current)}
+	for _, o := range opts {
+		o(c)
+	}
+
+	ideal, err := hashrange.Full.Divide(c.shardCount)
+	if err != nil {
+		return nil, err
+	}
+	if c.tolerance <= 0 {
+		return ideal, nil
+	}
+
+	existing := make([]hashrange.Key, 0, len(current))
+	for _, r := range current {
+		if r.Start != hashrange.MinKey {
+			existing = append(existing, r.Start)
+		}
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].Cmp(existing[j]) < 0
+	})
+
+	even, _ := hashrange.Full.Width().DivUint64(uint64(c.shardCount))
+	limit := even.MulFrac(c.tolerance)
+
+	starts := []hashrange.Key{hashrange.MinKey}
+	for _, r := range ideal[1:] {
+		b := r.Start
+		if near, ok := nearestKey(existing, b); ok && distance(near, b).Cmp(limit) <= 0 {
+			b = near
+		}
+		if b.Cmp(starts[len(starts)-1]) > 0 {
+			starts = append(starts, b)
+		}
+	}
+
+	target := make([]hashrange.Range, 0, len(starts))
+	for i, s := range starts {
+		end := hashrange.MaxKey
+		if i+1 < len(starts) {
+			end = starts[i+1].Prev()
+		}
+		target = append(target, hashrange.Range{Start: s, End: end})
+	}
+	return target, nil
+}
+
+func nearestKey(sorted []hashrange.Key, k hashrange.Key) (hashrange.Key, bool) {
+	if len(sorted) == 0 {
+		return hashrange.Key{}, false
+	}
+	i := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].Cmp(k) >= 0
+	})
+	switch {
+	case i == 0:
+		return sorted[0], true
+	case i == len(sorted):
+		return sorted[i-1], true
+	case distance(sorted[i-1], k).Cmp(distance(sorted[i], k)) <= 0:
+		return sorted[i-1], true
+	}
+	return sorted[i], true
+}
+
+func distance(a, b hashrange.Key) hashrange.Key {
+	if a.Cmp(b) > 0 {
+		return a.Sub(b)
+	}
+	return b.Sub(a)
+}
+
+// PlanRepair returns the splits and merges turning the stream back into an
+// evenly tiled topology. Topologies with gaps or overlaps cannot be repaired
+// by splitting and merging, and are reported as an error.
+func (k *Kine) PlanRepair(streamName string, opts ...RepairOption) (*Plan, error) {
+
+	report, err := k.CheckTopology(streamName)
+	if err != nil {
+		return nil, err
+	}
+	if !report.OK() {
+		return nil, fmt.Errorf("cannot repair %s: %v", streamName, report.Anomalies[0])
+	}
+
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return topology.PlanRepair(opts...)
+}
+
+// Repair plans and applies the repair of the stream, returning the plan.
+func (k *Kine) Repair(streamName string, opts ...RepairOption) (*Plan, error) {
+	plan, err := k.PlanRepair(streamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return plan, k.Apply(plan)
+}
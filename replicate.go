@@ -0,0 +1,212 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicationLagExpiry is how long the lag of a shard counts after it was
+// last read, so that parents read to their end are forgotten.
+const replicationLagExpiry = time.Minute
+
+// Replicator copies the records of a stream into another one, keeping their
+// partition keys. Records are put asynchronously, so the order of a
+// partition key is only kept as long as the puts do not need retries.
+type Replicator struct {
+	consumer *Consumer
+	producer *Producer
+	copied   int64
+	lag      *replicationLag
+
+	mu      sync.Mutex
+	running bool
+	err     error
+	done    chan struct{}
+}
+
+// NewReplicator returns a replicator from srcStream into dstStream, starting
+// at TRIM_HORIZON unless opts set another start position.
+func (k *Kine) NewReplicator(srcStream, dstStream string, opts ...ConsumerOption) *Replicator {
+	r := &Replicator{
+		producer: k.NewProducer(dstStream),
+		lag:      &replicationLag{shards: make(map[string]lagSample)},
+		done:     make(chan struct{}),
+	}
+	opts = append([]ConsumerOption{WithStartPosition(StartTrimHorizon)}, opts...)
+	r.consumer = k.NewConsumer(srcStream, r.copy, opts...)
+	r.consumer.metrics = teeMetrics{r.consumer.metrics, r.lag}
+	return r
+}
+
+// Lag returns how far the replicator is behind the tip of the source stream,
+// the largest MillisBehindLatest of the shards being read. It is false
+// before any shard reported its lag.
+func (r *Replicator) Lag() (time.Duration, bool) {
+	return r.lag.max()
+}
+
+func (r *Replicator) copy(ctx context.Context, rec *Record) error {
+	if err := r.producer.Put(ctx, rec.PartitionKey, rec.Data); err != nil {
+		return err
+	}
+	atomic.AddInt64(&r.copied, 1)
+	return nil
+}
+
+// Copied returns the number of records copied so far.
+func (r *Replicator) Copied() int64 {
+	return atomic.LoadInt64(&r.copied)
+}
+
+// Run copies records until ctx is done, copying fails or the replicator is
+// shut down, and flushes the records put before returning. A replicator
+// runs only once.
+func (r *Replicator) Run(ctx context.Context) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+	return r.run(ctx)
+}
+
+// start runs the replicator in the background, so that Shutdown waits for
+// it even when called right away.
+func (r *Replicator) start() error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+	go r.run(context.Background())
+	return nil
+}
+
+func (r *Replicator) begin() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return errors.New("replicator already ran")
+	}
+	r.running = true
+	return nil
+}
+
+func (r *Replicator) run(ctx context.Context) error {
+	err := r.consumer.Run(ctx)
+	if cerr := r.producer.Close(context.Background()); err == nil {
+		err = cerr
+	}
+
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+	close(r.done)
+	return err
+}
+
+// Shutdown stops reading the source stream and waits until the records read
+// are written, returning the error Run returned.
+func (r *Replicator) Shutdown(ctx context.Context) error {
+	if err := r.consumer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type lagSample struct {
+	lag time.Duration
+	at  time.Time
+}
+
+// replicationLag collects the MillisBehindLatest the consumer reports.
+type replicationLag struct {
+	mu     sync.Mutex
+	shards map[string]lagSample
+}
+
+func (l *replicationLag) Add(name string, value float64, labels ...string)     {}
+func (l *replicationLag) Observe(name string, value float64, labels ...string) {}
+
+func (l *replicationLag) Set(name string, value float64, labels ...string) {
+	if name != MetricConsumerLag {
+		return
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		if labels[i] == "shard" {
+			l.mu.Lock()
+			l.shards[labels[i+1]] = lagSample{
+				lag: time.Duration(value) * time.Millisecond,
+				at:  time.Now(),
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *replicationLag) max() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var max time.Duration
+	found := false
+	for _, s := range l.shards {
+		if time.Since(s.at) > replicationLagExpiry {
+			continue
+		}
+		found = true
+		if s.lag > max {
+			max = s.lag
+		}
+	}
+	return max, found
+}
+
+// caughtUp reports whether every shard being read reported, since t, that
+// it is at the tip of the stream.
+func (l *replicationLag) caughtUp(t time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	found := false
+	for _, s := range l.shards {
+		if time.Since(s.at) > replicationLagExpiry {
+			continue
+		}
+		if s.at.Before(t) || s.lag > 0 {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// teeMetrics reports to two Metrics.
+type teeMetrics [2]Metrics
+
+func (t teeMetrics) Add(name string, value float64, labels ...string) {
+	t[0].Add(name, value, labels...)
+	t[1].Add(name, value, labels...)
+}
+
+func (t teeMetrics) Set(name string, value float64, labels ...string) {
+	t[0].Set(name, value, labels...)
+	t[1].Set(name, value, labels...)
+}
+
+func (t teeMetrics) Observe(name string, value float64, labels ...string) {
+	t[0].Observe(name, value, labels...)
+	t[1].Observe(name, value, labels...)
+}
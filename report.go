@@ -0,0 +1,220 @@
+package kine
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	reportOperations = 20
+	reportLookback   = 30 * 24 * time.Hour
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f %%", v*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.StreamName}} – kine report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+.bar { fill: #4a7ebb; }
+.failed { color: #b00; }
+</style>
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: true });
+</script>
+</head>
+<body>
+<h1>{{.StreamName}}</h1>
+<p>Generated {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Shard distribution</h2>
+<svg width="720" height="{{.ChartHeight}}">
+{{range .Bars}}<text x="0" y="{{.Y}}" dy="12" font-size="12">{{.ShardID}}</text>
+<rect class="bar" x="180" y="{{.Y}}" width="{{.Width}}" height="14"></rect>
+<text x="{{.LabelX}}" y="{{.Y}}" dy="12" font-size="12">{{percent .Share}}</text>
+{{end}}</svg>
+
+<h2>Metrics</h2>
+<table>
+<tr><th>open shards</th><td>{{.Balance.OpenShardCount}}</td></tr>
+<tr><th>widest shard</th><td>{{percent .Balance.MaxRangeShare}}</td></tr>
+<tr><th>narrowest shard</th><td>{{percent .Balance.MinRangeShare}}</td></tr>
+<tr><th>skew ratio</th><td>{{printf "%.2f" .Balance.SkewRatio}}</td></tr>
+<tr><th>hot shards</th><td>{{if lt .Balance.HotShards 0}}shard metrics disabled{{else}}{{.Balance.HotShards}}{{end}}</td></tr>
+<tr><th>iterator age</th><td>{{.Lag.Latest}} (max {{.Lag.Max}})</td></tr>
+</table>
+
+<h2>Lineage</h2>
+<pre class="mermaid">
+{{.Lineage}}</pre>
+
+<h2>Latest operations</h2>
+{{if .Operations}}<table>
+<tr><th>time</th><th>operation</th><th>duration</th><th>outcome</th></tr>
+{{range .Operations}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Operation}}</td><td>{{.Duration}}</td><td{{if .Error}} class="failed"{{end}}>{{if .Error}}{{.Error}}{{else}}ok{{end}}</td></tr>
+{{end}}</table>{{else}}<p>No operations recorded.</p>{{end}}
+</body>
+</html>
+`))
+
+type reportBar struct {
+	ShardID string
+	Share   float64
+	Y       int
+	Width   int
+	LabelX  int
+}
+
+type reportOperation struct {
+	Time      time.Time
+	Operation string
+	Duration  time.Duration
+	Error     string
+}
+
+type reportData struct {
+	StreamName  string
+	Time        time.Time
+	Bars        []reportBar
+	ChartHeight int
+	Balance     *Balance
+	Lag         *Lag
+	Lineage     string
+	Operations  []reportOperation
+}
+
+// Report writes a standalone HTML report of the stream, to attach to change
+// tickets and incident reviews: the share of the hash space of every open
+// shard, the balance and consumer lag, the lineage of the shards as a
+// Mermaid diagram, and the latest operations from the OperationStore, or
+// else from the audit history. The diagram is drawn by Mermaid loaded from
+// a CDN when the report is opened.
+func (k *Kine) Report(streamName string, w io.Writer) error {
+	snapshot, err := k.Snapshot(streamName)
+	if err != nil {
+		return err
+	}
+
+	data := &reportData{
+		StreamName: streamName,
+		Time:       snapshot.Time,
+		Balance:    snapshot.Balance,
+		Lag:        snapshot.Lag,
+	}
+
+	const barHeight, maxBar = 18, 440
+	var widest float64
+	for _, s := range snapshot.Topology.Shards {
+		if share := s.Range.Share(); share > widest {
+			widest = share
+		}
+	}
+	for i, s := range snapshot.Topology.Shards {
+		width := 0
+		if widest > 0 {
+			width = int(s.Range.Share() / widest * maxBar)
+		}
+		data.Bars = append(data.Bars, reportBar{
+			ShardID: s.ShardID,
+			Share:   s.Range.Share(),
+			Y:       i * barHeight,
+			Width:   width,
+			LabelX:  180 + width + 6,
+		})
+	}
+	data.ChartHeight = len(data.Bars) * barHeight
+
+	if data.Lineage, err = k.lineageDiagram(streamName); err != nil {
+		return err
+	}
+	if data.Operations, err = k.reportOperations(streamName); err != nil {
+		return err
+	}
+
+	return reportTemplate.Execute(w, data)
+}
+
+// lineageDiagram returns the Mermaid flowchart of the shards of the stream
+// still within the retention period, from parents to children.
+func (k *Kine) lineageDiagram(streamName string) (string, error) {
+	var shards []*kinesis.Shard
+	err := k.listShards(streamName, func(page []*kinesis.Shard) error {
+		shards = append(shards, page...)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("graph LR\n")
+	node := func(shardID string) string {
+		return "s" + shardNumber(shardID)
+	}
+	for _, s := range shards {
+		fmt.Fprintf(buf, "  %s[\"%s\"]\n", node(*s.ShardId), *s.ShardId)
+		if s.SequenceNumberRange.EndingSequenceNumber == nil {
+			fmt.Fprintf(buf, "  class %s open\n", node(*s.ShardId))
+		}
+	}
+	for _, s := range shards {
+		for _, parent := range []*string{s.ParentShardId, s.AdjacentParentShardId} {
+			if p := aws.StringValue(parent); p != "" {
+				fmt.Fprintf(buf, "  %s --> %s\n", node(p), node(*s.ShardId))
+			}
+		}
+	}
+	buf.WriteString("  classDef open fill:#cfe3ff\n")
+	return buf.String(), nil
+}
+
+// reportOperations returns the latest operations, most recent first.
+func (k *Kine) reportOperations(streamName string) ([]reportOperation, error) {
+	since := k.clock.Now().Add(-reportLookback)
+	var ops []reportOperation
+	switch {
+	case k.opStore != nil:
+		records, err := k.opStore.Operations(streamName, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			ops = append(ops, reportOperation{Time: r.Started, Operation: r.Operation, Duration: r.Duration, Error: r.Error})
+		}
+	default:
+		events, err := k.History(streamName, since)
+		if err == ErrNoAuditHistory {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			ops = append(ops, reportOperation{Time: e.Time, Operation: e.Operation, Duration: e.Duration, Error: e.Error})
+		}
+	}
+
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Time.After(ops[j].Time)
+	})
+	if len(ops) > reportOperations {
+		ops = ops[:reportOperations]
+	}
+	for i := range ops {
+		ops[i].Duration = ops[i].Duration.Round(time.Millisecond)
+	}
+	return ops, nil
+}
@@ -0,0 +1,105 @@
+package kine
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Snapshot is the state of a stream at a moment, as pushed by a Reporter.
+type Snapshot struct {
+	StreamName string
+	Time       time.Time
+	Topology   *Topology
+	Balance    *Balance
+	// Lag is the lag of the polling consumers.
+	Lag *Lag
+}
+
+// Snapshot collects the topology, balance and consumer lag of the stream.
+func (k *Kine) Snapshot(streamName string) (*Snapshot, error) {
+	s := &Snapshot{
+		StreamName: streamName,
+		Time:       k.clock.Now(),
+	}
+	var err error
+	if s.Topology, err = k.Topology(streamName); err != nil {
+		return nil, err
+	}
+	if s.Balance, err = k.Balance(streamName); err != nil {
+		return nil, err
+	}
+	if s.Lag, err = k.ConsumerLag(streamName, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReportSink receives the snapshots of a Reporter.
+type ReportSink interface {
+	Report(s *Snapshot) error
+}
+
+type ReportFunc func(s *Snapshot) error
+
+func (f ReportFunc) Report(s *Snapshot) error {
+	return f(s)
+}
+
+// ReportChannel sends the snapshots to ch. A snapshot is dropped when ch is
+// full, so that a slow reader does not delay the next ones.
+func ReportChannel(ch chan<- *Snapshot) ReportSink {
+	return ReportFunc(func(s *Snapshot) error {
+		select {
+		case ch <- s:
+		default:
+			log.Printf("kine: reporter %s: channel full, snapshot dropped", s.StreamName)
+		}
+		return nil
+	})
+}
+
+// Reporter pushes snapshots of a stream to a sink in the background.
+type Reporter struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartReporter pushes a snapshot of the stream to the sink every interval
+// until ctx is done or Stop is called, so that services embedding kine can
+// feed their own dashboards. Failing snapshots and sinks are logged.
+func (k *Kine) StartReporter(ctx context.Context, streamName string, interval time.Duration, sink ReportSink) *Reporter {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Reporter{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s, err := k.Snapshot(streamName)
+			if err != nil {
+				log.Printf("kine: reporter %s: %v", streamName, err)
+			} else if err := sink.Report(s); err != nil {
+				log.Printf("kine: reporter %s: sink: %v", streamName, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return r
+}
+
+// Stop stops the reporter and waits for the snapshot in progress, if any.
+func (r *Reporter) Stop() {
+	r.cancel()
+	<-r.done
+}
@@ -0,0 +1,164 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// FailureAction is what the consumer does with a record whose handler failed
+// all its attempts, or what an ErrorHandler decides after each failure.
+type FailureAction int
+
+const (
+	// FailureStopConsumer stops the consumer, returning the error from Run.
+	FailureStopConsumer FailureAction = iota
+	// FailureRetry keeps retrying the record with the backoff, blocking the shard.
+	FailureRetry
+	// FailureSkip logs the error and goes on with the next record.
+	FailureSkip
+	// FailureStopShard stops reading the shard, checkpointing the records
+	// before the failed one, while the other shards go on.
+	FailureStopShard
+	// FailureDeadLetter sends the record to the dead-letter queue and goes on.
+	FailureDeadLetter
+)
+
+func (a FailureAction) String() string {
+	switch a {
+	case FailureStopConsumer:
+		return "stop consumer"
+	case FailureRetry:
+		return "retry"
+	case FailureSkip:
+		return "skip"
+	case FailureStopShard:
+		return "stop shard"
+	case FailureDeadLetter:
+		return "dead letter"
+	}
+	return fmt.Sprintf("FailureAction(%d)", int(a))
+}
+
+// RetryPolicy is how the consumer retries a failing handler.
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls of the handler per record before
+	// OnFailure applies, at least 1.
+	MaxAttempts int
+	// Backoff is the delay between attempts, DefaultBackoff when zero.
+	Backoff   Backoff
+	OnFailure FailureAction
+}
+
+// DefaultRetryPolicy calls the handler once and stops the consumer on error.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 1,
+	OnFailure:   FailureStopConsumer,
+}
+
+// DeadLetterQueue receives the records whose handler failed.
+type DeadLetterQueue interface {
+	Send(ctx context.Context, r *Record, err error) error
+}
+
+// ErrorHandler decides what the consumer does with a record whose handler
+// failed attempt times, the last time with err, e.g. depending on the type
+// of err. FailureRetry calls the handler again after the backoff. For a
+// batch consumer, r is the first record of the batch and the decision
+// applies to the whole batch.
+type ErrorHandler func(r *Record, err error, attempt int) FailureAction
+
+var errStopShard = errors.New("shard stopped")
+
+// WithRetryPolicy sets how failing handlers are retried, DefaultRetryPolicy by default.
+func WithRetryPolicy(p RetryPolicy) ConsumerOption {
+	return func(c *Consumer) {
+		c.retry = p
+	}
+}
+
+// WithErrorHandler makes h decide what to do after each failure of the
+// handler, instead of the retry policy, whose backoff still applies.
+func WithErrorHandler(h ErrorHandler) ConsumerOption {
+	return func(c *Consumer) {
+		c.onError = h
+	}
+}
+
+// WithDeadLetterQueue sets where records go with FailureDeadLetter.
+func WithDeadLetterQueue(q DeadLetterQueue) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetter = q
+	}
+}
+
+// handle calls the handler following the retry policy. It returns errStopShard
+// when the shard must stop, and an error when the consumer must stop.
+func (c *Consumer) handle(ctx context.Context, r *Record) error {
+	return c.retryHandler(ctx, []*Record{r}, func() error {
+		return c.handler(ctx, r)
+	})
+}
+
+// retryHandler calls handle, which handles the records, following the error
+// handler or else the retry policy; the failure actions apply to all the
+// records.
+func (c *Consumer) retryHandler(ctx context.Context, records []*Record, handle func() error) error {
+	backoff := c.retry.Backoff
+	if backoff.Initial <= 0 {
+		backoff = DefaultBackoff
+	}
+	r := records[0]
+
+	var d time.Duration
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := handle()
+		c.metrics.Observe(MetricHandlerLatency, time.Since(start).Seconds(), "stream", c.streamName)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		action := FailureRetry
+		if c.onError != nil {
+			action = c.onError(r, err, attempt)
+		} else if attempt >= c.retry.MaxAttempts {
+			action = c.retry.OnFailure
+		}
+
+		switch action {
+		case FailureRetry:
+			if quarantined, err := c.quarantineRetry(ctx, records, err); quarantined || err != nil {
+				return err
+			}
+		case FailureSkip:
+			log.Printf("kine: consumer %s: skipped %s %s: %v", c.streamName, r.ShardID, r.SequenceNumber, err)
+			return nil
+		case FailureStopShard:
+			log.Printf("kine: consumer %s: stopped %s at %s: %v", c.streamName, r.ShardID, r.SequenceNumber, err)
+			return errStopShard
+		case FailureDeadLetter:
+			if c.deadLetter == nil {
+				return fmt.Errorf("%s %s: %w (no dead-letter queue)", r.ShardID, r.SequenceNumber, err)
+			}
+			for _, r := range records {
+				if err := c.deadLetter.Send(ctx, r, err); err != nil {
+					return fmt.Errorf("%s %s: dead letter: %w", r.ShardID, r.SequenceNumber, err)
+				}
+			}
+			return nil
+		default:
+			return fmt.Errorf("%s %s: %w", r.ShardID, r.SequenceNumber, err)
+		}
+
+		d = backoff.next(d)
+		if err := c.k.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package kine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Route sends the records matching Match to the stream Destination.
+type Route struct {
+	Destination string
+	Match       TailFilter
+}
+
+// Router consumes a stream and writes each record, with its partition key,
+// to the destination of the first route it matches, e.g. to split a stream
+// by tenant tier. Records matching no route go to the default destination,
+// or are dropped when there is none.
+type Router struct {
+	consumer  *Consumer
+	routes    []Route
+	fallback  string
+	producers map[string]*Producer
+
+	routed  int64
+	dropped int64
+
+	mu      sync.Mutex
+	running bool
+	err     error
+	done    chan struct{}
+}
+
+// NewRouter returns a router consuming srcStream. fallback is the
+// destination of the records matching no route, none when empty.
+func (k *Kine) NewRouter(srcStream string, routes []Route, fallback string, opts ...ConsumerOption) *Router {
+	r := &Router{
+		routes:    routes,
+		fallback:  fallback,
+		producers: make(map[string]*Producer),
+		done:      make(chan struct{}),
+	}
+	for _, route := range routes {
+		if _, ok := r.producers[route.Destination]; !ok {
+			r.producers[route.Destination] = k.NewProducer(route.Destination)
+		}
+	}
+	if _, ok := r.producers[fallback]; fallback != "" && !ok {
+		r.producers[fallback] = k.NewProducer(fallback)
+	}
+	r.consumer = k.NewConsumer(srcStream, r.route, opts...)
+	return r
+}
+
+func (r *Router) destination(rec *Record) string {
+	for _, route := range r.routes {
+		if route.Match(rec) {
+			return route.Destination
+		}
+	}
+	return r.fallback
+}
+
+func (r *Router) route(ctx context.Context, rec *Record) error {
+	dst := r.destination(rec)
+	if dst == "" {
+		atomic.AddInt64(&r.dropped, 1)
+		return nil
+	}
+	if err := r.producers[dst].Put(ctx, rec.PartitionKey, rec.Data); err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	atomic.AddInt64(&r.routed, 1)
+	return nil
+}
+
+// Routed returns the number of records written to a destination.
+func (r *Router) Routed() int64 {
+	return atomic.LoadInt64(&r.routed)
+}
+
+// Dropped returns the number of records matching no route.
+func (r *Router) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Run routes records until ctx is done, routing fails or the router is shut
+// down, and flushes the records routed before returning. A router runs only
+// once.
+func (r *Router) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return errors.New("router already ran")
+	}
+	r.running = true
+	r.mu.Unlock()
+
+	err := r.consumer.Run(ctx)
+	if cerr := r.close(); err == nil {
+		err = cerr
+	}
+
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+	close(r.done)
+	return err
+}
+
+// Shutdown stops reading the source stream and waits until the records read
+// are written, returning the error Run returned.
+func (r *Router) Shutdown(ctx context.Context) error {
+	if err := r.consumer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Router) close() error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(r.producers))
+	for dst, p := range r.producers {
+		wg.Add(1)
+		go func(dst string, p *Producer) {
+			defer wg.Done()
+			if err := p.Close(context.Background()); err != nil {
+				errs <- fmt.Errorf("%s: %w", dst, err)
+			}
+		}(dst, p)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
@@ -0,0 +1,147 @@
+package kine
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ingtk/kine/hashrange"
+)
+
+// ScaleTo changes the open shard count of the stream to shardCount using
+// uniform scaling. UpdateShardCount only accepts targets between half and
+// double of the current count, so larger changes are done in several steps.
+func (k *Kine) ScaleTo(streamName string, shardCount int) error {
+
+	if err := k.checkReshard(streamName, "scale"); err != nil {
+		return err
+	}
+	end, err := k.beginReshard(streamName, "scale", map[string]interface{}{
+		"shardCount": shardCount,
+	})
+	if err != nil {
+		return err
+	}
+	err = k.scaleTo(streamName, shardCount)
+	end(err)
+	return err
+}
+
+func (k *Kine) scaleTo(streamName string, shardCount int) error {
+
+	for step := 0; ; step++ {
+		stream, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(streamName),
+		})
+		if err != nil {
+			return err
+		}
+
+		current := int(*stream.StreamDescriptionSummary.OpenShardCount)
+		if current == shardCount {
+			return nil
+		}
+
+		target := shardCount
+		if target > current*2 {
+			target = current * 2
+		}
+		if min := (current + 1) / 2; target < min {
+			target = min
+		}
+
+		// the first step was checked by ScaleTo
+		if step > 0 {
+			if err := k.checkReshard(streamName, "scale"); err != nil {
+				return err
+			}
+		}
+
+		_, err = k.svc.UpdateShardCount(&kinesis.UpdateShardCountInput{
+			ScalingType:      aws.String(kinesis.ScalingTypeUniformScaling),
+			StreamName:       aws.String(streamName),
+			TargetShardCount: aws.Int64(int64(target)),
+		})
+		k.shardCache.invalidate(streamName)
+		if err != nil {
+			return fmt.Errorf("%s: UpdateShardCount from %d to %d: %w", streamName, current, target, err)
+		}
+
+		err = k.waitUntilSettled(streamName, fmt.Sprintf("UpdateShardCount from %d to %d", current, target))
+		if err != nil {
+			return fmt.Errorf("%s: waiting for ACTIVE after scaling to %d: %w", streamName, target, err)
+		}
+		if err := k.waitUntilShardCount(streamName, target); err != nil {
+			return err
+		}
+	}
+}
+
+// SplitShardAt splits an open shard so that the lower child covers ratio of
+// its hash key range, e.g. 0.7 for a 70/30 split, and waits until the stream
+// is ACTIVE again. A ratio of 0.5 is the even split done by DoubleShard.
+// The shard ID may be given in short form, see NormalizeShardID.
+func (k *Kine) SplitShardAt(streamName, shardID string, ratio float64) error {
+
+	shardID, err := k.ResolveShardID(streamName, shardID)
+	if err != nil {
+		return err
+	}
+
+	if err := k.checkReshard(streamName, "split"); err != nil {
+		return err
+	}
+	end, err := k.beginReshard(streamName, "split", map[string]interface{}{
+		"shard": shardID,
+		"ratio": ratio,
+	})
+	if err != nil {
+		return err
+	}
+	err = k.splitShardAt(streamName, shardID, ratio)
+	end(err)
+	return err
+}
+
+func (k *Kine) splitShardAt(streamName, shardID string, ratio float64) error {
+
+	shards, err := k.openShards(streamName, false)
+	if err != nil {
+		return err
+	}
+
+	var shard *kinesis.Shard
+	for _, s := range shards {
+		if *s.ShardId == shardID {
+			shard = s
+			break
+		}
+	}
+	if shard == nil {
+		return fmt.Errorf("open shard %s not found in stream %s", shardID, streamName)
+	}
+
+	r, err := hashrange.Parse(*shard.HashKeyRange.StartingHashKey, *shard.HashKeyRange.EndingHashKey)
+	if err != nil {
+		return err
+	}
+	newStartingHashKey, err := r.SplitPoint(ratio)
+	if err != nil {
+		return err
+	}
+
+	_, err = k.svc.SplitShard(&kinesis.SplitShardInput{
+		NewStartingHashKey: aws.String(newStartingHashKey.String()),
+		ShardToSplit:       shard.ShardId,
+		StreamName:         aws.String(streamName),
+	})
+	k.shardCache.invalidate(streamName)
+	if err != nil {
+		return fmt.Errorf("%s: SplitShard %s: %w", streamName, shardID, err)
+	}
+
+	if err := k.waitUntilSettled(streamName, fmt.Sprintf("SplitShard %s", shardID), shardID); err != nil {
+		return fmt.Errorf("%s: waiting for ACTIVE after splitting %s: %w", streamName, shardID, err)
+	}
+	return k.waitUntilReplaced(streamName, shardID)
+}
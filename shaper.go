@@ -0,0 +1,140 @@
+package kine
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// shaperRefreshInterval is how often the producer re-reads the open shards.
+const shaperRefreshInterval = time.Minute
+
+// tokenBucket allows rate units per second with bursts of one second.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, last: now}
+}
+
+// take takes n tokens, returning how long to wait until they are available.
+func (b *tokenBucket) take(n float64, now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type shardLimiter struct {
+	bytes   *tokenBucket
+	records *tokenBucket
+}
+
+// shardMap caches the open shards of a stream for the producer, re-reading
+// them every minute to follow resharding.
+type shardMap struct {
+	k          *Kine
+	streamName string
+
+	mu        sync.Mutex
+	shards    []ShardRange
+	refreshed time.Time
+}
+
+// get returns the open shards sorted by hash key. When they cannot be read,
+// the known shards are returned and the read is retried at the next interval.
+func (m *shardMap) get() []ShardRange {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.k.clock.Now()
+	if now.Sub(m.refreshed) < shaperRefreshInterval {
+		return m.shards
+	}
+	m.refreshed = now
+
+	t, err := m.k.Topology(m.streamName)
+	if err != nil {
+		log.Printf("kine: producer %s: %v", m.streamName, err)
+		return m.shards
+	}
+	m.shards = t.Shards
+	return m.shards
+}
+
+// find returns the shard of the hash key.
+func (m *shardMap) find(key hashrange.Key) (ShardRange, bool) {
+	shards := m.get()
+	i := sort.Search(len(shards), func(i int) bool {
+		return shards[i].Range.End.Cmp(key) >= 0
+	})
+	if i == len(shards) {
+		return ShardRange{}, false
+	}
+	return shards[i], true
+}
+
+// shaper throttles the producer to the write limits of each shard.
+type shaper struct {
+	shards *shardMap
+
+	mu       sync.Mutex
+	limiters map[string]*shardLimiter
+}
+
+func newShaper(shards *shardMap) *shaper {
+	return &shaper{
+		shards:   shards,
+		limiters: make(map[string]*shardLimiter),
+	}
+}
+
+// delay returns how long to wait before sending the record to stay under
+// the limits of its shard.
+func (s *shaper) delay(r *pendingRecord) time.Duration {
+	shard, ok := s.shards.find(r.hashKey())
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.shards.k.clock.Now()
+	l, ok := s.limiters[shard.ShardID]
+	if !ok {
+		l = &shardLimiter{
+			bytes:   newTokenBucket(shardWriteBytesPerSecond, now),
+			records: newTokenBucket(shardWriteRecordsPerSecond, now),
+		}
+		s.limiters[shard.ShardID] = l
+	}
+
+	d := l.bytes.take(float64(r.size()), now)
+	if rd := l.records.take(1, now); rd > d {
+		d = rd
+	}
+	return d
+}
+
+func (r *pendingRecord) hashKey() hashrange.Key {
+	if r.explicitHashKey != "" {
+		if key, err := hashrange.ParseKey(r.explicitHashKey); err == nil {
+			return key
+		}
+	}
+	return HashKeyOf(r.partitionKey)
+}
@@ -0,0 +1,137 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const shardIDPrefix = "shardId-"
+
+// NormalizeShardID returns the full form of a shard ID, accepting the short
+// forms "1" and "000000000001" as well as "shardId-000000000001".
+func NormalizeShardID(s string) (string, error) {
+	digits := strings.TrimSpace(s)
+	if len(digits) >= len(shardIDPrefix) && strings.EqualFold(digits[:len(shardIDPrefix)], shardIDPrefix) {
+		digits = digits[len(shardIDPrefix):]
+	}
+	if digits == "" || len(digits) > 12 || strings.Trim(digits, "0123456789") != "" {
+		return "", fmt.Errorf("invalid shard ID %q", s)
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid shard ID %q: %w", s, err)
+	}
+	return fmt.Sprintf("%s%012d", shardIDPrefix, n), nil
+}
+
+// shardNumber is the number of a normalized shard ID.
+func shardNumber(shardID string) string {
+	n := strings.TrimLeft(strings.TrimPrefix(shardID, shardIDPrefix), "0")
+	if n == "" {
+		return "0"
+	}
+	return n
+}
+
+// UnknownShardError is returned for a shard ID not found in the stream,
+// with the IDs of the shards it may be a typo of.
+type UnknownShardError struct {
+	StreamName  string
+	ShardID     string
+	Suggestions []string
+}
+
+func (e *UnknownShardError) Error() string {
+	msg := fmt.Sprintf("%s: no shard %s", e.StreamName, e.ShardID)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf("; did you mean %s?", strings.Join(e.Suggestions, " or "))
+	}
+	return msg
+}
+
+// maxShardSuggestions is how many shard IDs an UnknownShardError suggests.
+const maxShardSuggestions = 3
+
+// ResolveShardID normalizes the shard ID and checks that the stream has the
+// shard, open or closed, returning an *UnknownShardError otherwise.
+func (k *Kine) ResolveShardID(streamName, shardID string) (string, error) {
+	id, err := NormalizeShardID(shardID)
+	if err != nil {
+		return "", err
+	}
+
+	var ids []string
+	err = k.listShards(streamName, func(page []*kinesis.Shard) error {
+		for _, s := range page {
+			ids = append(ids, *s.ShardId)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, s := range ids {
+		if s == id {
+			return id, nil
+		}
+	}
+
+	// suggest the shards whose numbers are one or two typos away
+	typed := shardNumber(id)
+	type candidate struct {
+		id       string
+		distance int
+	}
+	var candidates []candidate
+	for _, s := range ids {
+		if d := editDistance(typed, shardNumber(s)); d <= 2 {
+			candidates = append(candidates, candidate{id: s, distance: d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].id < candidates[j].id
+	})
+	e := &UnknownShardError{StreamName: streamName, ShardID: id}
+	for i := 0; i < len(candidates) && i < maxShardSuggestions; i++ {
+		e.Suggestions = append(e.Suggestions, candidates[i].id)
+	}
+	return "", e
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,223 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// listShardsPageSize is the largest page ListShards returns.
+const listShardsPageSize = 1000
+
+// defaultShardCacheTTL is how long the open shards of a stream are reused,
+// e.g. between the calls HalveShard and View make one after the other.
+const defaultShardCacheTTL = 5 * time.Second
+
+// WithShardCacheTTL sets how long the open shards of a stream are cached.
+// kine drops the cache of a stream it reshards itself; a ttl of 0 disables
+// the cache, e.g. when other processes reshard the same streams.
+func WithShardCacheTTL(ttl time.Duration) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.shardCache.ttl = ttl
+		return nil
+	})
+}
+
+type shardCacheEntry struct {
+	shards []*kinesis.Shard
+	listed time.Time
+}
+
+type shardCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	streams map[string]shardCacheEntry
+}
+
+func newShardCache() *shardCache {
+	return &shardCache{
+		ttl:     defaultShardCacheTTL,
+		streams: make(map[string]shardCacheEntry),
+	}
+}
+
+func (c *shardCache) get(streamName string, now time.Time) ([]*kinesis.Shard, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.streams[streamName]
+	if !ok || now.Sub(e.listed) >= c.ttl {
+		return nil, false
+	}
+	// callers sort the shards in place
+	shards := make([]*kinesis.Shard, len(e.shards))
+	copy(shards, e.shards)
+	return shards, true
+}
+
+func (c *shardCache) set(streamName string, shards []*kinesis.Shard, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := make([]*kinesis.Shard, len(shards))
+	copy(cached, shards)
+	c.streams[streamName] = shardCacheEntry{shards: cached, listed: now}
+}
+
+// invalidate drops the cached shards of the stream after kine changed them.
+func (c *shardCache) invalidate(streamName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.streams, streamName)
+}
+
+// activeSummary waits until the stream is ACTIVE, so that its shards are not
+// listed in the middle of a resharding, and returns its summary.
+func (k *Kine) activeSummary(streamName string) (*kinesis.StreamDescriptionSummary, error) {
+	for {
+		out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(streamName),
+		})
+		if err != nil {
+			return nil, err
+		}
+		summary := out.StreamDescriptionSummary
+		if *summary.StreamStatus == kinesis.StreamStatusActive {
+			return summary, nil
+		}
+
+		if err := k.waitUntilActive(streamName); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// listShards calls fn with every page of shards of the stream, open and
+// closed, so that callers only keep the shards they need.
+func (k *Kine) listShards(streamName string, fn func(page []*kinesis.Shard) error) error {
+	in := &kinesis.ListShardsInput{
+		MaxResults: aws.Int64(listShardsPageSize),
+		StreamName: aws.String(streamName),
+	}
+	for {
+		out, err := k.svc.ListShards(in)
+		if err != nil {
+			return err
+		}
+		if err := fn(out.Shards); err != nil {
+			return err
+		}
+		if out.NextToken == nil {
+			return nil
+		}
+
+		// the stream name must not be set along with a token
+		in = &kinesis.ListShardsInput{
+			MaxResults: aws.Int64(listShardsPageSize),
+			NextToken:  out.NextToken,
+		}
+	}
+}
+
+// openShards returns the open shards of the ACTIVE stream, without keeping
+// the closed ones in memory. They are in hash key order when sorted is set.
+func (k *Kine) openShards(streamName string, sorted bool) ([]*kinesis.Shard, error) {
+	if shards, ok := k.shardCache.get(streamName, k.clock.Now()); ok {
+		return filterOpenShards(shards, sorted), nil
+	}
+
+	summary, err := k.activeSummary(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := make([]*kinesis.Shard, 0, int(aws.Int64Value(summary.OpenShardCount)))
+	err = k.listShards(streamName, func(page []*kinesis.Shard) error {
+		shards = append(shards, filterOpenShards(page, false)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.shardCache.set(streamName, shards, k.clock.Now())
+	return filterOpenShards(shards, sorted), nil
+}
+
+// Listing shards right after a resharding can briefly return the shards from
+// before it, which would confuse the next step of a plan.
+const reshardConsistencyTimeout = time.Minute
+
+var reshardConsistencyBackoff = Backoff{
+	Initial:    200 * time.Millisecond,
+	Max:        2 * time.Second,
+	Multiplier: 2,
+}
+
+// waitForShards lists the open shards until done accepts them, and caches
+// them for the next step.
+func (k *Kine) waitForShards(streamName string, what string, done func(open []*kinesis.Shard) bool) error {
+	deadline := k.clock.Now().Add(reshardConsistencyTimeout)
+	var ok bool
+	var open []*kinesis.Shard
+	err := k.poll(context.Background(), []WaitOption{WithBackoff(reshardConsistencyBackoff)}, func() (bool, error) {
+		open = open[:0]
+		err := k.listShards(streamName, func(page []*kinesis.Shard) error {
+			open = append(open, filterOpenShards(page, false)...)
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		ok = done(open)
+		return ok || !k.clock.Now().Before(deadline), nil
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s: %s not visible after %s", streamName, what, reshardConsistencyTimeout)
+	}
+	k.shardCache.set(streamName, open, k.clock.Now())
+	return nil
+}
+
+// waitUntilReplaced waits until the parents are closed and each has an open
+// child, after SplitShard or MergeShards.
+func (k *Kine) waitUntilReplaced(streamName string, parents ...string) error {
+	return k.waitForShards(streamName, fmt.Sprintf("children of %s", strings.Join(parents, " and ")), func(open []*kinesis.Shard) bool {
+		replaced := make(map[string]bool, len(parents))
+		for _, s := range open {
+			for _, p := range parents {
+				if *s.ShardId == p {
+					return false
+				}
+			}
+			if s.ParentShardId != nil {
+				replaced[*s.ParentShardId] = true
+			}
+			if s.AdjacentParentShardId != nil {
+				replaced[*s.AdjacentParentShardId] = true
+			}
+		}
+		for _, p := range parents {
+			if !replaced[p] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// waitUntilShardCount waits until n shards are open, after UpdateShardCount.
+func (k *Kine) waitUntilShardCount(streamName string, n int) error {
+	return k.waitForShards(streamName, fmt.Sprintf("%d open shards", n), func(open []*kinesis.Shard) bool {
+		return len(open) == n
+	})
+}
@@ -0,0 +1,133 @@
+package kine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ingtk/kine/hashrange"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Simulate returns the topology resulting from applying the plan to t,
+// without touching the stream. Shards created by the plan have no ID.
+func Simulate(t *Topology, plan *Plan) (*Topology, error) {
+	shards := make([]ShardRange, len(t.Shards))
+	copy(shards, t.Shards)
+	find := func(r hashrange.Range) (int, error) {
+		for i, s := range shards {
+			if s.Range == r {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no open shard covers %s", r)
+	}
+
+	for i, step := range plan.Steps {
+		at, err := find(step.Range)
+		if err != nil {
+			return nil, fmt.Errorf("step %d of %d (%s): %w", i+1, len(plan.Steps), step, err)
+		}
+		switch step.Action {
+		case ActionSplit:
+			if !step.Range.Contains(step.At) || step.At == step.Range.Start {
+				return nil, fmt.Errorf("step %d of %d (%s): split point outside of the shard", i+1, len(plan.Steps), step)
+			}
+			lower := ShardRange{Range: hashrange.Range{Start: step.Range.Start, End: step.At.Prev()}}
+			upper := ShardRange{Range: hashrange.Range{Start: step.At, End: step.Range.End}}
+			shards = append(shards[:at], append([]ShardRange{lower, upper}, shards[at+1:]...)...)
+		case ActionMerge:
+			adjacent, err := find(step.Adjacent)
+			if err != nil {
+				return nil, fmt.Errorf("step %d of %d (%s): %w", i+1, len(plan.Steps), step, err)
+			}
+			merged, err := step.Range.Merge(step.Adjacent)
+			if err != nil {
+				return nil, fmt.Errorf("step %d of %d (%s): %w", i+1, len(plan.Steps), step, err)
+			}
+			shards[at] = ShardRange{Range: merged}
+			shards = append(shards[:adjacent], shards[adjacent+1:]...)
+		default:
+			return nil, fmt.Errorf("step %d of %d: unknown action %q", i+1, len(plan.Steps), step.Action)
+		}
+	}
+
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].Range.Start.Cmp(shards[j].Range.Start) < 0
+	})
+	return &Topology{StreamName: t.StreamName, Shards: shards}, nil
+}
+
+// ShardLoad is the predicted traffic of a shard.
+type ShardLoad struct {
+	Shard ShardRange
+	// Weight is the sum of the weights of the heat map in the shard, in
+	// the unit of the heat map, e.g. records per second.
+	Weight float64
+	// Share is the fraction of the total weight.
+	Share float64
+}
+
+// LoadPrediction is the traffic of a heat map spread over a topology.
+type LoadPrediction struct {
+	StreamName string
+	Shards     []ShardLoad
+	Total      float64
+	// Max is the largest Weight of a shard.
+	Max float64
+}
+
+// PredictLoad replays the heat map, e.g. sampled from the live stream,
+// against the topology, typically one returned by Simulate, to compare
+// candidate plans before applying one.
+func PredictLoad(t *Topology, heat HeatMap) *LoadPrediction {
+	p := &LoadPrediction{
+		StreamName: t.StreamName,
+		Shards:     make([]ShardLoad, len(t.Shards)),
+	}
+	for i, s := range t.Shards {
+		p.Shards[i].Shard = s
+	}
+	sort.Slice(p.Shards, func(i, j int) bool {
+		return p.Shards[i].Shard.Range.Start.Cmp(p.Shards[j].Shard.Range.Start) < 0
+	})
+
+	for _, h := range heat {
+		i := sort.Search(len(p.Shards), func(i int) bool {
+			return p.Shards[i].Shard.Range.End.Cmp(h.HashKey) >= 0
+		})
+		if i == len(p.Shards) || !p.Shards[i].Shard.Range.Contains(h.HashKey) {
+			// a gap in the topology
+			continue
+		}
+		p.Shards[i].Weight += h.Weight
+		p.Total += h.Weight
+	}
+	for i := range p.Shards {
+		if p.Total > 0 {
+			p.Shards[i].Share = p.Shards[i].Weight / p.Total
+		}
+		if p.Shards[i].Weight > p.Max {
+			p.Max = p.Shards[i].Weight
+		}
+	}
+	return p
+}
+
+// Render writes the prediction as a table, one row per shard.
+func (p *LoadPrediction) Render(w io.Writer) {
+	even := 1 / float64(len(p.Shards))
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"shard", "hash space", "load", "share", "vs even"})
+	for _, s := range p.Shards {
+		table.Append([]string{
+			s.Shard.String(),
+			fmt.Sprintf("%.2f %%", s.Shard.Range.Share()*100),
+			fmt.Sprintf("%.2f", s.Weight),
+			fmt.Sprintf("%.2f %%", s.Share*100),
+			fmt.Sprintf("%+.0f %%", (s.Share/even-1)*100),
+		})
+	}
+	table.SetFooter([]string{fmt.Sprintf("%d shards", len(p.Shards)), "", fmt.Sprintf("%.2f", p.Total), "", ""})
+	table.Render()
+}
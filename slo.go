@@ -0,0 +1,134 @@
+package kine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+const (
+	defaultSLOWindow = time.Hour
+	// defaultMaxBurnRate spends 2% of a 30-day error budget in an hour,
+	// the usual threshold to page on.
+	defaultMaxBurnRate = 14.4
+
+	iteratorAgeBurnCondition = "iterator age SLO burn rate"
+)
+
+// IteratorAgeSLO is the objective that the iterator age of the consumers of
+// a stream stays at or below Target for an Objective share of the minutes,
+// e.g. 30s for 99% of the minutes.
+type IteratorAgeSLO struct {
+	// ConsumerName is the enhanced fan-out consumer, or empty for the
+	// polling consumers, as with ConsumerLag.
+	ConsumerName string
+	Target       time.Duration
+	Objective    float64
+	// Window is how far back the burn rate is measured, one hour by
+	// default.
+	Window time.Duration
+	// MaxBurnRate is the burn rate from which the SLO is at risk, 14.4 by
+	// default.
+	MaxBurnRate float64
+}
+
+func (s IteratorAgeSLO) withDefaults() (IteratorAgeSLO, error) {
+	if s.Target <= 0 {
+		return s, fmt.Errorf("invalid iterator age target %s", s.Target)
+	}
+	if s.Objective <= 0 || s.Objective >= 1 {
+		return s, fmt.Errorf("invalid objective %v", s.Objective)
+	}
+	if s.Window == 0 {
+		s.Window = defaultSLOWindow
+	}
+	if s.MaxBurnRate == 0 {
+		s.MaxBurnRate = defaultMaxBurnRate
+	}
+	return s, nil
+}
+
+// SLOBurn is how fast a stream spends the error budget of its SLO.
+type SLOBurn struct {
+	StreamName string
+	// Minutes is the number of minutes of the window with a datapoint,
+	// BadMinutes those above the target.
+	Minutes    int
+	BadMinutes int
+	// BurnRate is the share of bad minutes over the share the objective
+	// allows: 1 spends the budget exactly by the end of the SLO period.
+	BurnRate float64
+}
+
+// IteratorAgeBurn measures the burn rate of the SLO over its window from
+// the one-minute maximum of the iterator age. Minutes without consumer
+// activity have no datapoint and are not counted.
+func (k *Kine) IteratorAgeBurn(streamName string, slo IteratorAgeSLO) (*SLOBurn, error) {
+	slo, err := slo.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	metricName := "GetRecords.IteratorAgeMilliseconds"
+	dims := map[string]string{"StreamName": streamName}
+	if slo.ConsumerName != "" {
+		metricName = "SubscribeToShardEvent.MillisBehindLatest"
+		dims["ConsumerName"] = slo.ConsumerName
+	}
+	datapoints, err := k.getMetric(metricName, dims, cloudwatch.StatisticMaximum, time.Minute, slo.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &SLOBurn{StreamName: streamName, Minutes: len(datapoints)}
+	for _, d := range datapoints {
+		if time.Duration(*d.Maximum)*time.Millisecond > slo.Target {
+			b.BadMinutes++
+		}
+	}
+	if b.Minutes > 0 {
+		b.BurnRate = float64(b.BadMinutes) / float64(b.Minutes) / (1 - slo.Objective)
+	}
+	return b, nil
+}
+
+// IteratorAgeBurnAbove alerts when the burn rate of the SLO reaches its
+// MaxBurnRate, i.e. when the SLO is at risk.
+func IteratorAgeBurnAbove(slo IteratorAgeSLO) Condition {
+	return ConditionFn(func(k *Kine, streamName string) (*Alert, error) {
+		slo, err := slo.withDefaults()
+		if err != nil {
+			return nil, err
+		}
+		b, err := k.IteratorAgeBurn(streamName, slo)
+		if err != nil {
+			return nil, err
+		}
+		if b.BurnRate < slo.MaxBurnRate {
+			return nil, nil
+		}
+		return &Alert{
+			StreamName: streamName,
+			Condition:  iteratorAgeBurnCondition,
+			Value:      b.BurnRate,
+			Threshold:  slo.MaxBurnRate,
+		}, nil
+	})
+}
+
+// ScaleUpOnBurn passes the alerts on to next, and scales the stream of the
+// autoscaler up with ScaleUp on the alerts of IteratorAgeBurnAbove for it,
+// so that consumers falling behind get more shards to read in parallel.
+func ScaleUpOnBurn(a *Autoscaler, next Alerter) Alerter {
+	return AlerterFunc(func(alert *Alert) error {
+		if err := next.Alert(alert); err != nil {
+			return err
+		}
+		if alert.Condition != iteratorAgeBurnCondition || alert.StreamName != a.streamName {
+			return nil
+		}
+		_, err := a.ScaleUp(alert.String())
+		return err
+	})
+}
@@ -0,0 +1,253 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const defaultRetentionHours = 24
+
+// StreamSpec declares the desired configuration of a stream.
+type StreamSpec struct {
+	Name       string `json:"name" yaml:"name"`
+	ShardCount int    `json:"shardCount" yaml:"shardCount"`
+
+	// RetentionHours defaults to 24 hours when zero.
+	RetentionHours int `json:"retentionHours,omitempty" yaml:"retentionHours,omitempty"`
+
+	// KMSKeyID enables server-side encryption with the key when set,
+	// and disables encryption when empty.
+	KMSKeyID string `json:"kmsKeyId,omitempty" yaml:"kmsKeyId,omitempty"`
+
+	// Tags are the complete set of tags; other tags are removed.
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+func (s *StreamSpec) retentionHours() int {
+	if s.RetentionHours == 0 {
+		return defaultRetentionHours
+	}
+	return s.RetentionHours
+}
+
+// Change is a difference between a StreamSpec and the actual stream.
+type Change struct {
+	StreamName string `json:"stream"`
+	Field      string `json:"field"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+
+	apply func(k *Kine) error
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s %q -> %q", c.StreamName, c.Field, c.From, c.To)
+}
+
+// DiffStream returns the changes needed to make the stream match the spec,
+// without applying them. A missing stream is a single "stream" change.
+func (k *Kine) DiffStream(spec StreamSpec) ([]Change, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(spec.Name),
+	})
+	if isNotFound(err) {
+		return []Change{{
+			StreamName: spec.Name,
+			Field:      "stream",
+			From:       "",
+			To:         fmt.Sprintf("created with %d shard(s)", spec.ShardCount),
+			apply: func(k *Kine) error {
+				_, err := k.svc.CreateStream(&kinesis.CreateStreamInput{
+					ShardCount: aws.Int64(int64(spec.ShardCount)),
+					StreamName: aws.String(spec.Name),
+				})
+				k.shardCache.invalidate(spec.Name)
+				if err != nil {
+					return err
+				}
+				return k.waitUntilActive(spec.Name)
+			},
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary := out.StreamDescriptionSummary
+
+	tags, err := k.listTags(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffStream(spec, summary, tags), nil
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == kinesis.ErrCodeResourceNotFoundException
+}
+
+func diffStream(spec StreamSpec, summary *kinesis.StreamDescriptionSummary, tags map[string]string) []Change {
+	var changes []Change
+	name := spec.Name
+
+	if open := int(*summary.OpenShardCount); open != spec.ShardCount {
+		changes = append(changes, Change{
+			StreamName: name,
+			Field:      "shard count",
+			From:       strconv.Itoa(open),
+			To:         strconv.Itoa(spec.ShardCount),
+			apply: func(k *Kine) error {
+				return k.ScaleTo(name, spec.ShardCount)
+			},
+		})
+	}
+
+	if current, desired := int(*summary.RetentionPeriodHours), spec.retentionHours(); current != desired {
+		changes = append(changes, Change{
+			StreamName: name,
+			Field:      "retention hours",
+			From:       strconv.Itoa(current),
+			To:         strconv.Itoa(desired),
+			apply: func(k *Kine) error {
+				var err error
+				if desired > current {
+					_, err = k.svc.IncreaseStreamRetentionPeriod(&kinesis.IncreaseStreamRetentionPeriodInput{
+						RetentionPeriodHours: aws.Int64(int64(desired)),
+						StreamName:           aws.String(name),
+					})
+				} else {
+					_, err = k.svc.DecreaseStreamRetentionPeriod(&kinesis.DecreaseStreamRetentionPeriodInput{
+						RetentionPeriodHours: aws.Int64(int64(desired)),
+						StreamName:           aws.String(name),
+					})
+				}
+				if err != nil {
+					return err
+				}
+				return k.waitUntilActive(name)
+			},
+		})
+	}
+
+	currentKey := ""
+	if aws.StringValue(summary.EncryptionType) == kinesis.EncryptionTypeKms {
+		currentKey = aws.StringValue(summary.KeyId)
+	}
+	if currentKey != spec.KMSKeyID {
+		changes = append(changes, Change{
+			StreamName: name,
+			Field:      "encryption key",
+			From:       currentKey,
+			To:         spec.KMSKeyID,
+			apply: func(k *Kine) error {
+				var err error
+				if spec.KMSKeyID == "" {
+					_, err = k.svc.StopStreamEncryption(&kinesis.StopStreamEncryptionInput{
+						EncryptionType: aws.String(kinesis.EncryptionTypeKms),
+						KeyId:          aws.String(currentKey),
+						StreamName:     aws.String(name),
+					})
+				} else {
+					_, err = k.svc.StartStreamEncryption(&kinesis.StartStreamEncryptionInput{
+						EncryptionType: aws.String(kinesis.EncryptionTypeKms),
+						KeyId:          aws.String(spec.KMSKeyID),
+						StreamName:     aws.String(name),
+					})
+				}
+				if err != nil {
+					return err
+				}
+				return k.waitUntilActive(name)
+			},
+		})
+	}
+
+	keys := make([]string, 0, len(tags)+len(spec.Tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	for key := range spec.Tags {
+		if _, ok := tags[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		key := key
+		current, hasCurrent := tags[key]
+		desired, hasDesired := spec.Tags[key]
+
+		switch {
+		case !hasDesired:
+			changes = append(changes, Change{
+				StreamName: name,
+				Field:      "tag " + key,
+				From:       current,
+				To:         "",
+				apply: func(k *Kine) error {
+					_, err := k.svc.RemoveTagsFromStream(&kinesis.RemoveTagsFromStreamInput{
+						StreamName: aws.String(name),
+						TagKeys:    []*string{aws.String(key)},
+					})
+					return err
+				},
+			})
+		case !hasCurrent || current != desired:
+			changes = append(changes, Change{
+				StreamName: name,
+				Field:      "tag " + key,
+				From:       current,
+				To:         desired,
+				apply: func(k *Kine) error {
+					_, err := k.svc.AddTagsToStream(&kinesis.AddTagsToStreamInput{
+						StreamName: aws.String(name),
+						Tags:       map[string]*string{key: aws.String(desired)},
+					})
+					return err
+				},
+			})
+		}
+	}
+
+	return changes
+}
+
+// EnsureStream creates the stream if it does not exist, and converges its
+// shard count, retention, encryption and tags to the spec.
+// It returns the changes made.
+func (k *Kine) EnsureStream(spec StreamSpec) ([]Change, error) {
+	if spec.ShardCount < 1 {
+		return nil, fmt.Errorf("%s: invalid shard count %d", spec.Name, spec.ShardCount)
+	}
+
+	var made []Change
+	for {
+		changes, err := k.DiffStream(spec)
+		if err != nil {
+			return made, err
+		}
+		if len(changes) == 0 {
+			return made, nil
+		}
+
+		for _, c := range changes {
+			if err := c.apply(k); err != nil {
+				return made, fmt.Errorf("%s: %w", c, err)
+			}
+			made = append(made, c)
+		}
+
+		// a created stream still needs its other settings to be converged
+		if changes[0].Field != "stream" {
+			return made, nil
+		}
+	}
+}
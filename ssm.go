@@ -0,0 +1,147 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// SSMSpecSource reads stream specs from the SSM Parameter Store, so that
+// operators can change desired shard counts without redeploying the service
+// reconciling them. Every stream has the parameters
+//
+//	<path>/<stream>/shardCount
+//	<path>/<stream>/retentionHours
+//	<path>/<stream>/kmsKeyId
+//	<path>/<stream>/tags/<key>
+//
+// of which only shardCount is required.
+type SSMSpecSource struct {
+	svc  *ssm.SSM
+	path string
+}
+
+func (k *Kine) NewSSMSpecSource(path string) *SSMSpecSource {
+	return &SSMSpecSource{
+		svc:  ssm.New(k.session, k.serviceConfig(ssm.EndpointsID)),
+		path: strings.TrimSuffix(path, "/"),
+	}
+}
+
+// Load reads the specs, sorted by stream name.
+func (s *SSMSpecSource) Load() ([]StreamSpec, error) {
+	specs, _, err := s.load()
+	return specs, err
+}
+
+// Watch polls the parameters every interval and calls fn with the specs
+// when they were first read and whenever a parameter was added, changed or
+// removed since, until ctx is done. A failing read is logged and retried
+// on the next tick.
+//
+// To converge the streams on every change:
+//
+//	src.Watch(ctx, time.Minute, func(specs []kine.StreamSpec) {
+//		if _, err := k.Reconcile(specs); err != nil {
+//			log.Print(err)
+//		}
+//	})
+func (s *SSMSpecSource) Watch(ctx context.Context, interval time.Duration, fn func(specs []StreamSpec)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		specs, version, err := s.load()
+		if err != nil {
+			log.Printf("kine: ssm %s: %v", s.path, err)
+		} else if version != last {
+			last = version
+			fn(specs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// load returns the specs and a string identifying the versions of the
+// parameters they were read from.
+func (s *SSMSpecSource) load() ([]StreamSpec, string, error) {
+	var params []*ssm.Parameter
+	err := s.svc.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+		Path:           aws.String(s.path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}, func(out *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		params = append(params, out.Parameters...)
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		return aws.StringValue(params[i].Name) < aws.StringValue(params[j].Name)
+	})
+
+	byName := make(map[string]*StreamSpec)
+	var versions []string
+	for _, p := range params {
+		name := aws.StringValue(p.Name)
+		value := aws.StringValue(p.Value)
+		versions = append(versions, fmt.Sprintf("%s@%d", name, aws.Int64Value(p.Version)))
+
+		parts := strings.SplitN(strings.TrimPrefix(name, s.path+"/"), "/", 2)
+		if len(parts) != 2 {
+			return nil, "", fmt.Errorf("%s: expected %s/<stream>/<field>", name, s.path)
+		}
+		spec, ok := byName[parts[0]]
+		if !ok {
+			spec = &StreamSpec{Name: parts[0]}
+			byName[parts[0]] = spec
+		}
+
+		field := parts[1]
+		switch {
+		case field == "shardCount":
+			spec.ShardCount, err = strconv.Atoi(value)
+		case field == "retentionHours":
+			spec.RetentionHours, err = strconv.Atoi(value)
+		case field == "kmsKeyId":
+			spec.KMSKeyID = value
+		case strings.HasPrefix(field, "tags/"):
+			if spec.Tags == nil {
+				spec.Tags = make(map[string]string)
+			}
+			spec.Tags[strings.TrimPrefix(field, "tags/")] = value
+		default:
+			return nil, "", fmt.Errorf("%s: unknown field %s", name, field)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	specs := make([]StreamSpec, 0, len(byName))
+	for _, spec := range byName {
+		if spec.ShardCount < 1 {
+			return nil, "", fmt.Errorf("%s/%s: missing shardCount", s.path, spec.Name)
+		}
+		specs = append(specs, *spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs, strings.Join(versions, ","), nil
+}
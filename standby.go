@@ -0,0 +1,190 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// warmIteratorAge is how long a warm iterator is used, under the 5 minutes
+// after which Kinesis expires it.
+const warmIteratorAge = 4 * time.Minute
+
+// WithStandby makes a leased consumer a warm standby for another fleet of
+// workers sharing the lease table, the primary fleet. A standby holds no
+// leases: it polls the lease table every second, and every TTL/3 refreshes
+// the shards of the stream, their checkpoints and iterators after them, so
+// that it reads them right away on takeover. When no lease was renewed for
+// timeout, TTL/2 by default, the primary fleet is deemed dead and the
+// standby takes its leases over, without waiting for them to expire, then
+// runs as a regular worker. The primary renews its leases every TTL/3, so
+// timeout must be between TTL/3 and TTL.
+func WithStandby(timeout time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.standby = &standby{
+			timeout: timeout,
+			warm:    make(map[string]warmShard),
+		}
+	}
+}
+
+type standby struct {
+	timeout time.Duration
+
+	mu   sync.Mutex
+	warm map[string]warmShard
+}
+
+// warmShard is a shard opened by a standby at its checkpoint.
+type warmShard struct {
+	checkpoint string
+	iterator   *string
+	opened     time.Time
+}
+
+// validate sets the default timeout and checks that the standby notices
+// the primary fleet is dead before its leases expire, but not while it
+// renews them.
+func (s *standby) validate(ttl time.Duration) error {
+	if s.timeout == 0 {
+		s.timeout = ttl / 2
+	}
+	if s.timeout <= ttl/3 || s.timeout >= ttl {
+		return fmt.Errorf("standby timeout %v is not between TTL/3 and the lease TTL %v", s.timeout, ttl)
+	}
+	return nil
+}
+
+// take returns the shard opened by the standby, if any, once.
+func (s *standby) take(shardID string) (warmShard, bool) {
+	if s == nil {
+		return warmShard{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.warm[shardID]
+	delete(s.warm, shardID)
+	return w, ok
+}
+
+// standBy waits until the primary fleet stops renewing its leases, and
+// returns the leases to take over, or nil when ctx is done or the consumer
+// is shut down first.
+func (c *Consumer) standBy(ctx context.Context, state *leaseState) ([]lease, error) {
+	t := c.leases
+	timeout := c.standby.timeout
+
+	// the time each lease is seen renewed, by its expiry
+	expires := make(map[string]time.Time)
+	lastRenewal := c.k.clock.Now()
+	var lastRefresh time.Time
+
+	for {
+		now := c.k.clock.Now()
+		if now.Sub(lastRefresh) >= t.TTL/3 {
+			if err := c.warmUp(ctx, state); err != nil {
+				log.Printf("kine: standby of %s: %v", c.streamName, err)
+			}
+			lastRefresh = now
+		}
+
+		leases, err := t.list(ctx)
+		if err != nil {
+			log.Printf("kine: standby of %s: %v", c.streamName, err)
+		}
+		var held []lease
+		for _, l := range leases {
+			if l.owner == "" {
+				continue
+			}
+			held = append(held, l)
+			if !l.expires.Equal(expires[l.shardID]) {
+				expires[l.shardID] = l.expires
+				lastRenewal = now
+			}
+		}
+		if err == nil && now.Sub(lastRenewal) >= timeout {
+			log.Printf("kine: standby of %s takes over: no lease renewed for %v", c.streamName, now.Sub(lastRenewal))
+			c.k.emit(EventStandbyTakeover, c.streamName, map[string]interface{}{
+				"worker": t.Worker,
+				"leases": len(held),
+			})
+			return held, nil
+		}
+
+		if err := c.wait(ctx, handoffPollInterval); err != nil || c.stopping() {
+			return nil, err
+		}
+	}
+}
+
+// warmUp refreshes the shards of the stream and opens those which can be
+// read at their checkpoints.
+func (c *Consumer) warmUp(ctx context.Context, state *leaseState) error {
+	if err := state.refresh(c.k, c.streamName); err != nil {
+		return err
+	}
+	available, err := state.available(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	warm := make(map[string]warmShard, len(available))
+	for id := range available {
+		seq, iterator, err := c.openShard(ctx, id, state.startPosition(c, id))
+		if err != nil {
+			return err
+		}
+		warm[id] = warmShard{checkpoint: seq, iterator: iterator, opened: c.k.clock.Now()}
+	}
+
+	c.standby.mu.Lock()
+	c.standby.warm = warm
+	c.standby.mu.Unlock()
+	return nil
+}
+
+// consumeWarm reads the shard with the iterator the standby opened, unless
+// the shard was checkpointed since or the iterator is about to expire.
+func (c *Consumer) consumeWarm(ctx context.Context, shardID string, from StartPosition) (bool, error) {
+	w, ok := c.standby.take(shardID)
+	if !ok || c.k.clock.Now().Sub(w.opened) >= warmIteratorAge {
+		return c.consumeShard(ctx, shardID, from)
+	}
+	seq, err := c.checkpoint.GetCheckpoint(ctx, c.streamName, shardID)
+	if err != nil {
+		return false, err
+	}
+	if seq != w.checkpoint {
+		return c.consumeShard(ctx, shardID, from)
+	}
+	if seq == ShardEnd {
+		return true, nil
+	}
+	return c.readShard(ctx, shardID, w.iterator)
+}
+
+// takeOver steals the leases of the primary fleet and starts reading their
+// shards. Leases taken by another standby worker meanwhile are skipped.
+func (c *Consumer) takeOver(ctx context.Context, state *leaseState, leases []lease, owned map[string]*leasedShard, results chan<- leaseResult) error {
+	available, err := state.available(ctx, c)
+	if err != nil {
+		return err
+	}
+	for _, l := range leases {
+		if !available[l.shardID] || state.shards[l.shardID] == nil {
+			continue
+		}
+		err := c.leases.steal(ctx, l.shardID, l.owner)
+		if err == errLeaseLost {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.startLeased(ctx, state, owned, results, l.shardID)
+	}
+	return nil
+}
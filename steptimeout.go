@@ -0,0 +1,84 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// WithStepTimeout bounds how long each SplitShard, MergeShards or
+// UpdateShardCount call of a resharding operation may keep the stream out
+// of ACTIVE. On timeout the operation stops before its next step, logs and
+// emits ReshardStuck, and returns a *StepTimeoutError naming the wedged
+// call. By default kine waits for as long as it takes.
+func WithStepTimeout(d time.Duration) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.stepTimeout = d
+		return nil
+	})
+}
+
+// StepTimeoutError is returned when a resharding call did not settle within
+// the step timeout. The call itself is not undone: the stream finishes it on
+// its own, so check the stream before resuming the operation.
+type StepTimeoutError struct {
+	StreamName string
+	// Operation is the call, e.g. "SplitShard shardId-000000000001".
+	Operation string
+	ShardIDs  []string
+	Timeout   time.Duration
+	// Status is the stream status when the timeout expired.
+	Status string
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("%s: %s still %s after %s", e.StreamName, e.Operation, e.Status, e.Timeout)
+}
+
+// waitUntilSettled waits until the stream is ACTIVE again after the
+// operation on the shards, within the step timeout if any.
+func (k *Kine) waitUntilSettled(streamName, operation string, shardIDs ...string) error {
+	if k.stepTimeout <= 0 {
+		return k.waitUntilActive(streamName)
+	}
+
+	deadline := k.clock.Now().Add(k.stepTimeout)
+	var status string
+	err := k.poll(context.Background(), nil, func() (bool, error) {
+		stream, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(streamName),
+		})
+		if err != nil {
+			return false, err
+		}
+		status = *stream.StreamDescriptionSummary.StreamStatus
+		return status == kinesis.StreamStatusActive || !k.clock.Now().Before(deadline), nil
+	})
+	if err != nil {
+		return err
+	}
+	if status == kinesis.StreamStatusActive {
+		return nil
+	}
+
+	e := &StepTimeoutError{
+		StreamName: streamName,
+		Operation:  operation,
+		ShardIDs:   shardIDs,
+		Timeout:    k.stepTimeout,
+		Status:     status,
+	}
+	log.Printf("kine: %v", e)
+	k.emit(EventReshardStuck, streamName, map[string]interface{}{
+		"operation": operation,
+		"shards":    strings.Join(shardIDs, ","),
+		"status":    status,
+		"timeout":   k.stepTimeout.String(),
+	})
+	return e
+}
@@ -0,0 +1,278 @@
+package kine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// Strategy decides how to reshard a stream. It is given the open shards and
+// their write utilization, the peak fraction of the shard write limit used
+// over the metrics window by shard ID, which is nil when the shard-level
+// metrics are not enabled. Strategies only plan; the plans are applied,
+// locked, audited and waited for like any other.
+type Strategy interface {
+	Plan(t *Topology, utilization map[string]float64) (*Plan, error)
+}
+
+// StrategyFunc adapts a function to a Strategy.
+type StrategyFunc func(t *Topology, utilization map[string]float64) (*Plan, error)
+
+func (f StrategyFunc) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	return f(t, utilization)
+}
+
+type strategyConfig struct {
+	window time.Duration
+}
+
+type StrategyOption func(c *strategyConfig)
+
+// WithMetricsWindow sets how far back the write utilization given to the
+// strategy is measured, five minutes by default.
+func WithMetricsWindow(d time.Duration) StrategyOption {
+	return func(c *strategyConfig) {
+		c.window = d
+	}
+}
+
+// PlanStrategy returns the plan of the strategy for the current topology
+// and write utilization of the stream.
+func (k *Kine) PlanStrategy(streamName string, s Strategy, opts ...StrategyOption) (*Plan, error) {
+
+	c := &strategyConfig{window: hotShardLookback}
+	for _, o := range opts {
+		o(c)
+	}
+
+	shards, err := k.openShards(streamName, true)
+	if err != nil {
+		return nil, err
+	}
+	topology, err := k.Topology(streamName)
+	if err != nil {
+		return nil, err
+	}
+	utilization, err := k.writeUtilization(streamName, shards, c.window)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Plan(topology, utilization)
+}
+
+// ApplyStrategy plans and applies the strategy, returning the plan.
+func (k *Kine) ApplyStrategy(streamName string, s Strategy, opts ...StrategyOption) (*Plan, error) {
+	plan, err := k.PlanStrategy(streamName, s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return plan, k.Apply(plan)
+}
+
+// HalveStrategy merges the open shards pairwise like HalveShard. Shards
+// with no adjacent shard are left untouched.
+type HalveStrategy struct {
+	// MergeRemainder also merges the shards left over by the pairing, as
+	// with WithRemainderMerge.
+	MergeRemainder bool
+}
+
+func (s HalveStrategy) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	plan, _ := halvePlan(t, s.MergeRemainder)
+	return plan, nil
+}
+
+// halvePlan returns the merges of HalveShard and the shards which cannot be
+// merged with any other.
+func halvePlan(t *Topology, mergeRemainder bool) (*Plan, []ShardRange) {
+	plan := &Plan{StreamName: t.StreamName}
+	if len(t.Shards) == 1 {
+		return plan, nil
+	}
+
+	pairs, remainder, isolated := pairAdjacentShards(t.Shards)
+	merged := make(map[hashrange.Key]hashrange.Range, len(pairs))
+	for _, pair := range pairs {
+		plan.Steps = append(plan.Steps, Step{Action: ActionMerge, Range: pair[0].Range, Adjacent: pair[1].Range})
+		merged[pair[1].Range.End] = hashrange.Range{Start: pair[0].Range.Start, End: pair[1].Range.End}
+	}
+
+	if mergeRemainder {
+		// a remainder always follows a merged pair
+		for _, s := range remainder {
+			below := merged[s.Range.Start.Prev()]
+			plan.Steps = append(plan.Steps, Step{Action: ActionMerge, Range: below, Adjacent: s.Range})
+		}
+	}
+	return plan, isolated
+}
+
+// DoubleStrategy splits the open shards in two halves like DoubleShard.
+type DoubleStrategy struct {
+	// Max is the number of shards split, widest first; 0 splits all.
+	Max int
+}
+
+func (s DoubleStrategy) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	n := len(t.Shards)
+	if s.Max > 0 && s.Max < n {
+		n = s.Max
+	}
+	plan, _, _, err := doublePlan(t, n)
+	return plan, err
+}
+
+// doublePlan returns the even splits of n of the shards, the widest ones
+// when not all of them, after checking that all of them can be split.
+func doublePlan(t *Topology, n int) (plan *Plan, split, skipped []ShardRange, err error) {
+	points := make(map[string]hashrange.Key, len(t.Shards))
+	for _, s := range t.Shards {
+		points[s.ShardID], err = s.Range.SplitPoint(0.5)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: shard %s: %w", t.StreamName, s.ShardID, err)
+		}
+	}
+
+	split = make([]ShardRange, len(t.Shards))
+	copy(split, t.Shards)
+	if n < len(split) {
+		// split the widest shards, which gain the most from it
+		sort.SliceStable(split, func(i, j int) bool {
+			return split[i].Range.Width().Cmp(split[j].Range.Width()) > 0
+		})
+		split, skipped = split[:n], split[n:]
+	}
+
+	plan = &Plan{StreamName: t.StreamName}
+	for _, s := range split {
+		plan.Steps = append(plan.Steps, Step{Action: ActionSplit, Range: s.Range, At: points[s.ShardID]})
+	}
+	return plan, split, skipped, nil
+}
+
+// EvenStrategy evenly tiles the hash key space like Repair.
+type EvenStrategy struct {
+	// ShardCount is the target shard count; 0 keeps the current one.
+	ShardCount int
+	// Tolerance is passed to WithTolerance.
+	Tolerance float64
+}
+
+func (s EvenStrategy) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	opts := []RepairOption{WithTolerance(s.Tolerance)}
+	if s.ShardCount > 0 {
+		opts = append(opts, WithShardCount(s.ShardCount))
+	}
+	return t.PlanRepair(opts...)
+}
+
+// ColdestPairsStrategy scales down to ShardCount by merging the adjacent
+// shards with the lowest combined write utilization first, a merged shard
+// counting as the sum of its parents, so that merging is least likely to
+// create a hot shard. It needs the shard-level metrics.
+type ColdestPairsStrategy struct {
+	ShardCount int
+}
+
+func (s ColdestPairsStrategy) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	if s.ShardCount < 1 {
+		return nil, fmt.Errorf("invalid shard count %d", s.ShardCount)
+	}
+	if utilization == nil {
+		return nil, fmt.Errorf("%s: merging the coldest shards needs the shard-level IncomingBytes and IncomingRecords metrics", t.StreamName)
+	}
+
+	type shard struct {
+		r    hashrange.Range
+		load float64
+	}
+	shards := make([]shard, len(t.Shards))
+	for i, s := range t.Shards {
+		shards[i] = shard{r: s.Range, load: utilization[s.ShardID]}
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].r.Start.Cmp(shards[j].r.Start) < 0
+	})
+
+	plan := &Plan{StreamName: t.StreamName}
+	for len(shards) > s.ShardCount {
+		coldest := -1
+		for i := 0; i+1 < len(shards); i++ {
+			if !shards[i].r.Adjacent(shards[i+1].r) {
+				continue
+			}
+			if coldest < 0 || shards[i].load+shards[i+1].load < shards[coldest].load+shards[coldest+1].load {
+				coldest = i
+			}
+		}
+		if coldest < 0 {
+			return nil, fmt.Errorf("%s: no adjacent shards left to merge at %d shards", t.StreamName, len(shards))
+		}
+
+		lower, upper := shards[coldest], shards[coldest+1]
+		plan.Steps = append(plan.Steps, Step{Action: ActionMerge, Range: lower.r, Adjacent: upper.r})
+		shards[coldest] = shard{
+			r:    hashrange.Range{Start: lower.r.Start, End: upper.r.End},
+			load: lower.load + upper.load,
+		}
+		shards = append(shards[:coldest+1], shards[coldest+2:]...)
+	}
+	return plan, nil
+}
+
+// HottestSplitStrategy scales up to at most ShardCount by splitting only
+// the shards whose write utilization reaches Threshold, hottest first, and
+// leaves the others untouched. The halves of a split shard are assumed to
+// get half of its traffic each, and are split again while still hot. It
+// needs the shard-level metrics.
+type HottestSplitStrategy struct {
+	ShardCount int
+	// Threshold is the write utilization from which a shard is split, 0.8
+	// by default.
+	Threshold float64
+}
+
+func (s HottestSplitStrategy) Plan(t *Topology, utilization map[string]float64) (*Plan, error) {
+	if utilization == nil {
+		return nil, fmt.Errorf("%s: splitting the hottest shards needs the shard-level IncomingBytes and IncomingRecords metrics", t.StreamName)
+	}
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = hotShardUtilization
+	}
+
+	type shard struct {
+		r    hashrange.Range
+		load float64
+	}
+	shards := make([]shard, len(t.Shards))
+	for i, s := range t.Shards {
+		shards[i] = shard{r: s.Range, load: utilization[s.ShardID]}
+	}
+
+	plan := &Plan{StreamName: t.StreamName}
+	for len(shards) < s.ShardCount {
+		hottest := -1
+		for i, sh := range shards {
+			if sh.load >= threshold && (hottest < 0 || sh.load > shards[hottest].load) {
+				hottest = i
+			}
+		}
+		if hottest < 0 {
+			break
+		}
+
+		sh := shards[hottest]
+		lower, upper, err := sh.r.SplitAt(0.5)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", t.StreamName, sh.r, err)
+		}
+		plan.Steps = append(plan.Steps, Step{Action: ActionSplit, Range: sh.r, At: upper.Start})
+		shards[hottest] = shard{r: lower, load: sh.load / 2}
+		shards = append(shards, shard{r: upper, load: sh.load / 2})
+	}
+	return plan, nil
+}
@@ -0,0 +1,119 @@
+package kine
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ingtk/kine/hashrange"
+)
+
+// benchmarkShards is the shard count of the topologies benchmarked.
+const benchmarkShards = 512
+
+// evenTopology returns n shards sharing the hash key space evenly.
+func evenTopology(tb testing.TB, n int) *Topology {
+	ranges, err := hashrange.Full.Divide(n)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	t := &Topology{StreamName: "bench", Shards: make([]ShardRange, 0, n)}
+	for i, r := range ranges {
+		t.Shards = append(t.Shards, ShardRange{ShardID: fmt.Sprintf("shardId-%012d", i), Range: r})
+	}
+	return t
+}
+
+// shuffledShards returns the shards of the topology as listed by Kinesis,
+// out of hash key order.
+func shuffledShards(t *Topology) []*kinesis.Shard {
+	shards := make([]*kinesis.Shard, 0, len(t.Shards))
+	for _, s := range t.Shards {
+		shards = append(shards, &kinesis.Shard{
+			ShardId: aws.String(s.ShardID),
+			HashKeyRange: &kinesis.HashKeyRange{
+				StartingHashKey: aws.String(s.Range.Start.String()),
+				EndingHashKey:   aws.String(s.Range.End.String()),
+			},
+			SequenceNumberRange: &kinesis.SequenceNumberRange{
+				StartingSequenceNumber: aws.String("0"),
+			},
+		})
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(shards), func(i, j int) {
+		shards[i], shards[j] = shards[j], shards[i]
+	})
+	return shards
+}
+
+func BenchmarkFilterOpenShardsSorted(b *testing.B) {
+	shards := shuffledShards(evenTopology(b, benchmarkShards))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filterOpenShards(shards, true)
+	}
+}
+
+func BenchmarkHalvePlan(b *testing.B) {
+	t := evenTopology(b, benchmarkShards)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		halvePlan(t, true)
+	}
+}
+
+func BenchmarkDoublePlan(b *testing.B) {
+	t := evenTopology(b, benchmarkShards)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := doublePlan(t, benchmarkShards/2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlanTopology(b *testing.B) {
+	current := evenTopology(b, benchmarkShards).Ranges()
+	target, err := hashrange.Full.Divide(benchmarkShards * 3 / 4)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := PlanTopology("bench", current, target); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRepairBoundaries(b *testing.B) {
+	current := evenTopology(b, benchmarkShards).Ranges()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := RepairBoundaries(current, WithShardCount(benchmarkShards+1), WithTolerance(0.1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDesignBoundaries(b *testing.B) {
+	counts := make(map[string]float64)
+	for i := 0; i < 10*benchmarkShards; i++ {
+		counts[fmt.Sprint("user-", i)] = float64(i%7 + 1)
+	}
+	heat := HeatMapFromPartitionKeys(counts)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DesignBoundaries(heat, benchmarkShards); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package kine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/olekukonko/tablewriter"
+)
+
+// StreamConsumer is an enhanced fan-out consumer registered to a stream.
+type StreamConsumer struct {
+	Name    string
+	ARN     string
+	Status  string
+	Created time.Time
+}
+
+// summaryThroughputLookback is the window of the recent peak throughput.
+const summaryThroughputLookback = time.Hour
+
+// Throughput is a write and read rate of a stream.
+type Throughput struct {
+	WriteBytesPerSecond   float64
+	WriteRecordsPerSecond float64
+	ReadBytesPerSecond    float64
+}
+
+// capacityOf returns the throughput the shards can take before throttling.
+func capacityOf(openShards int) Throughput {
+	return Throughput{
+		WriteBytesPerSecond:   float64(openShards * shardWriteBytesPerSecond),
+		WriteRecordsPerSecond: float64(openShards * shardWriteRecordsPerSecond),
+		ReadBytesPerSecond:    float64(openShards * shardReadBytesPerSecond),
+	}
+}
+
+// StreamSummary is the metadata of a stream in one place.
+type StreamSummary struct {
+	StreamName string
+	StreamARN  string
+	Status     string
+	// StreamMode is always PROVISIONED, see HealthReport.
+	StreamMode     string
+	Created        time.Time
+	OpenShards     int
+	ClosedShards   int
+	RetentionHours int
+	EncryptionType string
+	KeyID          string
+	// ShardLevelMetrics are the enhanced monitoring metrics, "ALL" or none.
+	ShardLevelMetrics []string
+	Consumers         []StreamConsumer
+	// EventSourceMappings are the Lambda functions reading the stream, nil
+	// when they cannot be listed, e.g. without
+	// lambda:ListEventSourceMappings.
+	EventSourceMappings []EventSourceMapping
+	Tags                map[string]string
+
+	// Capacity is implied by the open shards.
+	Capacity Throughput
+	// RecentPeak is the peak throughput of the last hour, nil when the
+	// CloudWatch metrics cannot be read.
+	RecentPeak *Throughput
+}
+
+// Summary returns the shards, retention, encryption, enhanced monitoring,
+// registered consumers, Lambda functions and tags of the stream.
+func (k *Kine) Summary(streamName string) (*StreamSummary, error) {
+
+	out, err := k.svc.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(streamName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := out.StreamDescriptionSummary
+
+	s := &StreamSummary{
+		StreamName:     streamName,
+		StreamARN:      *d.StreamARN,
+		Status:         *d.StreamStatus,
+		StreamMode:     "PROVISIONED",
+		Created:        aws.TimeValue(d.StreamCreationTimestamp),
+		OpenShards:     int(*d.OpenShardCount),
+		RetentionHours: int(*d.RetentionPeriodHours),
+		EncryptionType: aws.StringValue(d.EncryptionType),
+		KeyID:          aws.StringValue(d.KeyId),
+		Capacity:       capacityOf(int(*d.OpenShardCount)),
+	}
+	for _, m := range d.EnhancedMonitoring {
+		s.ShardLevelMetrics = append(s.ShardLevelMetrics, aws.StringValueSlice(m.ShardLevelMetrics)...)
+	}
+	sort.Strings(s.ShardLevelMetrics)
+
+	stream, err := k.DescribeStream(streamName)
+	if err != nil {
+		return nil, err
+	}
+	s.ClosedShards = len(stream.Shards) - len(filterOpenShards(stream.Shards, false))
+
+	err = k.svc.ListStreamConsumersPages(&kinesis.ListStreamConsumersInput{
+		StreamARN: d.StreamARN,
+	}, func(page *kinesis.ListStreamConsumersOutput, lastPage bool) bool {
+		for _, c := range page.Consumers {
+			s.Consumers = append(s.Consumers, StreamConsumer{
+				Name:    aws.StringValue(c.ConsumerName),
+				ARN:     aws.StringValue(c.ConsumerARN),
+				Status:  aws.StringValue(c.ConsumerStatus),
+				Created: aws.TimeValue(c.ConsumerCreationTimestamp),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.EventSourceMappings = k.eventSourceMappings(streamName)
+
+	s.Tags, err = k.listTags(streamName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RecentPeak = k.recentPeak(streamName)
+
+	return s, nil
+}
+
+func (k *Kine) recentPeak(streamName string) *Throughput {
+	t := &Throughput{}
+	peaks := []struct {
+		metricName string
+		peak       *float64
+	}{
+		{kinesis.MetricsNameIncomingBytes, &t.WriteBytesPerSecond},
+		{kinesis.MetricsNameIncomingRecords, &t.WriteRecordsPerSecond},
+		{"GetRecords.Bytes", &t.ReadBytesPerSecond},
+	}
+	for _, p := range peaks {
+		var err error
+		*p.peak, err = k.peakPerSecond(streamName, p.metricName, time.Minute, summaryThroughputLookback)
+		if err != nil {
+			return nil
+		}
+	}
+	return t
+}
+
+// formatUsage formats a rate and its share of the capacity.
+func formatUsage(v, capacity float64, unit string) string {
+	if capacity == 0 {
+		return fmt.Sprintf("%.0f %s", v, unit)
+	}
+	return fmt.Sprintf("%.0f %s (%.0f %%)", v, unit, v/capacity*100)
+}
+
+// Render writes the summary as a table.
+func (s *StreamSummary) Render(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+
+	encryption := s.EncryptionType
+	if s.KeyID != "" {
+		encryption = fmt.Sprintf("%s (%s)", s.EncryptionType, s.KeyID)
+	}
+	monitoring := "none"
+	if len(s.ShardLevelMetrics) > 0 {
+		monitoring = strings.Join(s.ShardLevelMetrics, ", ")
+	}
+
+	table.AppendBulk([][]string{
+		{"stream", s.StreamName},
+		{"arn", s.StreamARN},
+		{"status", s.Status},
+		{"mode", s.StreamMode},
+		{"created", s.Created.Format(time.RFC3339)},
+		{"shards", fmt.Sprintf("%d open, %d closed", s.OpenShards, s.ClosedShards)},
+		{"retention", fmt.Sprintf("%d hours", s.RetentionHours)},
+		{"encryption", encryption},
+		{"enhanced monitoring", monitoring},
+		{"write capacity", fmt.Sprintf("%.0f KB/s, %.0f records/s",
+			s.Capacity.WriteBytesPerSecond/1024, s.Capacity.WriteRecordsPerSecond)},
+		{"read capacity", fmt.Sprintf("%.0f KB/s", s.Capacity.ReadBytesPerSecond/1024)},
+	})
+	if p := s.RecentPeak; p != nil {
+		table.AppendBulk([][]string{
+			{"peak write (1h)", formatUsage(p.WriteBytesPerSecond/1024, s.Capacity.WriteBytesPerSecond/1024, "KB/s") + ", " +
+				formatUsage(p.WriteRecordsPerSecond, s.Capacity.WriteRecordsPerSecond, "records/s")},
+			{"peak read (1h)", formatUsage(p.ReadBytesPerSecond/1024, s.Capacity.ReadBytesPerSecond/1024, "KB/s")},
+		})
+	}
+	for _, c := range s.Consumers {
+		table.Append([]string{"consumer", fmt.Sprintf("%s (%s)", c.Name, c.Status)})
+	}
+	for _, m := range s.EventSourceMappings {
+		table.Append([]string{"lambda", fmt.Sprintf("%s (%s)", m.FunctionARN, m.State)})
+	}
+
+	keys := make([]string, 0, len(s.Tags))
+	for key := range s.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		table.Append([]string{"tag", key + "=" + s.Tags[key]})
+	}
+
+	table.Render()
+}
+
+// ViewSummary prints the summary of the stream.
+func (k *Kine) ViewSummary(streamName string) error {
+	s, err := k.Summary(streamName)
+	if err != nil {
+		return err
+	}
+	return printStdout(func(w io.Writer) error {
+		s.Render(w)
+		return nil
+	})
+}
@@ -0,0 +1,248 @@
+package kine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// TailFilter reports whether Tail prints the record, or whether a Route
+// matches it.
+type TailFilter func(r *Record) bool
+
+// TailFormat is how Tail prints the record data.
+type TailFormat string
+
+const (
+	FormatRaw    TailFormat = "raw"
+	FormatBase64 TailFormat = "base64"
+	FormatHex    TailFormat = "hex"
+	// FormatJSON pretty-prints JSON data, and prints other data as is.
+	FormatJSON TailFormat = "json"
+)
+
+// TailColumn is record metadata printed before the data.
+type TailColumn string
+
+const (
+	ColumnShard        TailColumn = "shard"
+	ColumnSequence     TailColumn = "sequence"
+	ColumnArrivalTime  TailColumn = "arrival"
+	ColumnPartitionKey TailColumn = "key"
+)
+
+var defaultTailColumns = []TailColumn{ColumnShard, ColumnSequence, ColumnPartitionKey}
+
+type tailConfig struct {
+	filters     []TailFilter
+	format      TailFormat
+	columns     []TailColumn
+	deaggregate bool
+	start       StartPosition
+}
+
+type TailOption func(c *tailConfig)
+
+// WithFormat sets how the data is printed, FormatRaw by default.
+func WithFormat(f TailFormat) TailOption {
+	return func(c *tailConfig) {
+		c.format = f
+	}
+}
+
+// WithColumns sets the metadata printed before the data, by default the
+// shard, sequence number and partition key. No columns prints the data only.
+func WithColumns(columns ...TailColumn) TailOption {
+	return func(c *tailConfig) {
+		c.columns = columns
+	}
+}
+
+// WithTailStart sets where Tail starts reading, StartLatest by default.
+// Starting from TRIM_HORIZON or a timestamp replays the retained records.
+func WithTailStart(p StartPosition) TailOption {
+	return func(c *tailConfig) {
+		c.start = p
+	}
+}
+
+// WithDeaggregation splits records aggregated by the Kinesis Producer Library
+// into the user records, which are then filtered and printed one by one.
+func WithDeaggregation() TailOption {
+	return func(c *tailConfig) {
+		c.deaggregate = true
+	}
+}
+
+// WithFilter makes Tail print only the records matching f. With several
+// filters, a record must match all of them.
+func WithFilter(f TailFilter) TailOption {
+	return func(c *tailConfig) {
+		c.filters = append(c.filters, f)
+	}
+}
+
+// PartitionKeyPrefix matches the records whose partition key starts with prefix.
+func PartitionKeyPrefix(prefix string) TailFilter {
+	return func(r *Record) bool {
+		return strings.HasPrefix(r.PartitionKey, prefix)
+	}
+}
+
+// PayloadMatches matches the records whose data matches re.
+func PayloadMatches(re *regexp.Regexp) TailFilter {
+	return func(r *Record) bool {
+		return re.Match(r.Data)
+	}
+}
+
+// JMESPathFilter matches the JSON records for which the expression is truthy,
+// e.g. `level == 'error'` or `user.id`. Records which are not JSON never match.
+func JMESPathFilter(expression string) (TailFilter, error) {
+	jp, err := jmespath.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *Record) bool {
+		var v interface{}
+		if err := json.Unmarshal(r.Data, &v); err != nil {
+			return false
+		}
+		result, err := jp.Search(v)
+		return err == nil && truthy(result)
+	}, nil
+}
+
+// truthy follows the JMESPath definition of false values.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// Tail prints the records arriving on every open shard of the stream to w
+// until ctx is done.
+func (k *Kine) Tail(ctx context.Context, streamName string, w io.Writer, opts ...TailOption) error {
+	c := &tailConfig{
+		format:  FormatRaw,
+		columns: defaultTailColumns,
+		start:   StartLatest,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	var mu sync.Mutex
+	handler := func(ctx context.Context, r *Record) error {
+		records := []*Record{r}
+		if c.deaggregate && isAggregated(r.Data) {
+			var err error
+			records, err = deaggregateRecord(r)
+			if err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range records {
+			if !c.match(r) {
+				continue
+			}
+			if _, err := io.WriteString(w, c.render(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return k.NewConsumer(streamName, handler, WithStartPosition(c.start)).Run(ctx)
+}
+
+func (c *tailConfig) match(r *Record) bool {
+	for _, f := range c.filters {
+		if !f(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *tailConfig) render(r *Record) string {
+	fields := make([]string, 0, len(c.columns)+1)
+	for _, column := range c.columns {
+		switch column {
+		case ColumnShard:
+			fields = append(fields, r.ShardID)
+		case ColumnSequence:
+			if r.aggregated {
+				fields = append(fields, fmt.Sprintf("%s/%d", r.SequenceNumber, r.SubSequenceNumber))
+			} else {
+				fields = append(fields, r.SequenceNumber)
+			}
+		case ColumnArrivalTime:
+			fields = append(fields, r.ArrivalTime.Format(time.RFC3339Nano))
+		case ColumnPartitionKey:
+			fields = append(fields, r.PartitionKey)
+		}
+	}
+
+	var data string
+	switch c.format {
+	case FormatBase64:
+		data = base64.StdEncoding.EncodeToString(r.Data)
+	case FormatHex:
+		// the dump spans several lines, so it starts on its own line
+		data = "\n" + strings.TrimSuffix(hex.Dump(r.Data), "\n")
+	case FormatJSON:
+		buf := &bytes.Buffer{}
+		if err := json.Indent(buf, r.Data, "", "  "); err == nil {
+			data = buf.String()
+		} else {
+			data = string(r.Data)
+		}
+	default:
+		data = string(r.Data)
+	}
+	fields = append(fields, data)
+
+	return strings.Join(fields, "\t") + "\n"
+}
+
+// deaggregateRecord splits a KPL aggregated record into its user records.
+func deaggregateRecord(r *Record) ([]*Record, error) {
+	users, err := deaggregate(r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", r.ShardID, r.SequenceNumber, err)
+	}
+	records := make([]*Record, 0, len(users))
+	for i, u := range users {
+		sub := *r
+		sub.SubSequenceNumber = i
+		sub.aggregated = true
+		sub.PartitionKey = u.PartitionKey
+		sub.Data = u.Data
+		records = append(records, &sub)
+	}
+	return records, nil
+}
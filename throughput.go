@@ -0,0 +1,147 @@
+package kine
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// getRecordsInterval keeps GetRecords calls under the limit of
+// 5 transactions per second per shard.
+const getRecordsInterval = 200 * time.Millisecond
+
+type ShardThroughput struct {
+	ShardID          string
+	Records          int
+	Bytes            int
+	RecordsPerSecond float64
+	BytesPerSecond   float64
+}
+
+type ThroughputEstimate struct {
+	Interval time.Duration
+	Shards   []ShardThroughput
+}
+
+// Skew returns the ratio of the busiest shard's records/sec to the average.
+// 1.0 means the traffic is perfectly even.
+func (e *ThroughputEstimate) Skew() float64 {
+	if len(e.Shards) == 0 {
+		return 0
+	}
+	var total, max float64
+	for _, s := range e.Shards {
+		total += s.RecordsPerSecond
+		if s.RecordsPerSecond > max {
+			max = s.RecordsPerSecond
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return max / (total / float64(len(e.Shards)))
+}
+
+// iteratorRefresh is how often the shards are read while estimating
+// throughput, under the 5 minutes after which Kinesis expires an iterator.
+const iteratorRefresh = 4 * time.Minute
+
+// EstimateThroughput takes the LATEST position of every open shard, waits for
+// interval and then reads what arrived in between, giving approximate
+// records/sec and bytes/sec per shard without CloudWatch.
+// The records are actually read, so this consumes read throughput. Over long
+// intervals the shards are read every 4 minutes, before their iterators
+// expire.
+func (k *Kine) EstimateThroughput(streamName string, interval time.Duration) (*ThroughputEstimate, error) {
+
+	shards, err := k.openShards(streamName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	iterators := make([]*string, len(shards))
+	for i, shard := range shards {
+		out, err := k.svc.GetShardIterator(&kinesis.GetShardIteratorInput{
+			ShardId:           shard.ShardId,
+			ShardIteratorType: aws.String(kinesis.ShardIteratorTypeLatest),
+			StreamName:        aws.String(streamName),
+		})
+		if err != nil {
+			return nil, err
+		}
+		iterators[i] = out.ShardIterator
+	}
+	start := k.clock.Now()
+
+	counts := make([]ShardThroughput, len(shards))
+	for i, shard := range shards {
+		counts[i].ShardID = *shard.ShardId
+	}
+
+	end := start.Add(interval)
+	for {
+		wait := end.Sub(k.clock.Now())
+		if wait > iteratorRefresh {
+			wait = iteratorRefresh
+		}
+		k.sleep(context.Background(), wait)
+		now := k.clock.Now()
+		if !now.Before(end) {
+			end = now
+			break
+		}
+		// read what arrived so far, moving the iterators forward
+		for i := range shards {
+			if iterators[i], err = k.readThroughput(iterators[i], time.Time{}, &counts[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	seconds := end.Sub(start).Seconds()
+
+	estimate := &ThroughputEstimate{
+		Interval: end.Sub(start),
+		Shards:   make([]ShardThroughput, 0, len(shards)),
+	}
+
+	for i := range shards {
+		t := counts[i]
+		if _, err := k.readThroughput(iterators[i], end, &t); err != nil {
+			return nil, err
+		}
+		t.RecordsPerSecond = float64(t.Records) / seconds
+		t.BytesPerSecond = float64(t.Bytes) / seconds
+		estimate.Shards = append(estimate.Shards, t)
+	}
+
+	return estimate, nil
+}
+
+// readThroughput counts the records of a shard arrived up to until, or all
+// of them when until is zero, and returns the iterator to read the next ones
+// from.
+func (k *Kine) readThroughput(iterator *string, until time.Time, t *ShardThroughput) (*string, error) {
+	for iterator != nil {
+		out, err := k.svc.GetRecords(&kinesis.GetRecordsInput{
+			ShardIterator: iterator,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range out.Records {
+			if !until.IsZero() && r.ApproximateArrivalTimestamp != nil && r.ApproximateArrivalTimestamp.After(until) {
+				return nil, nil
+			}
+			t.Records++
+			t.Bytes += len(r.Data)
+		}
+		iterator = out.NextShardIterator
+		if aws.Int64Value(out.MillisBehindLatest) == 0 {
+			break
+		}
+		k.sleep(context.Background(), getRecordsInterval)
+	}
+	return iterator, nil
+}
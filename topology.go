@@ -0,0 +1,68 @@
+package kine
+
+import (
+	"fmt"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// ShardRange is an open shard and its hash key range.
+type ShardRange struct {
+	ShardID string
+	Range   hashrange.Range
+}
+
+func (s ShardRange) String() string {
+	if s.ShardID == "" {
+		return s.Range.String()
+	}
+	return s.ShardID
+}
+
+// Topology is the set of open shards of a stream, sorted by hash key.
+type Topology struct {
+	StreamName string
+	Shards     []ShardRange
+}
+
+// Topology returns the open shards of the stream.
+func (k *Kine) Topology(streamName string) (*Topology, error) {
+
+	shards, err := k.openShards(streamName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Topology{
+		StreamName: streamName,
+		Shards:     make([]ShardRange, 0, len(shards)),
+	}
+	for _, s := range shards {
+		r, err := hashrange.Parse(*s.HashKeyRange.StartingHashKey, *s.HashKeyRange.EndingHashKey)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", *s.ShardId, err)
+		}
+		t.Shards = append(t.Shards, ShardRange{ShardID: *s.ShardId, Range: r})
+	}
+
+	return t, nil
+}
+
+// Ranges returns the hash key ranges of the shards.
+func (t *Topology) Ranges() []hashrange.Range {
+	ranges := make([]hashrange.Range, 0, len(t.Shards))
+	for _, s := range t.Shards {
+		ranges = append(ranges, s.Range)
+	}
+	return ranges
+}
+
+// Find returns the shard covering exactly r.
+func (t *Topology) Find(r hashrange.Range) (ShardRange, bool) {
+	for _, s := range t.Shards {
+		if s.Range == r {
+			return s, true
+		}
+	}
+	return ShardRange{}, false
+}
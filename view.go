@@ -0,0 +1,135 @@
+package kine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/ingtk/kine/hashrange"
+	"github.com/olekukonko/tablewriter"
+)
+
+type viewConfig struct {
+	quantiles int
+	top       int
+	highlight *Highlight
+	color     *bool
+}
+
+type ViewOption func(c *viewConfig)
+
+// WithQuantiles aggregates the view of streams with many shards: the hash
+// space is divided into n equal buckets, and each bucket shows the number of
+// shards starting in it and their average and largest share of the space.
+func WithQuantiles(n int) ViewOption {
+	return func(c *viewConfig) {
+		c.quantiles = n
+	}
+}
+
+// WithTop only shows the k shards covering the largest share of the hash
+// space, i.e. the most skewed ones, largest first.
+func WithTop(k int) ViewOption {
+	return func(c *viewConfig) {
+		c.top = k
+	}
+}
+
+func (k *Kine) viewWith(streamName string, w io.Writer, c *viewConfig) error {
+	switch {
+	case c.quantiles > 0:
+		shards, err := k.openShards(streamName, true)
+		if err != nil {
+			return err
+		}
+		return renderQuantiles(w, shards, c.quantiles)
+	case c.highlight != nil:
+		return k.viewHighlighted(streamName, w, c)
+	case c.top > 0:
+		shards, err := k.openShards(streamName, false)
+		if err != nil {
+			return err
+		}
+		renderTop(w, shards, c.top)
+		return nil
+	default:
+		return k.view(streamName, w)
+	}
+}
+
+func renderQuantiles(w io.Writer, shards []*kinesis.Shard, n int) error {
+	buckets, err := hashrange.Full.Divide(n)
+	if err != nil {
+		return err
+	}
+
+	type bucket struct {
+		shards int
+		total  float64
+		max    float64
+	}
+	stats := make([]bucket, n)
+	for _, s := range shards {
+		r, err := hashrange.Parse(*s.HashKeyRange.StartingHashKey, *s.HashKeyRange.EndingHashKey)
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", *s.ShardId, err)
+		}
+		i := sort.Search(n, func(i int) bool {
+			return buckets[i].End.Cmp(r.Start) >= 0
+		})
+		b := &stats[i]
+		b.shards++
+		b.total += r.Share()
+		if r.Share() > b.max {
+			b.max = r.Share()
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"hash space", "shards", "avg share", "max share"})
+	for i, b := range stats {
+		row := []string{
+			fmt.Sprintf("%.1f-%.1f %%", float64(i)*100/float64(n), float64(i+1)*100/float64(n)),
+			fmt.Sprintf("%d", b.shards),
+			"-",
+			"-",
+		}
+		if b.shards > 0 {
+			row[2] = fmt.Sprintf("%.2f %%", b.total/float64(b.shards)*100)
+			row[3] = fmt.Sprintf("%.2f %%", b.max*100)
+		}
+		table.Append(row)
+	}
+	table.Render()
+	return nil
+}
+
+func renderTop(w io.Writer, shards []*kinesis.Shard, k int) {
+	shares := make([]float64, len(shards))
+	for i, s := range shards {
+		shares[i] = hashRangeShare(s)
+	}
+	order := make([]int, len(shards))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return shares[order[i]] > shares[order[j]]
+	})
+	if k < len(order) {
+		order = order[:k]
+	}
+
+	even := 1 / float64(len(shards))
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"shard", "share", "vs even"})
+	for _, i := range order {
+		table.Append([]string{
+			*shards[i].ShardId,
+			fmt.Sprintf("%.2f %%", shares[i]*100),
+			fmt.Sprintf("%+.0f %%", (shares[i]/even-1)*100),
+		})
+	}
+	table.Render()
+}
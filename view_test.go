@@ -0,0 +1,80 @@
+package kine
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ingtk/kine/hashrange"
+)
+
+// largeShardCount is the shard count of the large on-demand streams.
+const largeShardCount = 5000
+
+// skewedTopology returns n shards, the first four of which cover 10 % of the
+// hash key space.
+func skewedTopology(tb testing.TB, n int) *Topology {
+	const wide = 4
+	lower, upper, err := hashrange.Full.SplitAt(0.1)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	wideRanges, err := lower.Divide(wide)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ranges, err := upper.Divide(n - wide)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	t := &Topology{StreamName: "large", Shards: make([]ShardRange, 0, n)}
+	for i, r := range append(wideRanges, ranges...) {
+		t.Shards = append(t.Shards, ShardRange{ShardID: fmt.Sprintf("shardId-%012d", i), Range: r})
+	}
+	return t
+}
+
+func TestRenderQuantilesLargeStream(t *testing.T) {
+	shards := shuffledShards(skewedTopology(t, largeShardCount))
+	buf := &bytes.Buffer{}
+	if err := renderQuantiles(buf, shards, 10); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "view_quantiles", buf.Bytes())
+}
+
+func TestRenderTopLargeStream(t *testing.T) {
+	shards := shuffledShards(skewedTopology(t, largeShardCount))
+	buf := &bytes.Buffer{}
+	renderTop(buf, filterOpenShards(shards, true), 5)
+	checkGolden(t, "view_top", buf.Bytes())
+}
+
+func BenchmarkRenderQuantilesLargeStream(b *testing.B) {
+	shards := shuffledShards(skewedTopology(b, largeShardCount))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := renderQuantiles(&bytes.Buffer{}, shards, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderTopLargeStream(b *testing.B) {
+	shards := shuffledShards(skewedTopology(b, largeShardCount))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderTop(&bytes.Buffer{}, shards, 5)
+	}
+}
+
+func BenchmarkFilterOpenShardsLargeStream(b *testing.B) {
+	shards := shuffledShards(skewedTopology(b, largeShardCount))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filterOpenShards(shards, true)
+	}
+}
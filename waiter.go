@@ -0,0 +1,123 @@
+package kine
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Backoff is the delay between polls of a waiter. The delay starts at
+// Initial, at least 100ms, and is multiplied by Multiplier after every
+// poll, up to Max.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoff polls every five seconds.
+var DefaultBackoff = Backoff{
+	Initial:    defaultWaitSecond,
+	Max:        defaultWaitSecond,
+	Multiplier: 1,
+}
+
+// minBackoff keeps a zero Backoff, or one shrinking to zero, from polling
+// in a tight loop.
+const minBackoff = 100 * time.Millisecond
+
+func (b Backoff) next(d time.Duration) time.Duration {
+	if d == 0 {
+		if b.Initial < minBackoff {
+			return minBackoff
+		}
+		return b.Initial
+	}
+	d = time.Duration(float64(d) * b.Multiplier)
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if d < minBackoff {
+		d = minBackoff
+	}
+	return d
+}
+
+// WithWaitBackoff sets the backoff used when kine waits for a stream,
+// both internally and by the exported waiters.
+func WithWaitBackoff(b Backoff) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.backoff = b
+		return nil
+	})
+}
+
+type waitConfig struct {
+	backoff Backoff
+}
+
+type WaitOption func(c *waitConfig)
+
+// WithBackoff overrides the backoff of the Kine for one wait.
+func WithBackoff(b Backoff) WaitOption {
+	return func(c *waitConfig) {
+		c.backoff = b
+	}
+}
+
+func (k *Kine) poll(ctx context.Context, opts []WaitOption, done func() (bool, error)) error {
+	c := &waitConfig{backoff: k.backoff}
+	for _, o := range opts {
+		o(c)
+	}
+
+	var d time.Duration
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		d = c.backoff.next(d)
+		if err := k.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// WaitUntilStreamActive waits until the stream status is ACTIVE, e.g. after
+// CreateStream, SplitShard or MergeShards.
+func (k *Kine) WaitUntilStreamActive(ctx context.Context, streamName string, opts ...WaitOption) error {
+	return k.poll(ctx, opts, func() (bool, error) {
+		stream, err := k.svc.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(streamName),
+		})
+		if err != nil {
+			return false, err
+		}
+		return *stream.StreamDescriptionSummary.StreamStatus == kinesis.StreamStatusActive, nil
+	})
+}
+
+// WaitUntilStreamDeleted waits until the stream does not exist anymore.
+func (k *Kine) WaitUntilStreamDeleted(ctx context.Context, streamName string, opts ...WaitOption) error {
+	return k.poll(ctx, opts, func() (bool, error) {
+		_, err := k.svc.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(streamName),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == kinesis.ErrCodeResourceNotFoundException {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+func (k *Kine) waitUntilActive(streamName string) error {
+	return k.WaitUntilStreamActive(context.Background(), streamName)
+}
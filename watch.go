@@ -0,0 +1,101 @@
+package kine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+type watchConfig struct {
+	changedOnly bool
+}
+
+type WatchOption func(c *watchConfig)
+
+// WithChangedOnly makes Watch print the table only once, and then a single
+// delta line when the topology changed, and nothing when it did not.
+func WithChangedOnly() WatchOption {
+	return func(c *watchConfig) {
+		c.changedOnly = true
+	}
+}
+
+// Watch prints the topology of the stream every interval until ctx is done.
+// A failing poll is logged and does not stop the loop.
+func (k *Kine) Watch(ctx context.Context, streamName string, interval time.Duration, w io.Writer, opts ...WatchOption) error {
+	c := &watchConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *Topology
+	for {
+		t, err := k.Topology(streamName)
+		if err != nil {
+			log.Printf("kine: watch %s: %v", streamName, err)
+		} else {
+			if err := k.renderWatch(w, prev, t, c); err != nil {
+				return err
+			}
+			prev = t
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (k *Kine) renderWatch(w io.Writer, prev, t *Topology, c *watchConfig) error {
+	now := k.clock.Now().Format("15:04:05")
+	if !c.changedOnly || prev == nil {
+		fmt.Fprintf(w, "%s %s: %d shards\n", now, t.StreamName, len(t.Shards))
+		return Render(t, TopologyTable, w)
+	}
+	if delta := topologyDelta(prev, t); delta != "" {
+		_, err := fmt.Fprintf(w, "%s %s: %s\n", now, t.StreamName, delta)
+		return err
+	}
+	return nil
+}
+
+// topologyDelta describes the shards opened and closed from prev to t, or
+// returns "" when they have the same shards.
+func topologyDelta(prev, t *Topology) string {
+	before := make(map[string]bool, len(prev.Shards))
+	for _, s := range prev.Shards {
+		before[s.ShardID] = true
+	}
+	var opened, closed []string
+	for _, s := range t.Shards {
+		if !before[s.ShardID] {
+			opened = append(opened, s.ShardID)
+		}
+		delete(before, s.ShardID)
+	}
+	for _, s := range prev.Shards {
+		if before[s.ShardID] {
+			closed = append(closed, s.ShardID)
+		}
+	}
+	if len(opened) == 0 && len(closed) == 0 {
+		return ""
+	}
+
+	parts := []string{fmt.Sprintf("%d -> %d shards", len(prev.Shards), len(t.Shards))}
+	if len(opened) > 0 {
+		parts = append(parts, "opened "+strings.Join(opened, " "))
+	}
+	if len(closed) > 0 {
+		parts = append(parts, "closed "+strings.Join(closed, " "))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,86 @@
+package kine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	webIdentityProviderName = "WebIdentityProvider"
+	// webIdentityExpiryWindow refreshes the credentials before they expire.
+	webIdentityExpiryWindow = 5 * time.Minute
+)
+
+// webIdentityProvider exchanges an OIDC token file for role credentials with
+// AssumeRoleWithWebIdentity, which the vendored SDK has no provider for.
+type webIdentityProvider struct {
+	credentials.Expiry
+
+	client    *sts.STS
+	roleARN   string
+	tokenFile string
+}
+
+func (p *webIdentityProvider) Retrieve() (credentials.Value, error) {
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return credentials.Value{ProviderName: webIdentityProviderName}, err
+	}
+
+	out, err := p.client.AssumeRoleWithWebIdentity(&sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.roleARN),
+		RoleSessionName:  aws.String(fmt.Sprintf("kine-%d", time.Now().UnixNano())),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	})
+	if err != nil {
+		return credentials.Value{ProviderName: webIdentityProviderName}, err
+	}
+
+	p.SetExpiration(*out.Credentials.Expiration, webIdentityExpiryWindow)
+	return credentials.Value{
+		AccessKeyID:     *out.Credentials.AccessKeyId,
+		SecretAccessKey: *out.Credentials.SecretAccessKey,
+		SessionToken:    *out.Credentials.SessionToken,
+		ProviderName:    webIdentityProviderName,
+	}, nil
+}
+
+// WithWebIdentity makes the client assume the role with the web identity
+// token of the file, as with EKS IAM roles for service accounts (IRSA).
+// Empty arguments default to the AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE
+// environment variables set by EKS. WithAssumeRole can chain another role.
+func WithWebIdentity(roleARN, tokenFile string) KineOption {
+	return OptionFn(func(k *Kine) error {
+		if roleARN == "" {
+			roleARN = os.Getenv("AWS_ROLE_ARN")
+		}
+		if tokenFile == "" {
+			tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+		if roleARN == "" || tokenFile == "" {
+			return fmt.Errorf("web identity needs a role ARN and a token file")
+		}
+		k.webIdentityRoleARN = roleARN
+		k.webIdentityTokenFile = tokenFile
+		return nil
+	})
+}
+
+func (k *Kine) webIdentityCredentials() *credentials.Credentials {
+	// the call is authenticated by the token, not by credentials
+	client := sts.New(k.session, k.serviceConfig(sts.EndpointsID), &aws.Config{
+		Credentials: credentials.AnonymousCredentials,
+	})
+	return credentials.NewCredentials(&webIdentityProvider{
+		client:    client,
+		roleARN:   k.webIdentityRoleARN,
+		tokenFile: k.webIdentityTokenFile,
+	})
+}
@@ -0,0 +1,123 @@
+package kine
+
+import (
+	"fmt"
+	"time"
+)
+
+// Window is a period of the day, repeated on Days or every day when Days is
+// empty. Start and End are offsets from midnight; a window with Start after
+// End spans midnight, and one with both zero is the whole day.
+type Window struct {
+	Days       []time.Weekday
+	Start, End time.Duration
+}
+
+// Contains reports whether t is in the window, in the location of t.
+func (w Window) Contains(t time.Time) bool {
+	if len(w.Days) > 0 {
+		found := false
+		for _, d := range w.Days {
+			if d == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if w.Start == 0 && w.End == 0 {
+		return true
+	}
+	y, m, d := t.Date()
+	offset := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if w.Start <= w.End {
+		return w.Start <= offset && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// ChangePolicy restricts when kine may reshard streams, e.g. to
+//
+//	ChangePolicy{
+//		Allow: []Window{{Start: 2 * time.Hour, End: 5 * time.Hour}},
+//		Deny:  []Window{{Days: []time.Weekday{time.Friday}}},
+//	}
+//
+// for 02:00-05:00 UTC except on Fridays.
+type ChangePolicy struct {
+	// Allow are the windows changes are allowed in, any time when empty.
+	Allow []Window
+	// Deny are the windows changes are never allowed in, e.g. a freeze.
+	Deny []Window
+	// Location is the time zone of the windows, UTC when nil.
+	Location *time.Location
+}
+
+// Allowed reports whether changes are allowed at t.
+func (p *ChangePolicy) Allowed(t time.Time) bool {
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	for _, w := range p.Deny {
+		if w.Contains(t) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, w := range p.Allow {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeWindowError is returned when a resharding is blocked by the
+// ChangePolicy of the Kine.
+type ChangeWindowError struct {
+	StreamName string
+	Operation  string
+	At         time.Time
+}
+
+func (e *ChangeWindowError) Error() string {
+	return fmt.Sprintf("%s %s: outside of the change window at %s", e.Operation, e.StreamName, e.At.Format(time.RFC3339))
+}
+
+// WithChangePolicy makes Apply, ScaleTo, SplitShardAt, HalveShard and
+// DoubleShard fail with a *ChangeWindowError when the policy does not allow
+// changes at the time.
+func WithChangePolicy(p ChangePolicy) KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.changePolicy = &p
+		return nil
+	})
+}
+
+// WithChangePolicyOverride ignores the change policy, e.g. for an emergency
+// resharding requested explicitly by an operator.
+func WithChangePolicyOverride() KineOption {
+	return OptionFn(func(k *Kine) error {
+		k.overrideChangePolicy = true
+		return nil
+	})
+}
+
+func (k *Kine) checkChangeWindow(streamName, operation string) error {
+	if k.changePolicy == nil || k.overrideChangePolicy {
+		return nil
+	}
+	now := k.clock.Now()
+	if k.changePolicy.Allowed(now) {
+		return nil
+	}
+	return &ChangeWindowError{StreamName: streamName, Operation: operation, At: now}
+}